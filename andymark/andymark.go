@@ -1,12 +1,13 @@
 package andymark
 
 import (
-	"encoding/json"
-	"fmt"
+	"log/slog"
 	"regexp"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/embedjson"
+	"github.com/toebes/ftc_parts_spider/onshape"
 	"github.com/toebes/ftc_parts_spider/partcatalog"
 	"github.com/toebes/ftc_parts_spider/spiderdata"
 )
@@ -15,14 +16,19 @@ import (
 var AndyMarkTarget = spiderdata.SpiderTarget{
 	Outfile:            "andymark.txt",
 	SpreadsheetID:      "1x4SUwNaQ_X687yA6kxPELoe7ZpoCKnnCq1-OsgxUCOw",
+	OnshapeNaming:      onshape.Naming{Vendor: "AndyMark"},
 	Presets:            []string{},
 	StripSKU:           false,
 	Seed:               "https://www.andymark.com/structure/",
 	ParsePageFunc:      ParseAndyMarkPage,
 	CheckMatchFunc:     CheckAndyMarkMatch,
+	IncludeRules:       []spiderdata.URLRule{spiderdata.Host("www.andymark.com")},
 	SectionNameDeletes: []string{},
 	SectionAllowedMap:  map[string]string{},
 	SectionEquivalents: [][]string{},
+
+	// The options of a product are picked with ?sku=, so those are different pages
+	SignificantParams: []string{"sku"},
 }
 
 const menuPrefix = "/menus/"
@@ -129,7 +135,7 @@ func CheckAndyMarkMatch(ctx *spiderdata.Context, partData *partcatalog.PartData)
 		}
 		// If the URL changes then we really want to use it.
 		// Just stash away the old URL so we know what happened
-		if !strings.EqualFold(partData.URL, entry.URL) {
+		if !spiderdata.SameURL(ctx, partData.URL, entry.URL) {
 			// In the case where there was a sku= on the URL we want to keep the one with it
 			urlString := partData.URL
 			newURL, strippedNew := spiderdata.CleanURL(ctx, partData.URL)
@@ -141,6 +147,9 @@ func CheckAndyMarkMatch(ctx *spiderdata.Context, partData *partcatalog.PartData)
 			// had the URL silently.
 			if strings.EqualFold(oldURL, newURL) {
 				partData.URL = urlString
+			} else if spiderdata.ReconcileMovedURL(ctx, partData, entry) {
+				// The catalog URL redirects here, so it has just moved.  Keep the new one and
+				// leave it for the URL moved report
 			} else {
 				partData.SpiderStatus = partcatalog.PartChanged
 				partData.Notes += extra + " Old URL:" + entry.URL
@@ -165,6 +174,8 @@ func CheckAndyMarkMatch(ctx *spiderdata.Context, partData *partcatalog.PartData)
 		}
 		// Prevent us from outputting the same entry more than once.
 		entry.SpiderStatus = partData.SpiderStatus
+		// Remember which row of the spreadsheet it came from so that any changes can be applied to it
+		partData.Row = entry.Row
 	} else {
 		partData.SpiderStatus = partcatalog.NewPart
 		partData.Status = "Not Done"
@@ -181,7 +192,7 @@ func findAllDownloads(ctx *spiderdata.Context, url string, root *goquery.Selecti
 		//<a target="_blank" class="product-documents__link" href="https://andymark-weblinc.netdna-ssl.com/media/W1siZiIsIjIwMTgvMTEvMDYvMTUvMDIvMTQvNTMwZjE4YmMtMmM5NS00Yzk3LTg3OWMtZjNmYzI1MTllMzJiL2FtLTMyODQgMzJ0IE5pbmphIFN0YXIgU3Byb2NrZXQuU1RFUCJdXQ/am-3284%2032t%20Ninja%20Star%20Sprocket.STEP?sha=9834a1285a141ddc">am-3284 32t Ninja Star Sprocket.STEP</a>
 		title := strings.TrimSpace(elem.Text())
 		dlurl, foundurl := elem.Attr("href")
-		fmt.Printf("Found a on '%v' href=%v\n", elem.Text(), dlurl)
+		slog.Debug("found document link", "title", elem.Text(), "href", dlurl)
 
 		if title == "" {
 			spiderdata.OutputError(ctx, "No Title found for url %s on %s\n", dlurl, url)
@@ -229,14 +240,12 @@ func processProductBrowse(ctx *spiderdata.Context, productname string, _ /*url*/
 	found = false
 	spiderdata.OutputCategory(ctx, productname, true)
 	product.Find("div.product-summary a.product-summary__media-link").Each(func(i int, linked *goquery.Selection) {
-		impression, hassimpression := linked.Attr("data-analytics-product-impression")
+		impression, hassimpression := embedjson.FromAttr(linked, "data-analytics-product-impression")
 		itemurl, hasurl := linked.Attr("href")
 		if hassimpression && hasurl {
-			var keys map[string]interface{}
-			json.Unmarshal([]byte(impression), &keys)
-			name := keys["name"]
-			sku := keys["sku"]
-			fmt.Printf(" Browse: name '%v' sku '%v' url '%v'\n", name, sku, itemurl)
+			name := impression.Get("name").String()
+			sku := impression.Get("sku").String()
+			slog.Debug("browse", "name", name, "sku", sku, "url", itemurl)
 			if !ctx.G.SingleOnly {
 				spiderdata.EnqueURL(ctx, itemurl, productname)
 			}
@@ -246,36 +255,31 @@ func processProductBrowse(ctx *spiderdata.Context, productname string, _ /*url*/
 	return
 }
 
-func processProductDetail(ctx *spiderdata.Context, productname string, url string, product *goquery.Selection) (found bool) {
+func processProductDetail(ctx *spiderdata.Context, productname string, url string, product *goquery.Selection, availability spiderdata.Availability) (found bool) {
 	found = false
 
 	spiderdata.OutputCategory(ctx, productname, true)
 	downloadurls := findAllDownloads(ctx, url, product)
 
-	productData, hasdata := product.Attr("data-analytics")
-	if hasdata {
-		var keys map[string]interface{}
-		json.Unmarshal([]byte(productData), &keys)
-		payload := keys["payload"].(map[string]interface{})
-		name := payload["name"].(string)
-		sku := ""
-		skuelem := payload["sku"]
-		if skuelem != nil {
-			sku = skuelem.(string)
-		} else {
-			skuelem = payload["id"]
-			if skuelem != nil {
-				sku = skuelem.(string)
-			}
+	productData, hasdata := embedjson.FromAttr(product, "data-analytics")
+	payload := productData.Get("payload")
+	if hasdata && payload.Exists() {
+		name := payload.Get("name").String()
+		sku := payload.Get("sku").String()
+		if sku == "" {
+			sku = payload.Get("id").String()
 		}
 
-		spiderdata.OutputProduct(ctx, name, sku, url, getDownloadURL(ctx, sku, downloadurls), false, nil)
+		partData := spiderdata.NewProductData(name, sku, url, getDownloadURL(ctx, sku, downloadurls), nil)
+		partData.Specs = spiderdata.ExtractSpecs(product)
+		partData.Images = spiderdata.ExtractImages(ctx, product)
+		spiderdata.OutputProductData(ctx, partData, availability)
 		found = true
 	}
 	return
 }
 
-func processProductSelection(ctx *spiderdata.Context, productname string, url string, product *goquery.Selection) (found bool) {
+func processProductSelection(ctx *spiderdata.Context, productname string, url string, product *goquery.Selection, availability spiderdata.Availability) (found bool) {
 	found = false
 	spiderdata.OutputCategory(ctx, productname, true)
 	localname := product.Find("h1.product-details__heading").Text()
@@ -283,6 +287,9 @@ func processProductSelection(ctx *spiderdata.Context, productname string, url st
 	changeset := product.Find("div.select-menu select")
 
 	downloadurls := findAllDownloads(ctx, url, product)
+	parentID := spiderdata.ProductID(product)
+	specs := spiderdata.ExtractSpecs(product)
+	images := spiderdata.ExtractImages(ctx, product)
 	if changeset.Children().Length() > 0 {
 		changeset.Find("option").Each(func(i int, option *goquery.Selection) {
 			value, hasval := option.Attr("value")
@@ -293,7 +300,16 @@ func processProductSelection(ctx *spiderdata.Context, productname string, url st
 				if pos >= 0 {
 					namepart := value[:pos-1]
 					sku := value[pos+2 : len(value)-1]
-					spiderdata.OutputProduct(ctx, localname+" "+namepart, sku, url, getDownloadURL(ctx, sku, downloadurls), false, nil)
+					// The label for the menu says what the option is (Color, Length...)
+					optionName := spiderdata.OptionName(option.Closest("div.select-menu").Find("label").Text(), "Option")
+					partData := spiderdata.NewProductData(localname+" "+namepart, sku, url, getDownloadURL(ctx, sku, downloadurls), nil)
+					partData.ParentID = parentID
+					partData.BaseName = strings.TrimSpace(localname)
+					// The part name is left as it has always been made, but the option value is the whole description
+					partData.Variant = []partcatalog.VariantAttribute{{Name: optionName, Value: strings.TrimSpace(value[:pos])}}
+					partData.Specs = specs
+					partData.Images = images
+					spiderdata.OutputProductData(ctx, partData, max(availability, spiderdata.TextAvailability(option, "")))
 					found = true
 				}
 			}
@@ -379,13 +395,13 @@ func CacheNav(ctx *spiderdata.Context, nav *goquery.Selection) {
 		alink := l1.Find("a.primary-nav__link")
 		navtitle := ""
 		if alink.Length() != 1 {
-			fmt.Printf("+++Looking at top level link expected 1 entry found %v\n", alink.Length())
+			slog.Warn("unexpected number of top level nav links", "expected", 1, "found", alink.Length())
 		}
 		href, hashref := alink.Attr(("href"))
 		if hashref {
 			title := alink.Find("span.primary-nav__link-text")
 			if title.Length() != 1 {
-				fmt.Printf("+++Looking at title for '%v' expected 1 link text found %v\n", href, title.Length())
+				slog.Warn("unexpected number of nav link titles", "url", href, "expected", 1, "found", title.Length())
 			}
 			navtitle = title.Text()
 			// We have a title and a URL, so output it and then find the children
@@ -461,9 +477,6 @@ func ParseAndyMarkPage(ctx *spiderdata.Context, doc *goquery.Document) {
 	breadcrumbs := getBreadCrumbName(ctx, url, doc.Find("div.breadcrumbs"))
 	spiderdata.MarkVisitedURL(ctx, url, breadcrumbs)
 
-	// see if this has been discontinued
-	// isDiscontinued := (doc.Find("p.discontinued").Length() > 0)
-
 	// fmt.Printf("Breadcrumb:%s\n", breadcrumbs)
 
 	primaryNav := doc.Find("nav.primary-nav")
@@ -489,7 +502,9 @@ func ParseAndyMarkPage(ctx *spiderdata.Context, doc *goquery.Document) {
 	if !found {
 		doc.Find("div.product-details--option_selects").Each(func(i int, productselect *goquery.Selection) {
 			// fmt.Printf("Found Product Details Selection\n")
-			if processProductSelection(ctx, breadcrumbs, url, productselect) {
+			// The options are checked individually since only some of them may be discontinued
+			availability := spiderdata.ProductAvailability(doc, productselect, "div.select-menu")
+			if processProductSelection(ctx, breadcrumbs, url, productselect, availability) {
 				found = true
 			}
 		})
@@ -507,7 +522,7 @@ func ParseAndyMarkPage(ctx *spiderdata.Context, doc *goquery.Document) {
 	if !found {
 		doc.Find("div.product-detail-container").Each(func(i int, product *goquery.Selection) {
 			// fmt.Printf("Found Product Detail Container")
-			if processProductDetail(ctx, breadcrumbs, url, product) {
+			if processProductDetail(ctx, breadcrumbs, url, product, spiderdata.ProductAvailability(doc, product, "")) {
 				found = true
 			}
 		})
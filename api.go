@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/toebes/ftc_parts_spider/catalogapi"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// apiCommand serves the reference catalog and the latest run output as a read-only JSON API
+//
+//	ftc_parts_spider api [-addr localhost:8081] [-target rev] [-run rev.txt]
+func apiCommand(args []string) int {
+	fs := flag.NewFlagSet("api", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8081", "Address to listen on")
+	apiTarget := fs.String("target", "rev", "Target vendor whose catalog and run output are served")
+	sheet := fs.String("spreadsheet", "", "Catalog spreadsheet (defaults to the one for the target)")
+	runFile := fs.String("run", "", "Run output to serve (defaults to the output file for the target)")
+	skipCatalog := fs.Bool("skipcatalog", false, "Only serve the run output")
+	reload := fs.Duration("reload", time.Minute, "How often to check the run output for a newer version (0 to never check)")
+	credentials := credentialFlags(fs)
+	logging := loggingFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s api [flags]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := logging("command", fs.Name()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	config, present := targets[*apiTarget]
+	if !present {
+		slog.Error("unknown target", "phase", "load", "target", *apiTarget)
+		return 2
+	}
+	if *sheet == "" {
+		*sheet = config.SpreadsheetID
+	}
+	if *runFile == "" {
+		*runFile = config.Outfile
+	}
+
+	server := catalogapi.New()
+	if !*skipCatalog {
+		catalog, err := partcatalog.LoadPartCatalogWithOptions(sheet, nil, partcatalog.LoadOptions{
+			Credentials: credentials(),
+			Sheet:       config.Sheet,
+		})
+		if err != nil {
+			slog.Error("unable to load the part catalog", "phase", "load", "spreadsheet", *sheet, "err", err)
+			return 1
+		}
+		server.Update(catalogapi.CatalogSource, catalog.Partdata)
+		slog.Info("loaded part catalog", "phase", "load", "spreadsheet", *sheet, "parts", len(catalog.Partdata))
+	}
+
+	modTime, err := loadRunSource(server, *runFile)
+	if err != nil {
+		slog.Error("unable to load run output", "phase", "load", "path", *runFile, "err", err)
+		return 1
+	}
+	if *reload > 0 {
+		go func() {
+			for range time.Tick(*reload) {
+				fi, err := os.Stat(*runFile)
+				if err != nil || !fi.ModTime().After(modTime) {
+					continue
+				}
+				if newTime, err := loadRunSource(server, *runFile); err != nil {
+					slog.Warn("unable to reload run output", "phase", "load", "path", *runFile, "err", err)
+				} else {
+					modTime = newTime
+				}
+			}
+		}()
+	}
+
+	slog.Info("serving", "phase", "serve", "url", "http://"+*addr+"/api/")
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		slog.Error("server stopped", "phase", "serve", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// loadRunSource reads the run output into the API, returning the modification time of what was loaded
+func loadRunSource(server *catalogapi.Server, path string) (time.Time, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	parts, err := partcatalog.LoadRunOutput(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	server.Update(catalogapi.RunSource, parts)
+	slog.Info("loaded run output", "phase", "load", "path", path, "parts", len(parts))
+	return fi.ModTime(), nil
+}
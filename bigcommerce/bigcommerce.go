@@ -0,0 +1,341 @@
+// Package bigcommerce reads products straight from the Storefront GraphQL API of a BigCommerce store
+// (goBILDA, ServoCity and REV all run on BigCommerce).  The API gives the SKU and options of every
+// variant of a product, which is much more reliable than picking them out of the product page.
+//
+// The API needs the storefront token which the store puts in the page for its own scripts, so the
+// token is found from the first product page and then used for the rest of the run.
+package bigcommerce
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/embedjson"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// MaxFailures is how many requests in a row can fail before the API is given up on for the run,
+// so that a store which has turned the API off doesn't cost an extra request for every page.  Only
+// requests which get no answer (or are refused, as with a bad token) count, not a product the API
+// doesn't know
+const MaxFailures = 5
+
+// maxSize is the largest response we will read
+const maxSize = 8 << 20
+
+// ErrDisabled is returned once the API has failed too often or no token can be found
+var ErrDisabled = errors.New("storefront API disabled")
+
+// ErrNotFound is returned when the API doesn't have the product
+var ErrNotFound = errors.New("product not found")
+
+// errRequest marks the failures which count towards MaxFailures
+var errRequest = errors.New("storefront API request failed")
+
+// Client talks to the Storefront GraphQL API of one store
+type Client struct {
+	HTTP *http.Client
+	// Endpoint is the GraphQL URL, normally https://<store host>/graphql
+	Endpoint string
+	// Token is the storefront API token.  It is found from the pages with FindToken when not given
+	Token string
+
+	mu       sync.Mutex
+	failures int
+}
+
+// NewClient creates a client for the store which serves site
+func NewClient(httpClient *http.Client, site *url.URL) *Client {
+	return &Client{HTTP: httpClient, Endpoint: site.Scheme + "://" + site.Host + "/graphql"}
+}
+
+// Product is a product as the API describes it
+type Product struct {
+	ID           int
+	Name         string
+	SKU          string
+	Path         string
+	Availability string // Available, Preorder or Unavailable
+	Price        float64
+	Currency     string
+	Images       []string // The default picture first
+	Variants     []Variant
+}
+
+// Variant is one orderable combination of the options of a product
+type Variant struct {
+	ID          int
+	SKU         string
+	Purchasable bool
+	Price       float64
+	Options     []partcatalog.VariantAttribute
+}
+
+// productQuery asks for everything needed to output the parts of a product
+const productQuery = `query ProductByID($id: Int!) {
+  site {
+    product(entityId: $id) {
+      entityId
+      name
+      sku
+      path
+      availabilityV2 { status }
+      prices { price { value currencyCode } }
+      images { edges { node { url(width: 1280) isDefault } } }
+      variants(first: 250) {
+        edges {
+          node {
+            entityId
+            sku
+            isPurchasable
+            prices { price { value currencyCode } }
+            options { edges { node { displayName values { edges { node { label } } } } } }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// price is the money amount used throughout the API
+type price struct {
+	Value        float64 `json:"value"`
+	CurrencyCode string  `json:"currencyCode"`
+}
+
+// prices holds the price of a product or variant, which is missing when it is hidden
+type prices struct {
+	Price price `json:"price"`
+}
+
+// productResponse mirrors the JSON returned for productQuery
+type productResponse struct {
+	Data struct {
+		Site struct {
+			Product *struct {
+				EntityID       int    `json:"entityId"`
+				Name           string `json:"name"`
+				SKU            string `json:"sku"`
+				Path           string `json:"path"`
+				AvailabilityV2 struct {
+					Status string `json:"status"`
+				} `json:"availabilityV2"`
+				Prices *prices `json:"prices"`
+				Images struct {
+					Edges []struct {
+						Node struct {
+							URL       string `json:"url"`
+							IsDefault bool   `json:"isDefault"`
+						} `json:"node"`
+					} `json:"edges"`
+				} `json:"images"`
+				Variants struct {
+					Edges []struct {
+						Node struct {
+							EntityID      int     `json:"entityId"`
+							SKU           string  `json:"sku"`
+							IsPurchasable bool    `json:"isPurchasable"`
+							Prices        *prices `json:"prices"`
+							Options       struct {
+								Edges []struct {
+									Node struct {
+										DisplayName string `json:"displayName"`
+										Values      struct {
+											Edges []struct {
+												Node struct {
+													Label string `json:"label"`
+												} `json:"node"`
+											} `json:"edges"`
+										} `json:"values"`
+									} `json:"node"`
+								} `json:"edges"`
+							} `json:"options"`
+						} `json:"node"`
+					} `json:"edges"`
+				} `json:"variants"`
+			} `json:"product"`
+		} `json:"site"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// FindToken looks for the storefront API token in the stencilBootstrap data of a page and keeps it
+// for the following requests.  It returns false when the client still has no token
+func (c *Client) FindToken(doc *goquery.Selection) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Token != "" {
+		return true
+	}
+	doc.Find("script").EachWithBreak(func(i int, script *goquery.Selection) bool {
+		args, found := embedjson.FromCall(script.Text(), "window.stencilBootstrap")
+		if !found || len(args) < 2 {
+			return true
+		}
+		c.Token = args[1].Get("..storefront_api.token").String()
+		return c.Token == ""
+	})
+	return c.Token != ""
+}
+
+// Disabled reports whether the API has been given up on for the run
+func (c *Client) Disabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failures >= MaxFailures
+}
+
+// Product asks the API for a product by its BigCommerce product ID (the product_id of the cart form)
+func (c *Client) Product(id string) (*Product, error) {
+	entityID, err := strconv.Atoi(strings.TrimSpace(id))
+	if err != nil {
+		return nil, fmt.Errorf("bad product ID %q", id)
+	}
+	c.mu.Lock()
+	token := c.Token
+	disabled := c.failures >= MaxFailures
+	c.mu.Unlock()
+	if disabled || token == "" {
+		return nil, ErrDisabled
+	}
+	product, err := c.fetch(entityID, token)
+	c.mu.Lock()
+	if errors.Is(err, errRequest) {
+		c.failures++
+	} else {
+		// The API answered, even if it didn't know the product
+		c.failures = 0
+	}
+	c.mu.Unlock()
+	return product, err
+}
+
+// fetch does the work of Product
+func (c *Client) fetch(id int, token string) (*Product, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     productQuery,
+		"variables": map[string]int{"id": id},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRequest, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned %s", errRequest, c.Endpoint, res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseProduct(data)
+}
+
+// ParseProduct converts the JSON response to productQuery into a Product
+func ParseProduct(data []byte) (*Product, error) {
+	var response productResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("unable to decode storefront response: %w", err)
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("storefront API error: %s", response.Errors[0].Message)
+	}
+	found := response.Data.Site.Product
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	product := &Product{
+		ID:           found.EntityID,
+		Name:         strings.TrimSpace(found.Name),
+		SKU:          strings.TrimSpace(found.SKU),
+		Path:         found.Path,
+		Availability: found.AvailabilityV2.Status,
+	}
+	if found.Prices != nil {
+		product.Price = found.Prices.Price.Value
+		product.Currency = found.Prices.Price.CurrencyCode
+	}
+	for _, edge := range found.Images.Edges {
+		if edge.Node.IsDefault {
+			product.Images = append([]string{edge.Node.URL}, product.Images...)
+		} else {
+			product.Images = append(product.Images, edge.Node.URL)
+		}
+	}
+	for _, edge := range found.Variants.Edges {
+		node := edge.Node
+		variant := Variant{ID: node.EntityID, SKU: strings.TrimSpace(node.SKU), Purchasable: node.IsPurchasable}
+		if node.Prices != nil {
+			variant.Price = node.Prices.Price.Value
+		}
+		for _, option := range node.Options.Edges {
+			// A variant has exactly one value picked for each of its options
+			for _, value := range option.Node.Values.Edges {
+				variant.Options = append(variant.Options, partcatalog.VariantAttribute{
+					Name:  strings.TrimSpace(option.Node.DisplayName),
+					Value: strings.TrimSpace(value.Node.Label),
+				})
+			}
+		}
+		product.Variants = append(product.Variants, variant)
+	}
+	return product, nil
+}
+
+// Unavailable reports whether the API says that the product can't be ordered any more
+func (p *Product) Unavailable() bool {
+	return strings.EqualFold(p.Availability, "Unavailable")
+}
+
+// Parts turns the product into the parts to output, one for each variant with options (named after
+// the product and its option values) or a single part when the product has no options.  url is the
+// page the product was found on.  The model, specifications and section come from the page
+func (p *Product) Parts(url string) []*partcatalog.PartData {
+	var result []*partcatalog.PartData
+	for _, variant := range p.Variants {
+		if len(variant.Options) == 0 || variant.SKU == "" {
+			continue
+		}
+		values := make([]string, 0, len(variant.Options))
+		for _, option := range variant.Options {
+			values = append(values, option.Value)
+		}
+		result = append(result, &partcatalog.PartData{
+			Name:     p.Name + " - " + strings.Join(values, " - "),
+			SKU:      variant.SKU,
+			URL:      url,
+			ParentID: strconv.Itoa(p.ID),
+			BaseName: p.Name,
+			Variant:  variant.Options,
+			Images:   p.Images,
+		})
+	}
+	if len(result) == 0 && p.SKU != "" {
+		result = append(result, &partcatalog.PartData{Name: p.Name, SKU: p.SKU, URL: url, Images: p.Images})
+	}
+	return result
+}
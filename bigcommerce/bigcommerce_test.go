@@ -0,0 +1,147 @@
+package bigcommerce
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+const testToken = "eyJ0eXAiOiJKV1QiLCJhbGciOiJFUzI1NiJ9.test"
+
+// fixtureServer serves a recorded GraphQL response, checking that the token was sent
+func fixtureServer(t *testing.T, fixture string) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" || r.Header.Get("Authorization") != "Bearer "+testToken {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server) *Client {
+	t.Helper()
+	site, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(srv.Client(), site)
+	client.Token = testToken
+	return client
+}
+
+func TestFindToken(t *testing.T) {
+	f, err := os.Open("testdata/product.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{}
+	if !client.FindToken(doc.Selection) || client.Token != testToken {
+		t.Errorf("FindToken gave %q, expected %q", client.Token, testToken)
+	}
+}
+
+func TestProductVariants(t *testing.T) {
+	srv := fixtureServer(t, "product.json")
+	defer srv.Close()
+	product, err := newTestClient(t, srv).Product("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.ID != 1234 || product.SKU != "1109-0048" || product.Price != 4.99 || len(product.Variants) != 2 {
+		t.Fatalf("unexpected product %+v", product)
+	}
+	if product.Images[0] != "https://cdn11.bigcommerce.com/s-abc/images/stencil/1280w/products/1234/1/main.jpg" {
+		t.Errorf("default image is not first: %q", product.Images)
+	}
+
+	parts := product.Parts("https://www.gobilda.com/gorail-48mm-length/")
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, expected 2", len(parts))
+	}
+	want := &partcatalog.PartData{
+		Name:     "goRAIL (48mm Length) - 2 Pack",
+		SKU:      "1109-0048-0002",
+		URL:      "https://www.gobilda.com/gorail-48mm-length/",
+		ParentID: "1234",
+		BaseName: "goRAIL (48mm Length)",
+		Variant:  []partcatalog.VariantAttribute{{Name: "Pack Size", Value: "2 Pack"}},
+		Images:   product.Images,
+	}
+	if !reflect.DeepEqual(parts[1], want) {
+		t.Errorf("second part is\n%+v\nexpected\n%+v", parts[1], want)
+	}
+}
+
+func TestProductWithoutOptions(t *testing.T) {
+	srv := fixtureServer(t, "simple.json")
+	defer srv.Close()
+	product, err := newTestClient(t, srv).Product("77")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := product.Parts("https://www.gobilda.com/m4-x-8mm-socket-head-screw/")
+	if len(parts) != 1 || parts[0].SKU != "2800-0004-0008" || parts[0].ParentID != "" {
+		t.Errorf("unexpected parts %+v", parts)
+	}
+}
+
+func TestProductNotFound(t *testing.T) {
+	srv := fixtureServer(t, "notfound.json")
+	defer srv.Close()
+	client := newTestClient(t, srv)
+	// The API is working, it just doesn't have the product, so it isn't given up on
+	for i := 0; i <= MaxFailures; i++ {
+		if _, err := client.Product("1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("missing product gave %v", err)
+		}
+	}
+	if client.Disabled() {
+		t.Errorf("client disabled by products which weren't found")
+	}
+}
+
+func TestProductUnavailable(t *testing.T) {
+	for status, want := range map[string]bool{"Available": false, "Preorder": false, "Unavailable": true, "": false} {
+		if got := (&Product{Availability: status}).Unavailable(); got != want {
+			t.Errorf("Unavailable with %q = %v, expected %v", status, got, want)
+		}
+	}
+}
+
+func TestGivesUpAfterFailures(t *testing.T) {
+	srv := fixtureServer(t, "product.json")
+	defer srv.Close()
+	client := newTestClient(t, srv)
+	client.Token = "expired"
+	for i := 0; i < MaxFailures; i++ {
+		if _, err := client.Product("1234"); err == nil {
+			t.Fatalf("request %d with a bad token succeeded", i)
+		}
+	}
+	if !client.Disabled() {
+		t.Errorf("client not disabled after %d failures", MaxFailures)
+	}
+	client.Token = testToken
+	if _, err := client.Product("1234"); err != ErrDisabled {
+		t.Errorf("disabled client gave %v, expected ErrDisabled", err)
+	}
+}
@@ -0,0 +1,149 @@
+package canonurl
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Canonicalizer normalizes vendor URLs so that the same page always ends up as the same string.
+// It turns http into https, lower cases the host (and the path when IgnoreCase), folds the bare and
+// www. forms of the vendor host together, drops default ports, duplicate slashes, fragments and
+// tracking parameters, sorts what is left of the query and makes the trailing slash consistent
+type Canonicalizer struct {
+	// Host is the preferred form of the vendor's host, e.g. www.revrobotics.com.  revrobotics.com is turned into it
+	Host string
+	// IgnoreCase lower cases the path, for sites which are known to ignore its case.  The path is kept
+	// as it is otherwise, since the key is also the address which gets fetched
+	IgnoreCase bool
+	// TrailingSlash ends the page paths with a / (as BigCommerce sites do) rather than removing it.
+	// Paths which look like files (with an extension) never get one
+	TrailingSlash bool
+	// StripQuery drops the query parameters from the page keys, except for SignificantParams
+	StripQuery bool
+	// SignificantParams are the query parameters which pick out a different page (such as sku) and are never stripped
+	SignificantParams []string
+}
+
+// trackingParams are query parameters which never change the page that comes back
+var trackingParams = []string{"utm_", "fbclid", "gclid", "mc_cid", "mc_eid", "_ga"}
+
+// fileExtension matches the extension of a path which names a file rather than a page.  Slugs such as
+// 1.50-bore have a dot in them but aren't files
+var fileExtension = regexp.MustCompile(`^\.[A-Za-z][A-Za-z0-9]{0,4}$`)
+
+// isTracking checks whether a query parameter is only there for analytics
+func isTracking(name string) bool {
+	name = strings.ToLower(name)
+	for _, prefix := range trackingParams {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Canonical normalizes a URL keeping all of the query parameters which aren't for tracking.
+// This is the form written to the output file and used to look up catalog entries by URL.
+// Anything which can't be parsed as an absolute http(s) URL is returned trimmed but otherwise unchanged
+func (c *Canonicalizer) Canonical(raw string) string {
+	return c.normalize(raw, false)
+}
+
+// Key normalizes a URL to identify the page to fetch.  It is the same as Canonical except that
+// when StripQuery is set only the SignificantParams are kept
+func (c *Canonicalizer) Key(raw string) string {
+	return c.normalize(raw, c != nil && c.StripQuery)
+}
+
+// Same checks whether two URLs refer to the same thing once they are made canonical
+func (c *Canonicalizer) Same(a string, b string) bool {
+	return strings.EqualFold(c.Canonical(a), c.Canonical(b))
+}
+
+// SameHost checks whether a URL is on the vendor's host (in either the bare or www. form)
+func (c *Canonicalizer) SameHost(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || c == nil || c.Host == "" {
+		return false
+	}
+	return c.foldHost(strings.ToLower(u.Hostname())) == strings.ToLower(c.Host)
+}
+
+// foldHost turns the bare or www. form of the vendor host into the preferred one
+func (c *Canonicalizer) foldHost(host string) string {
+	if c == nil || c.Host == "" {
+		return host
+	}
+	preferred := strings.ToLower(c.Host)
+	if strings.TrimPrefix(host, "www.") == strings.TrimPrefix(preferred, "www.") {
+		return preferred
+	}
+	return host
+}
+
+// normalize does the work of Canonical and Key
+func (c *Canonicalizer) normalize(raw string, stripQuery bool) string {
+	trimmed := strings.TrimSpace(raw)
+	u, err := url.Parse(trimmed)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return trimmed
+	}
+	u.Scheme = "https"
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	} else {
+		host = c.foldHost(host)
+	}
+	u.Host = host
+
+	p := u.Path
+	if p != "" {
+		p = path.Clean("/" + p)
+	}
+	if p == "/" {
+		p = ""
+	} else if p != "" && c != nil && c.TrailingSlash && !fileExtension.MatchString(path.Ext(p)) {
+		p += "/"
+	}
+	if c != nil && c.IgnoreCase {
+		p = strings.ToLower(p)
+	}
+	u.Path = p
+	u.RawPath = ""
+
+	query := u.Query()
+	for name := range query {
+		if isTracking(name) || (stripQuery && !c.significant(name)) {
+			query.Del(name)
+		}
+	}
+	// Encode sorts the parameters so that their order doesn't matter
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+
+	result := u.String()
+	if u.Path == "" && u.RawQuery == "" {
+		result += "/"
+	}
+	return result
+}
+
+// significant checks whether a query parameter is one which is never stripped
+func (c *Canonicalizer) significant(name string) bool {
+	if c == nil {
+		return false
+	}
+	for _, param := range c.SignificantParams {
+		if strings.EqualFold(param, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package canonurl
+
+import "testing"
+
+func TestCanonical(t *testing.T) {
+	rev := &Canonicalizer{Host: "www.revrobotics.com"}
+	folded := &Canonicalizer{Host: "www.revrobotics.com", IgnoreCase: true}
+	bigcommerce := &Canonicalizer{Host: "www.servocity.com", TrailingSlash: true}
+	tests := []struct {
+		c    *Canonicalizer
+		raw  string
+		want string
+	}{
+		// http becomes https and the bare host is folded into the www. one
+		{rev, "http://revrobotics.com/Gears/", "https://www.revrobotics.com/Gears"},
+		{rev, "HTTP://WWW.RevRobotics.com/a//b/#top", "https://www.revrobotics.com/a/b"},
+		{rev, "https://www.revrobotics.com", "https://www.revrobotics.com/"},
+		{rev, "  https://cdn.example.com/a/  ", "https://cdn.example.com/a"},
+		// Only a site which ignores case has its paths lower cased
+		{folded, "https://revrobotics.com/Gears/Spur", "https://www.revrobotics.com/gears/spur"},
+		// Trailing slashes are added to pages but not to files, and a dotted slug is still a page
+		{bigcommerce, "https://www.servocity.com/motion", "https://www.servocity.com/motion/"},
+		{bigcommerce, "https://www.servocity.com/images/gear.png", "https://www.servocity.com/images/gear.png"},
+		{bigcommerce, "https://www.servocity.com/1.50-bore", "https://www.servocity.com/1.50-bore/"},
+		// Tracking parameters go and the rest are sorted
+		{rev, "https://www.revrobotics.com/gear?utm_source=x&gclid=1&b=2&a=1", "https://www.revrobotics.com/gear?a=1&b=2"},
+		// Default ports are dropped, others are kept (and the host isn't folded)
+		{rev, "https://www.revrobotics.com:443/x", "https://www.revrobotics.com/x"},
+		{rev, "http://revrobotics.com:80/x", "https://www.revrobotics.com/x"},
+		{rev, "http://revrobotics.com:8080/x", "https://revrobotics.com:8080/x"},
+		// Anything which isn't an absolute http(s) URL is left alone
+		{rev, " /relative/Path/ ", "/relative/Path/"},
+		{rev, "mailto:sales@revrobotics.com", "mailto:sales@revrobotics.com"},
+		{nil, "http://revrobotics.com/Gears/", "https://revrobotics.com/Gears"},
+	}
+	for _, test := range tests {
+		if got := test.c.Canonical(test.raw); got != test.want {
+			t.Errorf("Canonical(%q) = %q, expected %q", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	strip := &Canonicalizer{Host: "www.servocity.com", TrailingSlash: true, StripQuery: true, SignificantParams: []string{"sku"}}
+	keep := &Canonicalizer{Host: "www.servocity.com", TrailingSlash: true}
+	tests := []struct {
+		c    *Canonicalizer
+		raw  string
+		want string
+	}{
+		{strip, "https://www.servocity.com/gear?sku=123&color=red&utm_medium=x", "https://www.servocity.com/gear/?sku=123"},
+		{strip, "https://www.servocity.com/gear?SKU=123", "https://www.servocity.com/gear/?SKU=123"},
+		{strip, "https://www.servocity.com/gear?color=red", "https://www.servocity.com/gear/"},
+		{keep, "https://www.servocity.com/gear?sku=123&color=red&utm_medium=x", "https://www.servocity.com/gear/?color=red&sku=123"},
+	}
+	for _, test := range tests {
+		if got := test.c.Key(test.raw); got != test.want {
+			t.Errorf("Key(%q) = %q, expected %q", test.raw, got, test.want)
+		}
+	}
+	// The canonical form always keeps the parameters
+	if got := strip.Canonical("https://www.servocity.com/gear?sku=123&color=red"); got != "https://www.servocity.com/gear/?color=red&sku=123" {
+		t.Errorf("Canonical with StripQuery = %q", got)
+	}
+}
+
+func TestSame(t *testing.T) {
+	c := &Canonicalizer{Host: "www.revrobotics.com"}
+	if !c.Same("http://revrobotics.com/Gear/", "https://www.revrobotics.com/gear") {
+		t.Error("Same should ignore the scheme, host form, trailing slash and case")
+	}
+	if c.Same("https://www.revrobotics.com/gear", "https://www.revrobotics.com/gears") {
+		t.Error("Same matched different pages")
+	}
+	for raw, want := range map[string]bool{
+		"https://revrobotics.com/x":     true,
+		"http://WWW.REVROBOTICS.COM/":   true,
+		"https://docs.revrobotics.com/": false,
+		"/relative":                     false,
+	} {
+		if got := c.SameHost(raw); got != want {
+			t.Errorf("SameHost(%q) = %v, expected %v", raw, got, want)
+		}
+	}
+}
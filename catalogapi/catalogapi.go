@@ -0,0 +1,272 @@
+package catalogapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// The sources of parts which can be queried
+const (
+	CatalogSource = "catalog" // The reference catalog from the spreadsheet
+	RunSource     = "run"     // The output of the most recent spider run
+)
+
+// DefaultLimit is the page size when a listing doesn't ask for one, MaxLimit is the most we will return at once
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// source is one set of parts along with the indexes for looking them up
+type source struct {
+	parts  []*partcatalog.PartData
+	sku    map[string]*partcatalog.PartData
+	url    map[string]*partcatalog.PartData
+	loaded time.Time
+}
+
+// Server answers read-only queries about the catalog and the latest run
+type Server struct {
+	mu      sync.RWMutex
+	sources map[string]*source
+	mux     *http.ServeMux
+}
+
+// PartList is the result of listing parts
+type PartList struct {
+	Total  int                     `json:"total"`
+	Offset int                     `json:"offset"`
+	Limit  int                     `json:"limit"`
+	Parts  []*partcatalog.PartData `json:"parts"`
+}
+
+// SectionCount is the number of parts in a section
+type SectionCount struct {
+	Section string `json:"section"`
+	Count   int    `json:"count"`
+}
+
+// Health reports what the server currently has loaded
+type Health struct {
+	Status  string                 `json:"status"`
+	Sources map[string]SourceState `json:"sources"`
+}
+
+// SourceState is the size and age of one source of parts
+type SourceState struct {
+	Parts  int       `json:"parts"`
+	Loaded time.Time `json:"loaded"`
+}
+
+// New creates an API server with nothing loaded
+func New() *Server {
+	s := &Server{sources: make(map[string]*source), mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /health", s.handleHealth)
+	s.mux.HandleFunc("GET /api/{source}/parts", s.handleList)
+	s.mux.HandleFunc("GET /api/{source}/parts/sku/{sku}", s.handleSKU)
+	s.mux.HandleFunc("GET /api/{source}/parts/url", s.handleURL)
+	s.mux.HandleFunc("GET /api/{source}/sections", s.handleSections)
+	return s
+}
+
+// Update replaces the parts for a source.  This is safe to call while serving
+func (s *Server) Update(name string, parts []*partcatalog.PartData) {
+	src := &source{
+		parts:  parts,
+		sku:    make(map[string]*partcatalog.PartData),
+		url:    make(map[string]*partcatalog.PartData),
+		loaded: time.Now(),
+	}
+	for _, partData := range parts {
+		// Keep the first one we see, just like the catalog does for duplicates
+		if _, found := src.sku[partData.SKU]; !found && partData.SKU != "" {
+			src.sku[partData.SKU] = partData
+		}
+		if _, found := src.url[partData.URL]; !found && partData.URL != "" {
+			src.url[partData.URL] = partData
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources[name] = src
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// getSource finds the source named in the request, reporting a 404 when it isn't loaded
+func (s *Server) getSource(w http.ResponseWriter, r *http.Request) *source {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	src, found := s.sources[r.PathValue("source")]
+	if !found {
+		writeError(w, http.StatusNotFound, "unknown source '"+r.PathValue("source")+"'")
+		return nil
+	}
+	return src
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	health := Health{Status: "ok", Sources: make(map[string]SourceState)}
+	for name, src := range s.sources {
+		health.Sources[name] = SourceState{Parts: len(src.parts), Loaded: src.loaded}
+	}
+	s.mu.RUnlock()
+	// The health check should never be answered from a cache
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, health)
+}
+
+// handleList lists the parts, filtered by section, spider status and model status, a page at a time
+//
+//	GET /api/run/parts?section=MOTION&status=Changed&modelStatus=Done&offset=0&limit=100
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	src := s.getSource(w, r)
+	if src == nil {
+		return
+	}
+	query := r.URL.Query()
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must be a non-negative number")
+		return
+	}
+	limit, err := queryInt(query.Get("limit"), DefaultLimit)
+	if err != nil || limit <= 0 {
+		writeError(w, http.StatusBadRequest, "limit must be a positive number")
+		return
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	var spiderStatus *partcatalog.SpiderStatus
+	if val := query.Get("status"); val != "" {
+		parsed, ok := partcatalog.ParseSpiderStatus(val)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "unknown status '"+val+"'")
+			return
+		}
+		spiderStatus = &parsed
+	}
+	section := query.Get("section")
+	modelStatus := query.Get("modelStatus")
+
+	result := PartList{Offset: offset, Limit: limit, Parts: []*partcatalog.PartData{}}
+	for _, partData := range src.parts {
+		if section != "" && partData.Section != section && !strings.HasPrefix(partData.Section, section+" > ") {
+			continue
+		}
+		if spiderStatus != nil && partData.SpiderStatus != *spiderStatus {
+			continue
+		}
+		if modelStatus != "" && !strings.EqualFold(partData.Status, modelStatus) {
+			continue
+		}
+		if result.Total >= offset && len(result.Parts) < limit {
+			result.Parts = append(result.Parts, partData)
+		}
+		result.Total++
+	}
+	writeJSON(w, r, result)
+}
+
+// handleSKU looks up a single part by SKU
+func (s *Server) handleSKU(w http.ResponseWriter, r *http.Request) {
+	src := s.getSource(w, r)
+	if src == nil {
+		return
+	}
+	partData, found := src.sku[r.PathValue("sku")]
+	if !found {
+		writeError(w, http.StatusNotFound, "no part with SKU '"+r.PathValue("sku")+"'")
+		return
+	}
+	writeJSON(w, r, partData)
+}
+
+// handleURL looks up a single part by the vendor URL given in the url parameter
+func (s *Server) handleURL(w http.ResponseWriter, r *http.Request) {
+	src := s.getSource(w, r)
+	if src == nil {
+		return
+	}
+	target := r.URL.Query().Get("url")
+	if target == "" {
+		writeError(w, http.StatusBadRequest, "url parameter is required")
+		return
+	}
+	partData, found := src.url[target]
+	if !found {
+		writeError(w, http.StatusNotFound, "no part with URL '"+target+"'")
+		return
+	}
+	writeJSON(w, r, partData)
+}
+
+// handleSections lists every section along with how many parts are in it
+func (s *Server) handleSections(w http.ResponseWriter, r *http.Request) {
+	src := s.getSource(w, r)
+	if src == nil {
+		return
+	}
+	counts := make(map[string]int)
+	for _, partData := range src.parts {
+		counts[partData.Section]++
+	}
+	result := make([]SectionCount, 0, len(counts))
+	for section, count := range counts {
+		result = append(result, SectionCount{section, count})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Section < result[j].Section })
+	writeJSON(w, r, result)
+}
+
+func queryInt(val string, def int) (int, error) {
+	if val == "" {
+		return def, nil
+	}
+	return strconv.Atoi(val)
+}
+
+// writeJSON sends the result with an ETag made from the body so that clients
+// can send If-None-Match and get a 304 when nothing has changed
+func writeJSON(w http.ResponseWriter, r *http.Request, result any) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		slog.Error("unable to encode response", "phase", "serve", "path", r.URL.Path, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,140 @@
+package catalogapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+func testParts() []*partcatalog.PartData {
+	return []*partcatalog.PartData{
+		{Section: "MOTION > Gears", Name: "Spur Gear", SKU: "G-1", URL: "https://www.example.com/gear", Status: "Done", SpiderStatus: partcatalog.UnchangedPart},
+		{Section: "MOTION > Shafts", Name: "Steel Shaft", SKU: "S-1", URL: "https://www.example.com/shaft", Status: "Bundle", SpiderStatus: partcatalog.PartChanged},
+		{Section: "MOTIONLESS", Name: "Bracket", SKU: "B-1", URL: "https://www.example.com/bracket", SpiderStatus: partcatalog.NewPart},
+		{Section: "STRUCTURE", Name: "Channel", SKU: "C-1", URL: "https://www.example.com/channel", Status: "Done", SpiderStatus: partcatalog.NewPart},
+	}
+}
+
+func get(t *testing.T, s *Server, target string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestList(t *testing.T) {
+	s := New()
+	s.Update(RunSource, testParts())
+	tests := []struct {
+		query string
+		total int
+		skus  []string
+	}{
+		{"", 4, []string{"G-1", "S-1", "B-1", "C-1"}},
+		// A section includes the ones underneath it, but not ones which just start with the same name
+		{"section=MOTION", 2, []string{"G-1", "S-1"}},
+		{"status=changed", 1, []string{"S-1"}},
+		{"modelStatus=done", 2, []string{"G-1", "C-1"}},
+		{"status=New&section=STRUCTURE", 1, []string{"C-1"}},
+		// The total counts everything which matches, not just the page
+		{"offset=1&limit=2", 4, []string{"S-1", "B-1"}},
+		{"offset=10", 4, []string{}},
+	}
+	for _, test := range tests {
+		w := get(t, s, "/api/run/parts?"+test.query, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d", test.query, w.Code)
+			continue
+		}
+		var result PartList
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		skus := []string{}
+		for _, partData := range result.Parts {
+			skus = append(skus, partData.SKU)
+		}
+		if result.Total != test.total || !reflect.DeepEqual(skus, test.skus) {
+			t.Errorf("%s: got %d %v, expected %d %v", test.query, result.Total, skus, test.total, test.skus)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := New()
+	s.Update(RunSource, testParts())
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/api/run/parts/sku/G-1", http.StatusOK},
+		{"/api/run/parts/url?url=" + url.QueryEscape("https://www.example.com/shaft"), http.StatusOK},
+		{"/api/run/sections", http.StatusOK},
+		// The catalog hasn't been loaded
+		{"/api/catalog/parts", http.StatusNotFound},
+		{"/api/run/parts/sku/X-1", http.StatusNotFound},
+		{"/api/run/parts/url?url=" + url.QueryEscape("https://www.example.com/missing"), http.StatusNotFound},
+		{"/api/run/parts/url", http.StatusBadRequest},
+		{"/api/run/parts?status=Lost", http.StatusBadRequest},
+		{"/api/run/parts?limit=0", http.StatusBadRequest},
+		{"/api/run/parts?offset=-1", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := get(t, s, test.target, nil)
+		if w.Code != test.status {
+			t.Errorf("GET %s = %d, expected %d", test.target, w.Code, test.status)
+		}
+		if test.status != http.StatusOK {
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] == "" {
+				t.Errorf("GET %s gave %q rather than an error", test.target, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestETag(t *testing.T) {
+	s := New()
+	s.Update(RunSource, testParts())
+	w := get(t, s, "/api/run/sections", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("got %d with ETag %q", w.Code, etag)
+	}
+	var sections []SectionCount
+	if err := json.Unmarshal(w.Body.Bytes(), &sections); err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 4 || sections[0] != (SectionCount{"MOTION > Gears", 1}) {
+		t.Errorf("sections = %v", sections)
+	}
+
+	for _, match := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		w = get(t, s, "/api/run/sections", http.Header{"If-None-Match": {match}})
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s gave %d", match, w.Code)
+		}
+	}
+
+	// Once the parts change, the old ETag no longer matches
+	s.Update(RunSource, testParts()[:2])
+	w = get(t, s, "/api/run/sections", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("after an update got %d with ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	// The health check is never cached
+	w = get(t, s, "/health", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("health gave %d with Cache-Control %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// writeChangeSet saves the edits proposed by a run for review
+func writeChangeSet(path string, changes []partcatalog.SheetChange) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return partcatalog.WriteChangeSetCSV(f, changes)
+}
+
+// applyChangesCommand makes the edits from a reviewed change set to a catalog snapshot
+//
+//	ftc_parts_spider apply-changes [-out updated.json] rev-changes.csv rev-catalog-<time>.json
+func applyChangesCommand(args []string) int {
+	fs := flag.NewFlagSet("apply-changes", flag.ExitOnError)
+	out := fs.String("out", "", "File for the updated catalog (defaults to the snapshot name with -updated added)")
+	logging := loggingFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s apply-changes [flags] <change set> <catalog snapshot>\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := logging("command", fs.Name()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	changesPath, snapshotPath := fs.Arg(0), fs.Arg(1)
+	if *out == "" {
+		*out = strings.TrimSuffix(snapshotPath, filepath.Ext(snapshotPath)) + "-updated.json"
+	}
+
+	f, err := os.Open(changesPath)
+	if err != nil {
+		slog.Error("unable to open change set", "phase", "load", "path", changesPath, "err", err)
+		return 1
+	}
+	changes, err := partcatalog.ReadChangeSetCSV(f)
+	f.Close()
+	if err != nil {
+		slog.Error("unable to read change set", "phase", "load", "path", changesPath, "err", err)
+		return 1
+	}
+	snapshot, err := partcatalog.LoadSnapshot(snapshotPath)
+	if err != nil {
+		slog.Error("unable to load catalog snapshot", "phase", "load", "path", snapshotPath, "err", err)
+		return 1
+	}
+
+	applied, skipped := partcatalog.ApplyChangeSet(snapshot, changes)
+	for _, reason := range skipped {
+		slog.Warn("skipped change", "phase", "report", "reason", reason)
+	}
+	if err := snapshot.WriteJSON(*out); err != nil {
+		slog.Error("unable to write the updated catalog", "phase", "report", "path", *out, "err", err)
+		return 1
+	}
+	slog.Info("applied change set", "phase", "report", "path", *out, "applied", applied, "skipped", len(skipped))
+	if len(skipped) > 0 {
+		return 1
+	}
+	return 0
+}
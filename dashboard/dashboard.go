@@ -0,0 +1,317 @@
+package dashboard
+
+import (
+	"embed"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+	"github.com/toebes/ftc_parts_spider/thumbnails"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// Run is one spider output file loaded for browsing
+type Run struct {
+	Name  string
+	Parts []*partcatalog.PartData
+}
+
+// Server serves the web UI for looking through one or more runs
+type Server struct {
+	runs []Run
+	tmpl *template.Template
+	mux  *http.ServeMux
+	// thumbs are the files in the thumbnail archive
+	thumbs map[string]bool
+}
+
+// SectionNode is one level of the section breadcrumbs with the number of parts under it
+type SectionNode struct {
+	Name     string
+	Path     string
+	Link     string
+	Count    int
+	Selected bool
+	Children []*SectionNode
+}
+
+// statusFilter is one of the choices in the status filter
+type statusFilter struct {
+	Name     string
+	Link     string
+	Count    int
+	Selected bool
+}
+
+// partRow is a part along with where it lives so that the detail page can be linked
+type partRow struct {
+	Index int
+	*partcatalog.PartData
+}
+
+// comparison is one line of the side by side view
+type comparison struct {
+	Field   string
+	Old     string
+	New     string
+	Changed bool
+}
+
+// New creates a server for the runs.  imageDir is the thumbnail archive, which may be empty if there isn't one
+func New(runs []Run, imageDir string) (*Server, error) {
+	s := &Server{runs: runs, mux: http.NewServeMux(), thumbs: make(map[string]bool)}
+	if imageDir != "" {
+		entries, err := os.ReadDir(imageDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			s.thumbs[entry.Name()] = true
+		}
+		s.mux.Handle("/thumbs/", http.StripPrefix("/thumbs/", http.FileServer(http.Dir(imageDir))))
+	}
+	funcs := template.FuncMap{
+		"thumb":  s.thumb,
+		"inc":    func(i int) int { return i + 1 },
+		"status": func(status partcatalog.SpiderStatus) string { return status.String() },
+		"statusClass": func(status partcatalog.SpiderStatus) string {
+			return strings.ToLower(strings.ReplaceAll(status.String(), " ", "-"))
+		},
+	}
+	tmpl, err := template.New("dashboard").Funcs(funcs).ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	s.tmpl = tmpl
+	s.mux.HandleFunc("/", s.handleIndex)
+	s.mux.HandleFunc("/part", s.handlePart)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// selectedRun figures out which run the request is asking about, defaulting to the first
+func (s *Server) selectedRun(r *http.Request) (int, bool) {
+	if len(s.runs) == 0 {
+		return 0, false
+	}
+	runIdx := 0
+	if val := r.URL.Query().Get("run"); val != "" {
+		idx, err := strconv.Atoi(val)
+		if err != nil || idx < 0 || idx >= len(s.runs) {
+			return 0, false
+		}
+		runIdx = idx
+	}
+	return runIdx, true
+}
+
+// handleIndex shows the list of parts for a run with the filters applied
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	runIdx, ok := s.selectedRun(r)
+	if !ok {
+		http.Error(w, "Unknown run", http.StatusNotFound)
+		return
+	}
+	run := s.runs[runIdx]
+	query := r.URL.Query()
+	status := query.Get("status")
+	section := query.Get("section")
+	search := strings.TrimSpace(query.Get("q"))
+
+	// The section tree and the status counts both honor the search, but not each other's filter
+	// so that you can always see where else the parts are
+	counts := make(map[string]int)
+	root := &SectionNode{}
+	var parts []partRow
+	for idx, partData := range run.Parts {
+		if !matchesSearch(partData, search) {
+			continue
+		}
+		if sectionMatches(partData.Section, section) {
+			counts[partData.SpiderStatus.String()]++
+		}
+		if status == "" || partData.SpiderStatus.String() == status {
+			addToTree(root, partData.Section, section, filterLink(runIdx, status, "", search))
+			if sectionMatches(partData.Section, section) {
+				parts = append(parts, partRow{idx, partData})
+			}
+		}
+	}
+	sortTree(root)
+
+	var statuses []statusFilter
+	for status := partcatalog.NewPart; status <= partcatalog.UnchangedPart; status++ {
+		statuses = append(statuses, statusFilter{
+			Name:  status.String(),
+			Link:  filterLink(runIdx, status.String(), section, search),
+			Count: counts[status.String()],
+		})
+	}
+	for i := range statuses {
+		statuses[i].Selected = statuses[i].Name == status
+	}
+
+	s.render(w, "index.html", map[string]any{
+		"Runs":     s.runs,
+		"RunIdx":   runIdx,
+		"Run":      run,
+		"Status":   status,
+		"Statuses": statuses,
+		"Section":  section,
+		"Search":   search,
+		"Tree":     root.Children,
+		"Parts":    parts,
+		"AllLink":  filterLink(runIdx, "", section, search),
+	})
+}
+
+// handlePart shows one part.  For a changed part the catalog and website values are side by side
+func (s *Server) handlePart(w http.ResponseWriter, r *http.Request) {
+	runIdx, ok := s.selectedRun(r)
+	if !ok {
+		http.Error(w, "Unknown run", http.StatusNotFound)
+		return
+	}
+	run := s.runs[runIdx]
+	idx, err := strconv.Atoi(r.URL.Query().Get("i"))
+	if err != nil || idx < 0 || idx >= len(run.Parts) {
+		http.Error(w, "Unknown part", http.StatusNotFound)
+		return
+	}
+	partData := run.Parts[idx]
+
+	changed := make(map[string]partcatalog.FieldChange)
+	for _, change := range partData.Changes() {
+		changed[change.Field] = change
+	}
+	var rows []comparison
+	for _, field := range []struct{ name, value string }{
+		{"Name", partData.Name},
+		{"Section", partData.Section},
+		{"SKU", partData.SKU},
+		{"URL", partData.URL},
+	} {
+		row := comparison{Field: field.name, Old: field.value, New: field.value}
+		if change, found := changed[field.name]; found {
+			row.Old, row.New, row.Changed = change.Old, change.New, true
+		}
+		rows = append(rows, row)
+	}
+
+	s.render(w, "part.html", map[string]any{
+		"RunIdx":      runIdx,
+		"Run":         run,
+		"Part":        partData,
+		"Comparisons": rows,
+	})
+}
+
+// thumb gives the picture to show for one of the images of a part.  We use the archived thumbnail
+// when there is one and fall back to the picture on the vendor website
+func (s *Server) thumb(partData *partcatalog.PartData, index int) string {
+	if name := thumbnails.FileName(partData.SKU, index); partData.SKU != "" && s.thumbs[name] {
+		return "/thumbs/" + url.PathEscape(name)
+	}
+	if index < len(partData.Images) {
+		return partData.Images[index]
+	}
+	return ""
+}
+
+func (s *Server) render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.tmpl.ExecuteTemplate(w, name, data); err != nil {
+		slog.Error("unable to render page", "phase", "serve", "template", name, "err", err)
+	}
+}
+
+// matchesSearch does a case insensitive search of the name and SKU
+func matchesSearch(partData *partcatalog.PartData, search string) bool {
+	if search == "" {
+		return true
+	}
+	search = strings.ToLower(search)
+	return strings.Contains(strings.ToLower(partData.Name), search) ||
+		strings.Contains(strings.ToLower(partData.SKU), search)
+}
+
+// sectionMatches checks whether a section is the selected one or underneath it
+func sectionMatches(section string, selected string) bool {
+	if strings.TrimSpace(section) == "" {
+		section = "(none)"
+	}
+	return selected == "" || section == selected || strings.HasPrefix(section, selected+" > ")
+}
+
+// filterLink builds the query string for a page of the list with the given filters
+func filterLink(runIdx int, status string, section string, search string) string {
+	values := url.Values{}
+	values.Set("run", strconv.Itoa(runIdx))
+	if status != "" {
+		values.Set("status", status)
+	}
+	if section != "" {
+		values.Set("section", section)
+	}
+	if search != "" {
+		values.Set("q", search)
+	}
+	return "/?" + values.Encode()
+}
+
+// addToTree counts a part at every level of its section breadcrumbs.
+// The link for each level is the base link with the section appended
+func addToTree(root *SectionNode, section string, selected string, base string) {
+	node := root
+	path := ""
+	for _, name := range strings.Split(section, " > ") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = "(none)"
+		}
+		if path != "" {
+			path += " > "
+		}
+		path += name
+		var child *SectionNode
+		for _, existing := range node.Children {
+			if existing.Name == name {
+				child = existing
+				break
+			}
+		}
+		if child == nil {
+			child = &SectionNode{
+				Name:     name,
+				Path:     path,
+				Link:     base + "&section=" + url.QueryEscape(path),
+				Selected: path == selected,
+			}
+			node.Children = append(node.Children, child)
+		}
+		child.Count++
+		node = child
+	}
+}
+
+func sortTree(node *SectionNode) {
+	sort.Slice(node.Children, func(i, j int) bool { return node.Children[i].Name < node.Children[j].Name })
+	for _, child := range node.Children {
+		sortTree(child)
+	}
+}
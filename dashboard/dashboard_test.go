@@ -0,0 +1,88 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+func testServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := New([]Run{{Name: "latest", Parts: []*partcatalog.PartData{
+		{Section: "MOTION > Gears", Name: "Spur Gear", SKU: "G-1", SpiderStatus: partcatalog.UnchangedPart},
+		{Section: "MOTION > Shafts", Name: "Steel Shaft", SKU: "S-1", SpiderStatus: partcatalog.PartChanged,
+			Notes: "Old SKU:S-0"},
+		{Section: "MOTIONLESS", Name: "Corner Bracket", SKU: "B-1", SpiderStatus: partcatalog.NewPart},
+		{Section: "STRUCTURE", Name: "Aluminum Channel", SKU: "C-1", SpiderStatus: partcatalog.NewPart},
+	}}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func get(s *Server, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestIndexFilters(t *testing.T) {
+	s := testServer(t)
+	names := []string{"Spur Gear", "Steel Shaft", "Corner Bracket", "Aluminum Channel"}
+	tests := []struct {
+		query string
+		shown []string
+	}{
+		{"", names},
+		{"status=Changed", []string{"Steel Shaft"}},
+		// A section includes the ones underneath it, but not ones which just start with the same name
+		{"section=" + url.QueryEscape("MOTION"), []string{"Spur Gear", "Steel Shaft"}},
+		{"section=" + url.QueryEscape("MOTION > Gears"), []string{"Spur Gear"}},
+		// The search is case insensitive and looks at the SKU too
+		{"q=c-1", []string{"Aluminum Channel"}},
+		{"q=steel&status=Same", nil},
+	}
+	for _, test := range tests {
+		w := get(s, "/?"+test.query)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d", test.query, w.Code)
+			continue
+		}
+		body := w.Body.String()
+		for _, name := range names {
+			want := false
+			for _, shown := range test.shown {
+				want = want || shown == name
+			}
+			if strings.Contains(body, name) != want {
+				t.Errorf("%s: showing %s is %v, expected %v", test.query, name, !want, want)
+			}
+		}
+	}
+}
+
+func TestPart(t *testing.T) {
+	s := testServer(t)
+	w := get(s, "/part?i=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	// The changed SKU is shown with the catalog value next to the website one
+	if body := w.Body.String(); !strings.Contains(body, `<tr class="diff">`) || !strings.Contains(body, "S-0") {
+		t.Errorf("the SKU change isn't shown:\n%s", body)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	s := testServer(t)
+	for _, target := range []string{"/missing", "/?run=1", "/?run=x", "/part?i=4", "/part?i=-1", "/part", "/part?run=2&i=0", "/thumbs/G-1.jpg"} {
+		if w := get(s, target); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, expected %d", target, w.Code, http.StatusNotFound)
+		}
+	}
+}
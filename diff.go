@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// diffCatalogCommand compares two catalog snapshots saved by spider runs, listing the rows the curators
+// added, removed and edited in between
+//
+//	ftc_parts_spider diff-catalog [-out changes.txt] rev-catalog-<older>.json rev-catalog-<newer>.json
+func diffCatalogCommand(args []string) int {
+	fs := flag.NewFlagSet("diff-catalog", flag.ExitOnError)
+	out := fs.String("out", "", "File for the differences (defaults to the standard output)")
+	logging := loggingFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s diff-catalog [flags] <older snapshot> <newer snapshot>\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := logging("command", fs.Name()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	var snapshots [2]*partcatalog.Snapshot
+	for i, path := range fs.Args() {
+		snapshot, err := partcatalog.LoadSnapshot(path)
+		if err != nil {
+			slog.Error("unable to load catalog snapshot", "phase", "load", "path", path, "err", err)
+			return 1
+		}
+		snapshots[i] = snapshot
+	}
+	if snapshots[0].SpreadsheetID != snapshots[1].SpreadsheetID {
+		slog.Warn("the snapshots are of different spreadsheets", "phase", "report", "older", snapshots[0].SpreadsheetID, "newer", snapshots[1].SpreadsheetID)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			slog.Error("unable to create output file", "phase", "report", "path", *out, "err", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	changes := partcatalog.DiffSnapshots(snapshots[0], snapshots[1])
+	if err := writeCatalogChanges(w, changes); err != nil {
+		slog.Error("unable to write the differences", "phase", "report", "path", *out, "err", err)
+		return 1
+	}
+	slog.Info("compared catalog snapshots", "phase", "report", "older", snapshots[0].Taken, "newer", snapshots[1].Taken, "changes", len(changes))
+	return 0
+}
+
+// writeCatalogChanges writes the differences as a backtick separated file with one line for each edited
+// field (or for each added or removed row), giving the row in the newer catalog where there is one
+func writeCatalogChanges(w io.Writer, changes []partcatalog.SnapshotChange) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "Change`Row`Old Row`Part #`Name`Field`Old`New")
+	for _, change := range changes {
+		part := change.Part()
+		row, oldRow := "", ""
+		if change.New != nil {
+			row = fmt.Sprint(change.New.Row)
+		}
+		if change.Old != nil {
+			oldRow = fmt.Sprint(change.Old.Row)
+		}
+		if len(change.Fields) == 0 {
+			fmt.Fprintf(bw, "%s`%s`%s`%s`%s```\n", change.Kind, row, oldRow, part.SKU, part.Name)
+		}
+		for _, field := range change.Fields {
+			fmt.Fprintf(bw, "%s`%s`%s`%s`%s`%s`%s`%s\n", change.Kind, row, oldRow, part.SKU, part.Name, field.Field, field.Old, field.New)
+		}
+	}
+	return bw.Flush()
+}
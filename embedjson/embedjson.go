@@ -0,0 +1,315 @@
+// Package embedjson finds the JSON which vendor pages carry around for their JavaScript: in
+// <script> blocks (JSON-LD or application/json), as the arguments of calls such as
+//
+//	window.stencilBootstrap("category", "{\"subcategories\":[{\"url\":\"https://...\"}]}").load();
+//
+// in assignments such as var BCData = {...}; and in data attributes such as data-analytics.  JSON
+// which has been escaped into a JavaScript string is unescaped and parsed as well.
+//
+// The parsed Value is queried with a path:
+//
+//	payload.sku              the sku key of the payload object
+//	subcategories[0].url     the url of the first subcategory
+//	subcategories[*].url     the url of every subcategory (.* does the same)
+//	..availability           every availability key at any depth
+//	["data-id"]              a key which isn't a plain name
+package embedjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Value is a parsed JSON value.  The zero Value is missing, so queries which don't match anything
+// can be chained without checking every step
+type Value struct {
+	data    interface{}
+	present bool
+}
+
+// Parse decodes JSON.  Anything after the first value (such as a trailing ; or .load()) is ignored, and
+// a JavaScript string literal is unescaped first, so both {"a":1} and "{\"a\":1}" give the same Value.
+// A string literal which doesn't hold an object or array is returned as a string
+func Parse(text string) (Value, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Value{}, errors.New("no JSON found")
+	}
+	switch text[0] {
+	case '"', '\'', '`':
+		end := skipString(text, 0)
+		if end < 0 {
+			return Value{}, errors.New("unterminated string")
+		}
+		str, err := Unquote(text[:end])
+		if err != nil {
+			return Value{}, err
+		}
+		if inner := strings.TrimSpace(str); inner != "" && (inner[0] == '{' || inner[0] == '[') {
+			if value, err := Parse(inner); err == nil {
+				return value, nil
+			}
+		}
+		return Value{data: str, present: true}, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		return Value{}, err
+	}
+	return Value{data: data, present: true}, nil
+}
+
+// Unquote turns a JavaScript string literal (in single, double or back quotes) into the string it
+// represents, handling the \x, \u and \u{...} escapes as well as the usual ones
+func Unquote(literal string) (string, error) {
+	if len(literal) < 2 || literal[len(literal)-1] != literal[0] || !strings.ContainsRune("\"'`", rune(literal[0])) {
+		return "", fmt.Errorf("not a string literal: %.20q", literal)
+	}
+	body := literal[1 : len(literal)-1]
+	var sb strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(body) {
+			return "", errors.New("string ends with a backslash")
+		}
+		switch c = body[i]; c {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'v':
+			sb.WriteByte('\v')
+		case '0':
+			sb.WriteByte(0)
+		case '\n':
+			// A line continuation
+		case 'x':
+			if i+3 > len(body) {
+				return "", errors.New("short \\x escape")
+			}
+			n, err := strconv.ParseUint(body[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("bad \\x escape: %w", err)
+			}
+			sb.WriteRune(rune(n))
+			i += 2
+		case 'u':
+			r, size, err := unicodeEscape(body[i+1:])
+			if err != nil {
+				return "", err
+			}
+			i += size
+			if utf16.IsSurrogate(r) && strings.HasPrefix(body[i+1:], "\\u") {
+				// Characters outside the basic plane come as a pair of escapes
+				if low, lowSize, err := unicodeEscape(body[i+3:]); err == nil {
+					if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+						r = pair
+						i += 2 + lowSize
+					}
+				}
+			}
+			sb.WriteRune(r)
+		default:
+			// \" \' \\ \/ and anything else just mean the character itself
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+// unicodeEscape decodes what follows a \u: either four hex digits or a code point in braces.
+// It returns the rune and how many bytes it used
+func unicodeEscape(s string) (r rune, size int, err error) {
+	digits := ""
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return 0, 0, errors.New("unterminated \\u{ escape")
+		}
+		digits, size = s[1:end], end+1
+	} else {
+		if len(s) < 4 {
+			return 0, 0, errors.New("short \\u escape")
+		}
+		digits, size = s[:4], 4
+	}
+	n, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad \\u escape: %w", err)
+	}
+	return rune(n), size, nil
+}
+
+// FromAttr parses the JSON held in an attribute such as data-analytics.  It returns false when the
+// attribute is missing or doesn't hold JSON
+func FromAttr(sel *goquery.Selection, name string) (Value, bool) {
+	text, found := sel.Attr(name)
+	if !found {
+		return Value{}, false
+	}
+	value, err := Parse(text)
+	return value, err == nil
+}
+
+// FromScripts parses the <script> blocks under sel with one of the given types, such as
+// application/ld+json.  Blocks which don't parse are skipped
+func FromScripts(sel *goquery.Selection, types ...string) []Value {
+	var result []Value
+	sel.Find("script").Each(func(i int, script *goquery.Selection) {
+		kind, _ := script.Attr("type")
+		kind, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(kind)), ";")
+		for _, want := range types {
+			if kind == strings.ToLower(want) {
+				if value, err := Parse(script.Text()); err == nil {
+					result = append(result, value)
+				}
+				break
+			}
+		}
+	})
+	return result
+}
+
+// FromCall finds the first call to fn (e.g. window.stencilBootstrap) in a script and parses its
+// arguments.  An argument which isn't a literal (such as a variable) is returned as a missing Value
+// so that the positions of the others are kept
+func FromCall(script string, fn string) ([]Value, bool) {
+	for pos := findName(script, fn, 0); pos >= 0; pos = findName(script, fn, pos+len(fn)) {
+		i := skipSpace(script, pos+len(fn))
+		if i >= len(script) || script[i] != '(' {
+			continue
+		}
+		var args []Value
+		i = skipSpace(script, i+1)
+		for i < len(script) && script[i] != ')' {
+			end := scanExpr(script, i, ",)")
+			if end < 0 {
+				return nil, false
+			}
+			value, _ := Parse(script[i:end])
+			args = append(args, value)
+			i = skipSpace(script, end)
+			if i < len(script) && script[i] == ',' {
+				i = skipSpace(script, i+1)
+			} else if i < len(script) && script[i] != ')' {
+				// A stray bracket means this isn't really a call
+				return nil, false
+			}
+		}
+		return args, true
+	}
+	return nil, false
+}
+
+// FromAssignment finds the value assigned to a name in a script, such as var BCData = {...};
+func FromAssignment(script string, name string) (Value, bool) {
+	for pos := findName(script, name, 0); pos >= 0; pos = findName(script, name, pos+len(name)) {
+		i := skipSpace(script, pos+len(name))
+		if i+1 >= len(script) || script[i] != '=' || script[i+1] == '=' {
+			continue
+		}
+		i = skipSpace(script, i+1)
+		end := scanExpr(script, i, ";,\n")
+		if end < 0 {
+			end = len(script)
+		}
+		if value, err := Parse(script[i:end]); err == nil {
+			return value, true
+		}
+	}
+	return Value{}, false
+}
+
+// findName finds the next place a name appears in a script which isn't just the end of a longer name
+func findName(script string, name string, from int) int {
+	for from <= len(script) {
+		pos := strings.Index(script[from:], name)
+		if pos < 0 {
+			return -1
+		}
+		pos += from
+		if pos == 0 || !isIdentChar(script[pos-1]) {
+			return pos
+		}
+		from = pos + 1
+	}
+	return -1
+}
+
+// isIdentChar checks whether a byte can be part of a JavaScript name
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// skipSpace returns the position of the next character which isn't white space
+func skipSpace(s string, i int) int {
+	for i < len(s) && strings.IndexByte(" \t\r\n", s[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// skipString returns the position just after the string literal starting at s[i], or -1 when it doesn't end
+func skipString(s string, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// scanExpr returns the end of the expression starting at s[i]: the first of the stop characters
+// outside of any brackets or strings, or an unmatched closing bracket.  It returns -1 when the
+// expression runs off the end of the script with brackets still open
+func scanExpr(s string, i int, stops string) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			end := skipString(s, j)
+			if end < 0 {
+				return -1
+			}
+			j = end - 1
+		case c == '{' || c == '[' || c == '(':
+			depth++
+		case c == '}' || c == ']' || c == ')':
+			if depth == 0 {
+				return j
+			}
+			depth--
+		case depth == 0 && strings.IndexByte(stops, c) >= 0:
+			return j
+		}
+	}
+	if depth == 0 {
+		return len(s)
+	}
+	return -1
+}
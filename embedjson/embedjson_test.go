@@ -0,0 +1,100 @@
+package embedjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestFromCallEscapedJSON(t *testing.T) {
+	script := `
+	// Exported in app.js
+	window.stencilBootstrap("category", "{\"categoryProductsPerPage\":50,\"subcategories\":[{\"url\":\"https:\/\/www.servocity.com\/channel\/\",\"name\":\"Channel \\u0026 Brackets\"},{\"url\":\"https:\/\/www.servocity.com\/gears\/\"}],\"template\":\"pages\/category\"}").load();
+	`
+	args, found := FromCall(script, "window.stencilBootstrap")
+	if !found || len(args) != 2 {
+		t.Fatalf("FromCall found=%v with %d arguments, expected 2", found, len(args))
+	}
+	if got := args[0].String(); got != "category" {
+		t.Errorf("first argument = %q, expected category", got)
+	}
+	want := []string{"https://www.servocity.com/channel/", "https://www.servocity.com/gears/"}
+	if got := args[1].Strings("subcategories[*].url"); !reflect.DeepEqual(got, want) {
+		t.Errorf("subcategories[*].url = %q, expected %q", got, want)
+	}
+	if got := args[1].Get("subcategories[0].name").String(); got != "Channel & Brackets" {
+		t.Errorf("subcategories[0].name = %q", got)
+	}
+	if got := args[1].Get("categoryProductsPerPage").String(); got != "50" {
+		t.Errorf("categoryProductsPerPage = %q, expected 50", got)
+	}
+	if _, found := FromCall(script, "stencilBootstrap"); !found {
+		t.Errorf("unable to find the call without the window. prefix")
+	}
+	if _, found := FromCall(script, "Bootstrap"); found {
+		t.Errorf("found the call from the end of a longer name")
+	}
+}
+
+func TestFromAssignment(t *testing.T) {
+	script := `var BCData = {"product_attributes":{"sku":"3100-0008-0001","stock":null}};
+	var other = 1;`
+	value, found := FromAssignment(script, "BCData")
+	if !found {
+		t.Fatal("BCData not found")
+	}
+	if got := value.Get("product_attributes.sku").String(); got != "3100-0008-0001" {
+		t.Errorf("sku = %q", got)
+	}
+	if value.Get("product_attributes.stock").Exists() == false {
+		t.Errorf("null stock should still exist")
+	}
+	if value.Get("product_attributes.missing.deeper").Exists() {
+		t.Errorf("missing key found")
+	}
+}
+
+func TestAttrAndScripts(t *testing.T) {
+	html := `<div id="p" data-analytics='{"event":"view","payload":{"name":"Spacer","id":12345}}'>
+	<script type="application/ld+json">{"@type":"Product","offers":[{"availability":"https://schema.org/InStock"},{"availability":"https://schema.org/Discontinued"}]}</script>
+	<script>var ignored = true;</script></div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, found := FromAttr(doc.Find("#p"), "data-analytics")
+	if !found {
+		t.Fatal("data-analytics not parsed")
+	}
+	if got := value.Get("payload.id").String(); got != "12345" {
+		t.Errorf("numeric id = %q, expected 12345", got)
+	}
+	scripts := FromScripts(doc.Selection, "application/ld+json")
+	if len(scripts) != 1 {
+		t.Fatalf("found %d JSON-LD scripts, expected 1", len(scripts))
+	}
+	want := []string{"https://schema.org/InStock", "https://schema.org/Discontinued"}
+	if got := scripts[0].Strings("..availability"); !reflect.DeepEqual(got, want) {
+		t.Errorf("..availability = %q, expected %q", got, want)
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	for _, test := range []struct{ in, want string }{
+		{`"a\"b"`, `a"b`},
+		{`'it\'s'`, `it's`},
+		{`"\x41B\u{43}"`, `ABC`},
+		{`"\uD83D\uDE00"`, "\U0001F600"},
+		{`"a\/b\\c"`, `a/b\c`},
+	} {
+		got, err := Unquote(test.in)
+		if err != nil || got != test.want {
+			t.Errorf("Unquote(%s) = %q, %v expected %q", test.in, got, err, test.want)
+		}
+	}
+	if _, err := Unquote(`"\x4"`); err == nil {
+		t.Errorf("short \\x escape accepted")
+	}
+}
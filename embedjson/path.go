@@ -0,0 +1,256 @@
+package embedjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stepKind says what a step of a path does
+type stepKind int
+
+const (
+	// stepKey - look up a key in an object
+	stepKey stepKind = iota
+	// stepIndex - pick an element out of an array (negative counts from the end)
+	stepIndex
+	// stepAll - every element of an array or every value of an object
+	stepAll
+	// stepDescend - a key anywhere under the current value
+	stepDescend
+)
+
+// step is one part of a parsed path
+type step struct {
+	kind  stepKind
+	key   string
+	index int
+}
+
+// parsePath splits a path such as items[*].options..sku into its steps
+func parsePath(path string) ([]step, error) {
+	var steps []step
+	i := 0
+	for i < len(path) {
+		switch {
+		case strings.HasPrefix(path[i:], ".."):
+			name, end := readName(path, i+2)
+			if name == "" {
+				return nil, fmt.Errorf("missing name after .. in %q", path)
+			}
+			steps = append(steps, step{kind: stepDescend, key: name})
+			i = end
+		case path[i] == '.':
+			name, end := readName(path, i+1)
+			if name == "" {
+				return nil, fmt.Errorf("missing name after . in %q", path)
+			}
+			steps = append(steps, nameStep(name))
+			i = end
+		case path[i] == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in %q", path)
+			}
+			inside := strings.TrimSpace(path[i+1 : i+end])
+			i += end + 1
+			switch {
+			case inside == "*":
+				steps = append(steps, step{kind: stepAll})
+			case len(inside) >= 2 && (inside[0] == '"' || inside[0] == '\'') && inside[len(inside)-1] == inside[0]:
+				steps = append(steps, step{kind: stepKey, key: inside[1 : len(inside)-1]})
+			default:
+				index, err := strconv.Atoi(inside)
+				if err != nil {
+					return nil, fmt.Errorf("bad index [%s] in %q", inside, path)
+				}
+				steps = append(steps, step{kind: stepIndex, index: index})
+			}
+		default:
+			name, end := readName(path, i)
+			steps = append(steps, nameStep(name))
+			i = end
+		}
+	}
+	return steps, nil
+}
+
+// readName reads a key up to the next . or [
+func readName(path string, i int) (name string, end int) {
+	end = i
+	for end < len(path) && path[end] != '.' && path[end] != '[' {
+		end++
+	}
+	return path[i:end], end
+}
+
+// nameStep turns a name into a key lookup, or every value for *
+func nameStep(name string) step {
+	if name == "*" {
+		return step{kind: stepAll}
+	}
+	return step{kind: stepKey, key: name}
+}
+
+// Query finds every value matching a path.  An empty path gives the value itself, and a path which
+// can't be parsed matches nothing
+func (v Value) Query(path string) []Value {
+	if !v.present {
+		return nil
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil
+	}
+	current := []interface{}{v.data}
+	for _, s := range steps {
+		var next []interface{}
+		for _, data := range current {
+			next = s.apply(data, next)
+		}
+		current = next
+	}
+	result := make([]Value, 0, len(current))
+	for _, data := range current {
+		result = append(result, Value{data: data, present: true})
+	}
+	return result
+}
+
+// apply adds whatever the step finds in data to result
+func (s step) apply(data interface{}, result []interface{}) []interface{} {
+	switch s.kind {
+	case stepKey:
+		if obj, ok := data.(map[string]interface{}); ok {
+			if value, found := obj[s.key]; found {
+				result = append(result, value)
+			}
+		}
+	case stepIndex:
+		if arr, ok := data.([]interface{}); ok {
+			index := s.index
+			if index < 0 {
+				index += len(arr)
+			}
+			if index >= 0 && index < len(arr) {
+				result = append(result, arr[index])
+			}
+		}
+	case stepAll:
+		result = append(result, children(data)...)
+	case stepDescend:
+		result = s.descend(data, result)
+	}
+	return result
+}
+
+// descend gathers the key from data and everything under it
+func (s step) descend(data interface{}, result []interface{}) []interface{} {
+	if obj, ok := data.(map[string]interface{}); ok {
+		if value, found := obj[s.key]; found {
+			result = append(result, value)
+		}
+	}
+	for _, child := range children(data) {
+		result = s.descend(child, result)
+	}
+	return result
+}
+
+// children returns the elements of an array or the values of an object (in key order, so that
+// queries always give the same answer)
+func children(data interface{}) []interface{} {
+	switch value := data.(type) {
+	case []interface{}:
+		return value
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		result := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			result = append(result, value[key])
+		}
+		return result
+	}
+	return nil
+}
+
+// Get returns the first value matching a path, or a missing Value
+func (v Value) Get(path string) Value {
+	if found := v.Query(path); len(found) > 0 {
+		return found[0]
+	}
+	return Value{}
+}
+
+// Strings returns the text of every value matching a path, leaving out nulls, empty strings, objects and arrays
+func (v Value) Strings(path string) []string {
+	var result []string
+	for _, value := range v.Query(path) {
+		switch value.data.(type) {
+		case map[string]interface{}, []interface{}, nil:
+			continue
+		}
+		if text := value.String(); text != "" {
+			result = append(result, text)
+		}
+	}
+	return result
+}
+
+// Exists checks whether the value was found
+func (v Value) Exists() bool {
+	return v.present
+}
+
+// Interface returns the decoded value: a map[string]interface{}, []interface{}, string, json.Number, bool or nil
+func (v Value) Interface() interface{} {
+	return v.data
+}
+
+// Array returns the elements of an array, or nothing when the value isn't one
+func (v Value) Array() []Value {
+	arr, _ := v.data.([]interface{})
+	result := make([]Value, 0, len(arr))
+	for _, data := range arr {
+		result = append(result, Value{data: data, present: true})
+	}
+	return result
+}
+
+// String gives the text of a value.  Numbers and booleans are formatted, missing values and nulls
+// are empty and objects and arrays come back as JSON
+func (v Value) String() string {
+	switch data := v.data.(type) {
+	case nil:
+		return ""
+	case string:
+		return data
+	case json.Number:
+		return data.String()
+	case bool:
+		return strconv.FormatBool(data)
+	}
+	text, err := json.Marshal(v.data)
+	if err != nil {
+		return ""
+	}
+	return string(text)
+}
+
+// Decode fills in a struct (or anything else encoding/json can decode into) from the value
+func (v Value) Decode(target interface{}) error {
+	if !v.present {
+		return fmt.Errorf("no value to decode")
+	}
+	text, err := json.Marshal(v.data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(text, target)
+}
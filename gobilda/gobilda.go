@@ -1,6 +1,7 @@
 package gobilda
 
 import (
+	"github.com/toebes/ftc_parts_spider/onshape"
 	"github.com/toebes/ftc_parts_spider/servocity"
 	"github.com/toebes/ftc_parts_spider/spiderdata"
 )
@@ -9,6 +10,7 @@ import (
 var GobildaTarget = spiderdata.SpiderTarget{
 	Outfile:       "gobilda.txt",
 	SpreadsheetID: "15XT3v9O0VOmyxqXrgR8tWDyb_CRLQT5-xPfWPdbx4RM",
+	OnshapeNaming: onshape.Naming{Vendor: "goBILDA"},
 	Presets: []string{
 		"https://www.gobilda.com/structure/",
 		"https://www.gobilda.com/motion/",
@@ -19,6 +21,9 @@ var GobildaTarget = spiderdata.SpiderTarget{
 	StripSKU:       true,
 	ParsePageFunc:  servocity.ParseServocityPage,
 	CheckMatchFunc: servocity.CheckServocityMatch,
+	IncludeRules:   []spiderdata.URLRule{spiderdata.Host("www.gobilda.com")},
+	TrailingSlash:  true,
+	StorefrontAPI:  true,
 	SectionNameDeletes: []string{
 		"Shop by Electrical Connector Style > ",
 		"Shop by Hub Style > ",
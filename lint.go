@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// lintCatalogCommand checks the reference catalog for data quality problems, exiting with 1 when there
+// are errors (or warnings with -strict) so that it can be used to check the spreadsheet after editing
+//
+//	ftc_parts_spider lint-catalog [-target rev] [-run rev.txt] [-strict]
+func lintCatalogCommand(args []string) int {
+	fs := flag.NewFlagSet("lint-catalog", flag.ExitOnError)
+	lintTarget := fs.String("target", "rev", "Target vendor whose catalog is checked")
+	sheet := fs.String("spreadsheet", "", "Catalog spreadsheet (defaults to the one for the target)")
+	runFile := fs.String("run", "", "Run output giving the vendor's sections (defaults to the output file for the target, skipped when missing)")
+	strict := fs.Bool("strict", false, "Fail on warnings as well as errors")
+	credentials := credentialFlags(fs)
+	logging := loggingFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s lint-catalog [flags]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := logging("command", fs.Name()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	config, present := targets[*lintTarget]
+	if !present {
+		slog.Error("unknown target", "phase", "load", "target", *lintTarget)
+		return 2
+	}
+	if *sheet == "" {
+		*sheet = config.SpreadsheetID
+	}
+	if *runFile == "" {
+		*runFile = config.Outfile
+	}
+
+	catalog, err := partcatalog.LoadPartCatalogWithOptions(sheet, ExcludeFromMatch, partcatalog.LoadOptions{
+		Credentials: credentials(),
+		Sheet:       config.Sheet,
+	})
+	if err != nil {
+		slog.Error("unable to load the part catalog", "phase", "load", "spreadsheet", *sheet, "err", err)
+		return 1
+	}
+
+	// The sections come from the last run, along with the ones the target configuration moves parts to.
+	// Without a run there is nothing to check them against
+	var opts partcatalog.LintOptions
+	if parts, err := partcatalog.LoadRunOutput(*runFile); err == nil {
+		for _, part := range parts {
+			opts.Sections = append(opts.Sections, part.Section)
+		}
+		for _, section := range config.SectionAllowedMap {
+			opts.Sections = append(opts.Sections, section)
+		}
+		for _, equivalent := range config.SectionEquivalents {
+			opts.Sections = append(opts.Sections, equivalent...)
+		}
+	} else {
+		slog.Info("no run output, so sections are not checked", "phase", "load", "path", *runFile, "err", err)
+	}
+
+	errorCount, warningCount := 0, 0
+	for _, issue := range partcatalog.LintCatalog(catalog, opts) {
+		fmt.Println(issue)
+		if issue.Severity == partcatalog.SeverityError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+	slog.Info("checked part catalog", "phase", "report", "spreadsheet", *sheet, "parts", len(catalog.Partdata), "errors", errorCount, "warnings", warningCount)
+	if errorCount > 0 || (*strict && warningCount > 0) {
+		return 1
+	}
+	return 0
+}
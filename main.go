@@ -1,28 +1,35 @@
 package main
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/publicsuffix"
 
 	"github.com/toebes/ftc_parts_spider/andymark"
+	"github.com/toebes/ftc_parts_spider/bigcommerce"
+	"github.com/toebes/ftc_parts_spider/canonurl"
 	"github.com/toebes/ftc_parts_spider/gobilda"
+	"github.com/toebes/ftc_parts_spider/onshape"
 	"github.com/toebes/ftc_parts_spider/partcatalog"
+	"github.com/toebes/ftc_parts_spider/progress"
 	"github.com/toebes/ftc_parts_spider/revrobotics"
 	"github.com/toebes/ftc_parts_spider/servocity"
+	"github.com/toebes/ftc_parts_spider/sitemap"
 	"github.com/toebes/ftc_parts_spider/spiderdata"
 	"github.com/toebes/ftc_parts_spider/studica"
+	"github.com/toebes/ftc_parts_spider/thumbnails"
 
 	"github.com/PuerkitoBio/fetchbot"
 	"github.com/PuerkitoBio/goquery"
@@ -68,23 +75,89 @@ var (
 	fileout       = flag.String("out", "", "Output File")
 	spreadsheetID = flag.String("spreadsheet", "", "spider this spreadsheet")
 	singleOnly    = flag.Bool("single", false, "Only process the seed and don't follow any additional links")
+	useSitemap    = flag.Bool("sitemap", false, "Also enqueue every page listed in the site's sitemaps (always on for targets which are set up for it)")
+	sitemapSince  = flag.String("sitemap-since", "", "Only enqueue sitemap pages modified since a date (2024-09-01) or for a duration (720h)")
 	StripSKU      = flag.Bool("stripsku", false, "Strip the SKU and other parameters from URLs")
 	SkipCatalog   = flag.Bool("skipcatalog", false, "Skip loading the catalog")
+	summaryFile   = flag.String("summary", "", "JSON file for the end of run summary (defaults to <out>-summary.json)")
+	imagesDir     = flag.String("images", "", "Directory to keep thumbnails of the product pictures in, named by SKU (no pictures are downloaded unless given)")
+	specColumns   = flag.String("spec-columns", "", "Comma separated list of specifications to output as columns (e.g. Bore,Pitch,Material)")
+	kitsFile      = flag.String("kits", "", "Output file for the contents of the kits found (defaults to <out>-kits.txt)")
+	variantsFile  = flag.String("variants", "", "JSON file grouping the variants of each product (defaults to <out>-variants.json)")
+	dryEnqueue    = flag.String("dry-enqueue", "", "Fetch just this page and print what would be crawled from it (and why anything is rejected) without spidering")
+	incremental   = flag.Bool("incremental", false, "Only process the pages which changed since the last incremental run, reusing the previous results for the rest")
+	stateFile     = flag.String("state", "", "File used by -incremental to keep what each page produced between runs (defaults to <out>-state.json)")
+	relatedFile   = flag.String("related", "", "Base name for the graph of related products, written as .dot and .json (defaults to <out>-related)")
+	useAPI        = flag.Bool("api", false, "Read the products from the store's Storefront API (for targets which support it), falling back to the pages")
+	apiToken      = flag.String("api-token", "", "Storefront API token to use with -api (found from the pages when not given)")
+	movedFile     = flag.String("moved", "", "Output file listing the catalog URLs which now redirect elsewhere (defaults to <out>-moved.txt)")
+	changesFile   = flag.String("changes", "", "CSV file of the edits which would bring the catalog up to date (defaults to <out>-changes.csv)")
+	namingFile    = flag.String("naming", "", "Output file proposing the Onshape folder, part name and configuration for each New or Changed part (not written unless given)")
+	snapshotFile  = flag.String("catalog-snapshot", "", "JSON file to save the catalog the run is matched against in (defaults to <out>-catalog-<time>.json, \"none\" to not save it)")
+	showProgress  = flag.Duration("progress", 0, "display the progress of the run at a given interval (use -log-level warn to keep the display readable)")
 )
 
+// catalogCredentials gives the credentials for reading the catalog from the command line flags
+var catalogCredentials = credentialFlags(flag.CommandLine)
+
+// crawlLogging sets up the logging asked for on the command line
+var crawlLogging = loggingFlags(flag.CommandLine)
+
+// crawlStopped is set when -stopafter, -stopat, -cancelafter or -cancelat ends the crawl before its
+// queue has drained, so the run hasn't seen the whole site
+var crawlStopped atomic.Bool
+
 type userAgentTransport struct {
+	// qc tracks how many requests are currently being fetched
+	qc *spiderdata.QueueCounter
+	// state supplies the validators for conditional requests on an incremental run
+	state *spiderdata.CrawlState
 }
 
 func (uat *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if uat.qc != nil {
+		uat.qc.StartFetch()
+		defer uat.qc.EndFetch()
+	}
 	req.Header.Set("User-Agent", "FTCPartsSpider/1.0.0")
 	req.Header.Set("Host", "ftconshape.com")
 	req.Header.Set("Accept", "*/*")
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Accept-Encoding", "")
 	req.Header.Set("Connection", "keep-alive")
+	if req.Method == http.MethodGet {
+		etag, lastModified := uat.state.Validators(req.URL.String())
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
 	return http.DefaultTransport.RoundTrip(req)
 }
 
+// credentialFlags adds the -credentials, -token and -non-interactive flags to a flag set, returning a
+// function which gives the credentials they ask for once the flags have been parsed
+func credentialFlags(fs *flag.FlagSet) func() partcatalog.Credentials {
+	file := fs.String("credentials", "", "Google service account key or OAuth client secret for reading the catalog (defaults to $FTC_SPIDER_CREDENTIALS, $GOOGLE_APPLICATION_CREDENTIALS or credentials.json)")
+	tokenFile := fs.String("token", "", "File keeping the OAuth token when -credentials is a client secret (defaults to $FTC_SPIDER_TOKEN or token.json)")
+	nonInteractive := fs.Bool("non-interactive", false, "Fail instead of asking for an authorization code when there is no OAuth token (automatic when stdin isn't a terminal)")
+	return func() partcatalog.Credentials {
+		creds := partcatalog.DefaultCredentials()
+		if *file != "" {
+			creds.File = *file
+		}
+		if *tokenFile != "" {
+			creds.TokenFile = *tokenFile
+		}
+		if *nonInteractive {
+			creds.Interactive = false
+		}
+		return creds
+	}
+}
+
 // ExcludeFromMatch checks to see whether something should be spidered
 func ExcludeFromMatch(partdata *partcatalog.PartData) bool {
 	exclude := false
@@ -100,8 +173,64 @@ func ExcludeFromMatch(partdata *partcatalog.PartData) bool {
 	return exclude
 }
 
+// setupLogging sends all of the diagnostic messages to stderr so that they never mix with the
+// output file or the summary report.  The args are added to every message
+func setupLogging(level string, format string, args ...any) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format '%s'", format)
+	}
+	slog.SetDefault(slog.New(handler).With(args...))
+	return nil
+}
+
+// loggingFlags adds the -log-level and -log-format flags to a flag set, returning a function which sets
+// up the logging they ask for once the flags have been parsed
+func loggingFlags(fs *flag.FlagSet) func(args ...any) error {
+	level := fs.String("log-level", "info", "Minimum level of diagnostic messages: debug, info, warn or error")
+	format := fs.String("log-format", "text", "Format of diagnostic messages: text or json")
+	return func(args ...any) error {
+		return setupLogging(*level, *format, args...)
+	}
+}
+
+// fatal logs an error and terminates the run
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// commands are the alternatives to spidering a target, selected by the first argument
+var commands = map[string]func(args []string) int{
+	"serve":         serveCommand,
+	"api":           apiCommand,
+	"lint-catalog":  lintCatalogCommand,
+	"diff-catalog":  diffCatalogCommand,
+	"apply-changes": applyChangesCommand,
+	"check-onshape": checkOnshapeCommand,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, found := commands[os.Args[1]]; found {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
 	flag.Parse()
+	if err := crawlLogging("target", *target); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
 
 	context := spiderdata.Context{}
 	context.G = &spiderdata.Globals{}
@@ -110,6 +239,12 @@ func main() {
 	context.G.DownloadMap = make(spiderdata.DownloadEntMap)
 	context.G.SingleOnly = *singleOnly
 	context.G.StripSKU = *StripSKU
+	context.G.Stats = spiderdata.NewRunStats()
+	for _, name := range strings.Split(*specColumns, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			context.G.SpecColumns = append(context.G.SpecColumns, name)
+		}
+	}
 
 	present := false
 	context.G.TargetConfig, present = targets[*target]
@@ -117,6 +252,21 @@ func main() {
 		context.G.TargetConfig = targets[""]
 	}
 
+	if err := context.G.TargetConfig.CompileRules(); err != nil {
+		fatal("unable to use the URL rules", "target", *target, "err", err)
+	}
+
+	// A dry enqueue only looks at the one page and doesn't produce any output files
+	if *dryEnqueue != "" {
+		*seed = *dryEnqueue
+		*fileout = os.DevNull
+		*SkipCatalog = true
+		*incremental = false
+		*singleOnly = false
+		context.G.SingleOnly = false
+		context.G.DryEnqueue = true
+	}
+
 	// See if we have to fill in any defaults
 	if len(*seed) == 0 {
 		*seed = context.G.TargetConfig.Seed
@@ -127,6 +277,39 @@ func main() {
 	if len(*spreadsheetID) == 0 {
 		*spreadsheetID = context.G.TargetConfig.SpreadsheetID
 	}
+	if len(*summaryFile) == 0 {
+		*summaryFile = strings.TrimSuffix(*fileout, filepath.Ext(*fileout)) + "-summary.json"
+	}
+	if len(*kitsFile) == 0 {
+		*kitsFile = strings.TrimSuffix(*fileout, filepath.Ext(*fileout)) + "-kits" + filepath.Ext(*fileout)
+	}
+	if len(*variantsFile) == 0 {
+		*variantsFile = strings.TrimSuffix(*fileout, filepath.Ext(*fileout)) + "-variants.json"
+	}
+	if len(*stateFile) == 0 {
+		*stateFile = strings.TrimSuffix(*fileout, filepath.Ext(*fileout)) + "-state.json"
+	}
+	if len(*relatedFile) == 0 {
+		*relatedFile = strings.TrimSuffix(*fileout, filepath.Ext(*fileout)) + "-related"
+	}
+	if len(*movedFile) == 0 {
+		*movedFile = strings.TrimSuffix(*fileout, filepath.Ext(*fileout)) + "-moved" + filepath.Ext(*fileout)
+	}
+	if len(*changesFile) == 0 {
+		*changesFile = strings.TrimSuffix(*fileout, filepath.Ext(*fileout)) + "-changes.csv"
+	}
+	if len(*snapshotFile) == 0 {
+		*snapshotFile = partcatalog.SnapshotPath(strings.TrimSuffix(*fileout, filepath.Ext(*fileout)), time.Now())
+	}
+
+	// Check the naming templates now rather than after the whole run
+	var namer *onshape.Namer
+	if *namingFile != "" {
+		var err error
+		if namer, err = onshape.NewNamer(context.G.TargetConfig.OnshapeNaming); err != nil {
+			fatal("unable to use the Onshape naming templates", "target", *target, "err", err)
+		}
+	}
 
 	if context.G.TargetConfig.StripSKU {
 		context.G.StripSKU = context.G.TargetConfig.StripSKU
@@ -135,7 +318,20 @@ func main() {
 	// Parse the provided seed
 	u, err := url.Parse(*seed)
 	if err != nil {
-		log.Fatal(err)
+		fatal("unable to parse seed", "url", *seed, "err", err)
+	}
+	// Every URL is normalized the same way, with the seed's host as the preferred form of the vendor's host
+	context.G.Canon = &canonurl.Canonicalizer{
+		Host:              u.Hostname(),
+		IgnoreCase:        context.G.TargetConfig.IgnoreCase,
+		TrailingSlash:     context.G.TargetConfig.TrailingSlash,
+		StripQuery:        context.G.StripSKU,
+		SignificantParams: context.G.TargetConfig.SignificantParams,
+	}
+
+	// Nothing would ever be fetched if the rules turn away the seed
+	if ok, reason := context.G.TargetConfig.CheckURL(*seed); !ok {
+		fatal("seed is rejected by the URL rules", "url", *seed, "reason", reason)
 	}
 
 	// Mark all the pages that we want to automatically skip
@@ -146,7 +342,7 @@ func main() {
 	// Start our log file to import into Excel
 	context.G.Outfile, err = os.Create(*fileout)
 	if err != nil {
-		log.Fatal(err)
+		fatal("unable to create output file", "path", *fileout, "err", err)
 	}
 	spiderdata.OutputHeader(&context)
 
@@ -155,13 +351,25 @@ func main() {
 		context.G.ReferenceData = partcatalog.NewPartCatalogData()
 		context.G.ReferenceData.Partdata = make([]*partcatalog.PartData, 0)
 	} else {
-		context.G.ReferenceData, err = partcatalog.LoadPartCatalog(spreadsheetID, ExcludeFromMatch)
+		slog.Info("loading part catalog", "phase", "load", "spreadsheet", *spreadsheetID)
+		context.G.ReferenceData, err = partcatalog.LoadPartCatalogWithOptions(spreadsheetID, ExcludeFromMatch, partcatalog.LoadOptions{Credentials: catalogCredentials(), Sheet: context.G.TargetConfig.Sheet})
 		if err != nil {
-			fmt.Printf("%v\n", err)
+			// Without the catalog every part would come out as New, so there is no point carrying on
+			fatal("unable to load part catalog", "phase", "load", "err", err)
+		}
+		if *snapshotFile != "none" {
+			snapshot := partcatalog.NewSnapshot(context.G.ReferenceData, *spreadsheetID, context.G.TargetConfig.Sheet, time.Now())
+			if err := snapshot.WriteJSON(*snapshotFile); err != nil {
+				slog.Error("unable to save catalog snapshot", "phase", "load", "path", *snapshotFile, "err", err)
+				*snapshotFile = "none"
+			} else {
+				slog.Info("saved catalog snapshot", "phase", "load", "path", *snapshotFile, "parts", len(snapshot.Parts))
+			}
 		}
 	}
 
 	if context.G.ReferenceData != nil {
+		context.G.ReferenceData.IndexURLs(context.G.Canon.Canonical)
 		for _, partdata := range context.G.ReferenceData.ExcludeFromSearch {
 			partdata.SpiderStatus = partcatalog.UnchangedPart
 			spiderdata.OutputPartData(&context, partdata)
@@ -169,20 +377,49 @@ func main() {
 	}
 	context.Qc = &spiderdata.QueueCounter{}
 
+	if *incremental {
+		context.G.Crawl, err = spiderdata.LoadCrawlState(*stateFile)
+		if err != nil {
+			fatal("unable to load crawl state", "path", *stateFile, "err", err)
+		}
+	}
+
 	// Initialize a custom HTTP client with a User-Agent
 	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 	client := &http.Client{
-		Transport: &userAgentTransport{},
+		Transport: &userAgentTransport{qc: context.Qc, state: context.G.Crawl},
 		Jar:       jar}
+	context.G.Redirects = spiderdata.NewRedirectTracker()
+	if *useAPI && !context.G.DryEnqueue {
+		if context.G.TargetConfig.StorefrontAPI {
+			context.G.Storefront = bigcommerce.NewClient(client, u)
+			context.G.Storefront.Token = *apiToken
+		} else {
+			slog.Warn("-api ignored, the target has no storefront API", "target", *target)
+		}
+	}
 
 	// Create the muxer
 	mux := fetchbot.NewMux()
 
 	// Handle all errors the same
 	mux.HandleErrors(fetchbot.HandlerFunc(func(ctx *fetchbot.Context, res *http.Response, err error) {
-		fmt.Printf("[ERR] %s %s - %s\n", ctx.Cmd.Method(), ctx.Cmd.URL(), err)
+		slog.Error("fetch failed", "phase", "crawl", "method", ctx.Cmd.Method(), "url", ctx.Cmd.URL().String(), "err", err)
+		context.G.Stats.RecordError("Fetch error")
 	}))
 
+	// pageDone is called after each page has been handled.  Note that when we get down to processing
+	// the last one, we want to queue in all the entries which were in the loaded list
+	pageDone := func(url string) {
+		remain := context.Qc.GetPendingCount()
+		slog.Debug("page processed", "phase", "crawl", "url", url, "remain", remain)
+		if remain == 1 && !context.G.SingleOnly {
+			for _, entry := range context.G.ReferenceData.PartNumber {
+				spiderdata.EnqueURL(&context, entry.URL, entry.Section)
+			}
+		}
+	}
+
 	// Handle GET requests for html responses, to parse the body and enqueue all links as HEAD
 	// requests.
 	getHandler := fetchbot.HandlerFunc(
@@ -194,57 +431,75 @@ func main() {
 			// }
 			context.Qc.Decrement()
 			if err != nil {
-				fmt.Printf("[ERR] %s %s - %s\n", ctx.Cmd.Method(), ctx.Cmd.URL(), err)
+				slog.Error("fetch failed", "phase", "crawl", "method", ctx.Cmd.Method(), "url", ctx.Cmd.URL().String(), "err", err)
+				context.G.Stats.RecordError("Fetch error")
 				return
 			}
 			// Process the body to find the links
 			defer res.Body.Close()
+			spiderdata.RecordRedirects(&context, res)
+			if res.StatusCode == http.StatusNotModified {
+				// Unchanged since the last incremental run, so use what we got from it last time
+				original := ctx.Cmd.URL().String()
+				muxcontext := spiderdata.Context{Cmd: ctx.Cmd, Q: ctx.Q, G: context.G, Url: original, Qc: context.Qc}
+				if !spiderdata.ReplayPage(&muxcontext, original) {
+					slog.Warn("not modified but nothing saved for page", "phase", "crawl", "url", original)
+				}
+				pageDone(original)
+				return
+			}
+			context.G.Stats.PageFetched(ctx.Cmd.URL().String())
 			if res.StatusCode == 404 {
-
+				context.G.Stats.RecordError("Page not found")
 			} else {
 				doc, err := goquery.NewDocumentFromReader(res.Body)
 				if err != nil {
-					fmt.Printf("[ERR] %s %s - %s\n", ctx.Cmd.Method(), ctx.Cmd.URL(), err)
+					slog.Error("unable to parse page", "phase", "crawl", "url", ctx.Cmd.URL().String(), "err", err)
+					context.G.Stats.RecordError("Parse error")
 					return
 				}
 				url := res.Request.URL.String()
 				wasseen := false
 				original := ctx.Cmd.URL().String()
 				if url != original {
-					// We got a redirect.  See if the finalURL was also on the list
-					_, wasseen = context.G.BreadcrumbMap[url]
+					// We got a redirect.  See if the finalURL was also on the list (a redirect which only
+					// normalizes the address, such as adding a trailing slash, still leaves us on the same page)
+					if key, _ := spiderdata.CleanURL(&context, url); key != original {
+						_, wasseen = context.G.BreadcrumbMap[key]
+					}
 				}
 
 				muxcontext := spiderdata.Context{Cmd: ctx.Cmd, Q: ctx.Q, G: context.G, Url: url, Qc: context.Qc}
-				// Enqueue all links as HEAD requests
+				// The page may say that it is really another address that we have already been to
 				if !wasseen {
-					context.G.TargetConfig.ParsePageFunc(&muxcontext, doc)
+					muxcontext.Url, wasseen = spiderdata.CanonicalPage(&muxcontext, doc)
 				}
-				// See how many are remaining
-				remain := context.Qc.GetPendingCount()
-				fmt.Printf("#### After Processing %v remain\n", remain)
-				// Note that when we get down to processing the last one, we want to
-				// queue in all the entries which were in the loaded list
-				if remain == 1 && !context.G.SingleOnly {
-					for _, entry := range context.G.ReferenceData.PartNumber {
-						spiderdata.EnqueURL(&context, entry.URL, entry.Section)
+				// Enqueue all links as HEAD requests
+				if !wasseen {
+					// Only remember pages we weren't redirected to, since the validators belong to the original address.
+					// A page with a different canonical address is kept under that, as that is where it records its parts
+					if url == original {
+						context.G.Crawl.BeginPage(url, muxcontext.Url, res.Header)
 					}
+					spiderdata.FetchStorefrontProduct(&muxcontext, doc)
+					context.G.TargetConfig.ParsePageFunc(&muxcontext, doc)
 				}
+				pageDone(url)
 			}
 		})
 	mux.Response().Method("GET").ContentType("text/html").Handler(getHandler)
-	mux.Response().Method("GET").ContentType("application/xml").Handler(getHandler)
+	// A 304 Not Modified usually has no content type, so it needs its own route
+	mux.Response().Method("GET").Status(http.StatusNotModified).Handler(getHandler)
 
 	// Handle HEAD requests for html responses coming from the source host - we don't want
 	// to crawl links from other hosts.
 	headhandler := fetchbot.HandlerFunc(
 		func(ctx *fetchbot.Context, res *http.Response, err error) {
 			if _, err := ctx.Q.SendStringGet(ctx.Cmd.URL().String()); err != nil {
-				fmt.Printf("[ERR] %s %s - %s\n", ctx.Cmd.Method(), ctx.Cmd.URL(), err)
+				slog.Error("unable to enqueue", "phase", "crawl", "url", ctx.Cmd.URL().String(), "err", err)
 			}
 		})
 
-	mux.Response().Method("HEAD").Host(u.Host).ContentType("application/xml").Handler(headhandler)
 	mux.Response().Method("HEAD").Host(u.Host).ContentType("text/html").Handler(headhandler)
 
 	// Create the Fetcher, handle the logging first, then dispatch to the Muxer
@@ -293,6 +548,7 @@ func main() {
 		go func() {
 			c := time.After(after)
 			<-c
+			crawlStopped.Store(true)
 			stopFunc()
 		}()
 	}
@@ -300,21 +556,157 @@ func main() {
 	// Enqueue the seed, which is the first entry in the dup map
 	spiderdata.EnqueURL(&context, *seed, "Home > Competition > FTC")
 
-	if !context.G.SingleOnly {
+	if !context.G.SingleOnly && !context.G.DryEnqueue && (*useSitemap || context.G.TargetConfig.Sitemap) {
+		seedFromSitemap(&context, client, u)
+	}
+
+	if context.G.DryEnqueue {
+		slog.Info("-dry-enqueue option selected, only listing the links found", "url", *seed)
+	} else if !context.G.SingleOnly {
 		for _, val := range context.G.TargetConfig.Presets {
 			spiderdata.EnqueURL(&context, val, "Initial")
 		}
 	} else {
-		fmt.Print("*** -single option selected, no additional URLs will be spidered")
+		slog.Info("-single option selected, no additional URLs will be spidered")
+	}
+
+	var display *progress.Display
+	if *showProgress > 0 {
+		display = progress.Start(context.G.Stats, context.Qc, *showProgress)
 	}
 
 	q.Block()
 
+	if display != nil {
+		display.Stop()
+	}
+	if context.G.DryEnqueue {
+		return
+	}
+
+	// Every catalog URL has been crawled by now, so the parts found under a different URL can be settled
+	spiderdata.ReconcilePending(&context)
+
 	for _, entry := range context.G.ReferenceData.PartNumber {
 		if entry.SpiderStatus == partcatalog.PartNotFoundBySpider {
 			spiderdata.OutputPartData(&context, entry)
 		}
 	}
+
+	// List the catalog URLs which now redirect so that the spreadsheet can be updated in bulk.
+	// A -single run doesn't look for most of the parts, so it would only find noise
+	if !context.G.SingleOnly {
+		spiderdata.FindMovedNotFound(&context)
+	}
+	if moved := context.G.Redirects.MovedURLs(); len(moved) > 0 {
+		if err := spiderdata.WriteMovedURLs(*movedFile, moved); err != nil {
+			slog.Error("unable to write moved URLs", "phase", "report", "path", *movedFile, "err", err)
+		} else {
+			slog.Info("wrote moved URLs", "phase", "report", "path", *movedFile, "urls", len(moved))
+		}
+	}
+
+	// Turn the notes into edits for the spreadsheet so that the curators only have to review them
+	if !*SkipCatalog {
+		// Only a crawl which went through its whole queue can say that a part is gone
+		opts := partcatalog.ChangeSetOptions{MovedURLs: make(map[string]string), Retire: !context.G.SingleOnly && !crawlStopped.Load()}
+		for _, moved := range context.G.Redirects.MovedURLs() {
+			if moved.Status != spiderdata.MovedDifferentPage {
+				opts.MovedURLs[moved.OldURL] = moved.NewURL
+			}
+		}
+		changes := partcatalog.BuildChangeSet(context.G.ReferenceData, context.G.Emitted, opts)
+		if err := writeChangeSet(*changesFile, changes); err != nil {
+			slog.Error("unable to write change set", "phase", "report", "path", *changesFile, "err", err)
+		} else {
+			slog.Info("wrote change set", "phase", "report", "path", *changesFile, "changes", len(changes))
+		}
+	}
+
+	// Suggest how the modelers should name the new parts in the library
+	if namer != nil {
+		if count, err := onshape.WriteNamingProposals(*namingFile, namer, context.G.Emitted); err != nil {
+			slog.Error("unable to write naming proposals", "phase", "report", "path", *namingFile, "err", err)
+		} else {
+			slog.Info("wrote naming proposals", "phase", "report", "path", *namingFile, "parts", count)
+		}
+	}
+
+	// Group the options of each product so that they can be made into one configurable part
+	if groups := spiderdata.GroupVariants(context.G.Emitted); len(groups) > 0 {
+		if err := spiderdata.WriteVariantGroups(*variantsFile, groups); err != nil {
+			slog.Error("unable to write variants", "phase", "report", "path", *variantsFile, "err", err)
+		} else {
+			slog.Info("wrote variants", "phase", "report", "path", *variantsFile, "products", len(groups))
+		}
+	}
+
+	// Expand the kits into their components so that BOMs can be built from them
+	if len(context.G.Kits) > 0 {
+		missing, err := spiderdata.WriteKitContents(*kitsFile, context.G.Kits, context.G.ReferenceData, context.G.Emitted)
+		if err != nil {
+			slog.Error("unable to write kit contents", "phase", "report", "path", *kitsFile, "err", err)
+		} else {
+			slog.Info("wrote kit contents", "phase", "report", "path", *kitsFile, "kits", len(context.G.Kits), "missing", missing)
+		}
+	}
+
+	// Save what each page produced for the next incremental run.  A -single run only sees one page,
+	// so saving it would throw away everything else.  A crawl which was stopped early keeps what the
+	// previous run had for the pages it didn't get to
+	if context.G.Crawl != nil && !context.G.SingleOnly {
+		kept := 0
+		if crawlStopped.Load() {
+			kept = context.G.Crawl.KeepUnreached()
+		}
+		if err := context.G.Crawl.Save(*stateFile); err != nil {
+			slog.Error("unable to save crawl state", "phase", "report", "path", *stateFile, "err", err)
+		} else {
+			slog.Info("saved crawl state", "phase", "report", "path", *stateFile, "pages", context.G.Crawl.Pages(), "unreached", kept)
+		}
+	}
+
+	// Show which products the vendor considers go together
+	if len(context.G.Related) > 0 {
+		graph := spiderdata.BuildRelatedGraph(context.G.Related, context.G.Emitted)
+		for _, write := range []struct {
+			path string
+			fn   func(string, spiderdata.RelatedGraph) error
+		}{
+			{*relatedFile + ".dot", spiderdata.WriteRelatedDOT},
+			{*relatedFile + ".json", spiderdata.WriteRelatedJSON},
+		} {
+			if err := write.fn(write.path, graph); err != nil {
+				slog.Error("unable to write related products", "phase", "report", "path", write.path, "err", err)
+			} else {
+				slog.Info("wrote related products", "phase", "report", "path", write.path, "products", len(graph.Nodes), "links", len(graph.Links))
+			}
+		}
+	}
+
+	// Keep a small copy of each product picture for checking the models against
+	if *imagesDir != "" {
+		// The pictures get a client of their own so that they aren't counted as pages being crawled and are
+		// never asked for conditionally with the validators of a page
+		imageClient := &http.Client{Transport: &userAgentTransport{}, Timeout: time.Minute}
+		archive := thumbnails.Archive{Dir: *imagesDir, Size: thumbnails.DefaultSize, Client: imageClient, Workers: 4}
+		saved, failed, err := archive.SaveAll(context.G.Emitted)
+		if err != nil {
+			slog.Error("unable to save thumbnails", "phase", "report", "dir", *imagesDir, "err", err)
+		} else {
+			slog.Info("saved thumbnails", "phase", "report", "dir", *imagesDir, "saved", saved, "failed", failed)
+		}
+	}
+
+	// Report how the run went both on the console and in a form a script can check
+	summary := context.G.Stats.Summary(*target)
+	if !*SkipCatalog && *snapshotFile != "none" {
+		summary.CatalogSnapshot = *snapshotFile
+	}
+	summary.Print(os.Stdout)
+	if err := summary.WriteJSON(*summaryFile); err != nil {
+		slog.Error("unable to write summary", "phase", "report", "path", *summaryFile, "err", err)
+	}
 }
 
 func runMemStats(f *fetchbot.Fetcher, tick time.Duration) {
@@ -343,18 +735,50 @@ func runMemStats(f *fetchbot.Fetcher, tick time.Duration) {
 func printMemStats(di *fetchbot.DebugInfo) {
 	var mem runtime.MemStats
 	runtime.ReadMemStats(&mem)
-	buf := bytes.NewBuffer(nil)
-	buf.WriteString(strings.Repeat("=", 72) + "\n")
-	buf.WriteString("Memory Profile:\n")
-	buf.WriteString(fmt.Sprintf("`Alloc: %d Kb\n", mem.Alloc/1024))
-	buf.WriteString(fmt.Sprintf("`TotalAlloc: %d Kb\n", mem.TotalAlloc/1024))
-	buf.WriteString(fmt.Sprintf("`NumGC: %d\n", mem.NumGC))
-	buf.WriteString(fmt.Sprintf("`Goroutines: %d\n", runtime.NumGoroutine()))
+	attrs := []any{
+		"allocKb", mem.Alloc / 1024,
+		"totalAllocKb", mem.TotalAlloc / 1024,
+		"numGC", mem.NumGC,
+		"goroutines", runtime.NumGoroutine(),
+	}
 	if di != nil {
-		buf.WriteString(fmt.Sprintf("`NumHosts: %d\n", di.NumHosts))
+		attrs = append(attrs, "numHosts", di.NumHosts)
+	}
+	slog.Info("memory profile", attrs...)
+}
+
+// seedFromSitemap enqueues the pages listed in the sitemaps of the site the seed is on
+func seedFromSitemap(ctx *spiderdata.Context, client *http.Client, site *url.URL) {
+	// The sitemap pages go through the same include and exclude rules as every other link.  They are
+	// checked here as well as when they are enqueued so that a page the rules turn away isn't replayed
+	opts := sitemap.Options{Allow: func(loc string) bool {
+		key, _ := spiderdata.CleanURL(ctx, loc)
+		ok, _ := ctx.G.TargetConfig.CheckURL(key)
+		return ok
+	}}
+	if *sitemapSince != "" {
+		if age, err := time.ParseDuration(*sitemapSince); err == nil {
+			opts.Since = time.Now().Add(-age)
+		} else if opts.Since = sitemap.ParseLastMod(*sitemapSince); opts.Since.IsZero() {
+			fatal("unable to understand -sitemap-since", "value", *sitemapSince)
+		}
+	}
+	entries, err := sitemap.Discover(client, site, opts)
+	if err != nil {
+		slog.Error("unable to read sitemaps", "phase", "sitemap", "err", err)
+		return
+	}
+	reused := 0
+	for _, entry := range entries {
+		// On an incremental run a page the sitemap says hasn't changed doesn't even need to be fetched
+		key, _ := spiderdata.CleanURL(ctx, entry.Loc)
+		if ctx.G.Crawl.SitemapUnchanged(key, entry.LastMod) && spiderdata.ReplayPage(ctx, key) {
+			reused++
+			continue
+		}
+		spiderdata.EnqueURL(ctx, entry.Loc, "")
 	}
-	buf.WriteString(strings.Repeat("=", 72))
-	fmt.Println(buf.String())
+	slog.Info("seeded from sitemaps", "phase", "sitemap", "pages", len(entries), "reused", reused)
 }
 
 // stopHandler stops the fetcher if the stopurl is reached. Otherwise it dispatches
@@ -362,7 +786,8 @@ func printMemStats(di *fetchbot.DebugInfo) {
 func stopHandler(stopurl string, cancel bool, wrapped fetchbot.Handler) fetchbot.Handler {
 	return fetchbot.HandlerFunc(func(ctx *fetchbot.Context, res *http.Response, err error) {
 		if ctx.Cmd.URL().String() == stopurl {
-			fmt.Printf(">>>>> STOP URL %s\n", ctx.Cmd.URL())
+			slog.Info("stop URL reached", "url", ctx.Cmd.URL().String(), "cancel", cancel)
+			crawlStopped.Store(true)
 			// generally not a good idea to stop/block from a handler goroutine
 			// so do it in a separate goroutine
 			go func() {
@@ -378,11 +803,11 @@ func stopHandler(stopurl string, cancel bool, wrapped fetchbot.Handler) fetchbot
 	})
 }
 
-// logHandler prints the fetch information and dispatches the call to the wrapped Handler.
+// logHandler logs the fetch information and dispatches the call to the wrapped Handler.
 func logHandler(wrapped fetchbot.Handler) fetchbot.Handler {
 	return fetchbot.HandlerFunc(func(ctx *fetchbot.Context, res *http.Response, err error) {
 		if err == nil {
-			fmt.Printf("[%d] %s %s - %s\n", res.StatusCode, ctx.Cmd.Method(), ctx.Cmd.URL(), res.Header.Get("Content-Type"))
+			slog.Info("fetched", "phase", "crawl", "status", res.StatusCode, "method", ctx.Cmd.Method(), "url", ctx.Cmd.URL().String(), "contentType", res.Header.Get("Content-Type"))
 		}
 		wrapped.Handle(ctx, res, err)
 	})
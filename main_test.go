@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toebes/ftc_parts_spider/spiderdata"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == "Sun, 01 Sep 2024 00:00:00 GMT" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	saved := `{"` + server.URL + `/gears": {"etag": "\"v1\"", "lastModified": "Sun, 01 Sep 2024 00:00:00 GMT"}}`
+	if err := os.WriteFile(path, []byte(saved), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := spiderdata.LoadCrawlState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &userAgentTransport{state: state}}
+	for _, test := range []struct {
+		path   string
+		status int
+	}{
+		{"/gears", http.StatusNotModified},
+		{"/motors", http.StatusOK},
+	} {
+		res, err := client.Get(server.URL + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != test.status {
+			t.Errorf("GET %s = %d, expected %d", test.path, res.StatusCode, test.status)
+		}
+	}
+}
+
+func TestLoggingFlags(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	fs := flag.NewFlagSet("lint-catalog", flag.ContinueOnError)
+	logging := loggingFlags(fs)
+	if err := fs.Parse([]string{"-log-level", "debug", "-log-format", "json"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := logging("command", fs.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("-log-level debug didn't apply to the command")
+	}
+
+	fs = flag.NewFlagSet("serve", flag.ContinueOnError)
+	logging = loggingFlags(fs)
+	if err := fs.Parse([]string{"-log-format", "xml"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := logging(); err == nil {
+		t.Error("an unknown log format was accepted")
+	}
+}
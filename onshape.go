@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/toebes/ftc_parts_spider/onshape"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// checkOnshapeCommand checks the Onshape URLs of the catalog against an exported listing of our Onshape
+// library, exiting with 1 when any catalog model is missing or doesn't have a part named for its SKU
+//
+//	ftc_parts_spider check-onshape -library onshape.json [-catalog rev-catalog-<time>.json | -target rev]
+func checkOnshapeCommand(args []string) int {
+	fs := flag.NewFlagSet("check-onshape", flag.ExitOnError)
+	libraryFile := fs.String("library", "", "Exported listing of the Onshape library (.json, or .csv)")
+	snapshotFile := fs.String("catalog", "", "Catalog snapshot saved by a run (the spreadsheet for the target is read when not given)")
+	checkTarget := fs.String("target", "rev", "Target vendor whose catalog spreadsheet is checked")
+	sheet := fs.String("spreadsheet", "", "Catalog spreadsheet (defaults to the one for the target)")
+	out := fs.String("out", "", "File for the report (defaults to the standard output)")
+	all := fs.Bool("all", false, "Also list the rows whose model is fine")
+	credentials := credentialFlags(fs)
+	logging := loggingFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s check-onshape -library <listing> [flags]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := logging("command", fs.Name()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if *libraryFile == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	library, err := onshape.LoadLibrary(*libraryFile)
+	if err != nil {
+		slog.Error("unable to load the Onshape library", "phase", "load", "path", *libraryFile, "err", err)
+		return 1
+	}
+
+	var parts []*partcatalog.PartData
+	if *snapshotFile != "" {
+		snapshot, err := partcatalog.LoadSnapshot(*snapshotFile)
+		if err != nil {
+			slog.Error("unable to load catalog snapshot", "phase", "load", "path", *snapshotFile, "err", err)
+			return 1
+		}
+		parts = snapshot.Parts
+	} else {
+		config, present := targets[*checkTarget]
+		if !present {
+			slog.Error("unknown target", "phase", "load", "target", *checkTarget)
+			return 2
+		}
+		if *sheet == "" {
+			*sheet = config.SpreadsheetID
+		}
+		catalog, err := partcatalog.LoadPartCatalogWithOptions(sheet, nil, partcatalog.LoadOptions{
+			Credentials: credentials(),
+			Sheet:       config.Sheet,
+		})
+		if err != nil {
+			slog.Error("unable to load the part catalog", "phase", "load", "spreadsheet", *sheet, "err", err)
+			return 1
+		}
+		parts = catalog.Partdata
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			slog.Error("unable to create output file", "phase", "report", "path", *out, "err", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	results := onshape.Check(parts, library, *all)
+	if err := onshape.WriteReport(w, results); err != nil {
+		slog.Error("unable to write the report", "phase", "report", "path", *out, "err", err)
+		return 1
+	}
+
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	slog.Info("checked Onshape models", "phase", "report", "library", len(library.Parts),
+		"missing", counts[onshape.StatusMissing], "mismatched", counts[onshape.StatusMismatched],
+		"badURL", counts[onshape.StatusBadURL], "orphaned", counts[onshape.StatusOrphaned])
+	if onshape.Failed(results) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package onshape
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// The outcomes of checking a catalog model against the library
+const (
+	// StatusOK - the model has a part (or a configuration of one) named for the SKU
+	StatusOK = "OK"
+	// StatusMissing - the document (or tab) isn't in the library, or a part marked Done has no model
+	StatusMissing = "Missing"
+	// StatusMismatched - the model has no part or configuration named for the SKU
+	StatusMismatched = "Mismatched"
+	// StatusBadURL - the Onshape URL isn't a document URL
+	StatusBadURL = "Bad URL"
+	// StatusOrphaned - a part in one of the catalog's documents which no catalog row matches
+	StatusOrphaned = "Orphaned"
+)
+
+// DoneStatus is the Model Status of a part whose model is finished
+const DoneStatus = "Done"
+
+// Result is what was found for one catalog row, or for an orphaned part of the library
+type Result struct {
+	Status  string
+	Part    *partcatalog.PartData // nil for an orphaned model
+	Model   *Part                 // The part of the library which matched, or the orphan
+	Details string
+}
+
+// Check compares the Onshape URL of every catalog part against the library.  Rows without an Onshape
+// URL are only reported when their Model Status says the model is done.  Each part in a document which
+// the catalog points at that no catalog row matched by its SKU is reported as orphaned, even when it shares
+// the document with parts that were matched.  The documents no row points at are left alone, since the
+// library holds the models of every vendor.  When all is true the rows which are fine are included as well
+func Check(parts []*partcatalog.PartData, library *Library, all bool) []Result {
+	var results []Result
+	referenced := make(map[*Part]bool)
+	documents := make(map[string]bool)
+	for _, part := range parts {
+		if strings.TrimSpace(part.OnshapeURL) == "" {
+			if strings.EqualFold(strings.TrimSpace(part.Status), DoneStatus) {
+				results = append(results, Result{Status: StatusMissing, Part: part, Details: "the model is Done but there is no Onshape URL"})
+			}
+			continue
+		}
+		ref, err := ParseURL(part.OnshapeURL)
+		if err != nil {
+			results = append(results, Result{Status: StatusBadURL, Part: part, Details: err.Error()})
+			continue
+		}
+		documents[ref.DocumentID] = true
+		models, found := library.Find(ref)
+		if !found {
+			what := "document"
+			if ref.ElementID != "" {
+				what = "tab of the document"
+			}
+			results = append(results, Result{Status: StatusMissing, Part: part, Details: "the " + what + " isn't in the library"})
+			continue
+		}
+		if model := findSKU(models, part.SKU); model != nil {
+			referenced[model] = true
+			if all {
+				results = append(results, Result{Status: StatusOK, Part: part, Model: model})
+			}
+			continue
+		}
+		names := make([]string, 0, len(models))
+		for _, model := range models {
+			names = append(names, model.label())
+		}
+		results = append(results, Result{Status: StatusMismatched, Part: part, Details: "no part named for " + part.SKU + " among " + strings.Join(names, "; ")})
+	}
+
+	for _, model := range library.Parts {
+		if documents[model.DocumentID] && !referenced[model] {
+			results = append(results, Result{Status: StatusOrphaned, Model: model, Details: "no catalog row matches it, although rows use its document"})
+		}
+	}
+	return results
+}
+
+// findSKU returns the first model whose name, or the name of its configuration, includes the SKU as a
+// whole word.  The options of a product are normally configurations of a part named after the product
+// (see DefaultPartName and DefaultConfiguration), so only the configuration has the SKU
+func findSKU(models []*Part, sku string) *Part {
+	sku = strings.ToUpper(strings.TrimSpace(sku))
+	if sku == "" {
+		return nil
+	}
+	for _, model := range models {
+		if containsWord(strings.ToUpper(model.Name), sku) || containsWord(strings.ToUpper(model.Configuration), sku) {
+			return model
+		}
+	}
+	return nil
+}
+
+// containsWord reports whether word is in s without being part of a longer part number (so 1-1 isn't
+// found in 1-10 or 1-1-2)
+func containsWord(s, word string) bool {
+	partOfSKU := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' }
+	for start := 0; ; {
+		i := strings.Index(s[start:], word)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(word)
+		before := i == 0 || !partOfSKU(rune(s[i-1]))
+		after := end == len(s) || !partOfSKU(rune(s[end]))
+		if before && after {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+// Failed reports whether any catalog row has a problem, as opposed to the library having orphans
+func Failed(results []Result) bool {
+	return slices.ContainsFunc(results, func(result Result) bool {
+		return result.Status != StatusOK && result.Status != StatusOrphaned
+	})
+}
+
+// WriteReport writes the results as a backtick separated file
+func WriteReport(w io.Writer, results []Result) error {
+	if _, err := fmt.Fprintln(w, "Status`Row`Part #`Name`Onshape URL`Document`Element`Model`Details"); err != nil {
+		return err
+	}
+	for _, result := range results {
+		var row, sku, name, onshapeURL, document, element, model string
+		if result.Part != nil {
+			row = fmt.Sprint(result.Part.Row)
+			sku, name, onshapeURL = result.Part.SKU, result.Part.Name, result.Part.OnshapeURL
+		}
+		if result.Model != nil {
+			document, element, model = result.Model.DocumentID, result.Model.ElementID, result.Model.label()
+			if result.Model.DocumentName != "" {
+				document = result.Model.DocumentName + " (" + result.Model.DocumentID + ")"
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s`%s`%s`%s`%s`%s`%s`%s`%s\n", result.Status, row, sku, name, onshapeURL, document, element, model, result.Details); err != nil {
+			return err
+		}
+	}
+	return nil
+}
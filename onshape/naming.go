@@ -0,0 +1,182 @@
+package onshape
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// The templates used for any of Naming which aren't given
+const (
+	DefaultFolder        = `{{.Vendor}}/{{join .Sections "/"}}`
+	DefaultPartName      = `{{if .Variant}}{{.BaseName}}{{else}}{{.SKU}} {{.Name}}{{end}}`
+	DefaultConfiguration = `{{if .Variant}}{{.SKU}} {{.Values}}{{end}}`
+)
+
+// Naming holds the text/template templates which propose where the model of a new part goes in the
+// library and what it is called.  The templates are given a NamingData
+type Naming struct {
+	// Vendor is the vendor's name as it is used for the top folder of the library (e.g. "goBILDA")
+	Vendor string
+	// Folder is the folder path of the document, separated by /
+	Folder string
+	// PartName is the name of the part in the document
+	PartName string
+	// Configuration names the configuration of the part for one of the options of a product.  It is
+	// normally empty for a part without options
+	Configuration string
+}
+
+// NamingData is what the templates can use
+type NamingData struct {
+	Vendor   string
+	Section  string   // The section as it is in the catalog, e.g. "MOTION > Gears > Spur Gears"
+	Sections []string // The levels of the section, cleaned up to be used as folder names
+	Name     string
+	BaseName string // The name of the product without the options, the Name when there aren't any
+	SKU      string
+	Variant  []partcatalog.VariantAttribute
+	Options  map[string]string // The option values by name, e.g. {{index .Options "Length"}}
+	Values   string            // The option values, separated by commas
+}
+
+// Proposal is the proposed naming for the model of a part
+type Proposal struct {
+	Folder        string
+	PartName      string
+	Configuration string
+}
+
+// Namer proposes names from a compiled Naming
+type Namer struct {
+	vendor                          string
+	folder, partName, configuration *template.Template
+}
+
+// namingFuncs are the functions the templates can use besides the text/template ones
+var namingFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"clean": cleanName,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+}
+
+// NewNamer compiles the templates, using the default for any which aren't given
+func NewNamer(naming Naming) (*Namer, error) {
+	namer := &Namer{vendor: naming.Vendor}
+	for _, t := range []struct {
+		name, text, fallback string
+		result               **template.Template
+	}{
+		{"Folder", naming.Folder, DefaultFolder, &namer.folder},
+		{"PartName", naming.PartName, DefaultPartName, &namer.partName},
+		{"Configuration", naming.Configuration, DefaultConfiguration, &namer.configuration},
+	} {
+		text := t.text
+		if text == "" {
+			text = t.fallback
+		}
+		parsed, err := template.New(t.name).Funcs(namingFuncs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("bad %s naming template: %w", t.name, err)
+		}
+		*t.result = parsed
+	}
+	return namer, nil
+}
+
+// Data gives what the templates can use for a part
+func (namer *Namer) Data(part *partcatalog.PartData) NamingData {
+	data := NamingData{
+		Vendor:   namer.vendor,
+		Section:  part.Section,
+		Name:     cleanName(part.Name),
+		BaseName: cleanName(part.BaseName),
+		SKU:      strings.TrimSpace(part.SKU),
+		Variant:  part.Variant,
+		Options:  make(map[string]string),
+	}
+	if data.BaseName == "" {
+		data.BaseName = data.Name
+	}
+	for _, level := range strings.Split(part.Section, ">") {
+		if level = cleanName(strings.NewReplacer("/", "-", "\\", "-").Replace(level)); level != "" {
+			data.Sections = append(data.Sections, level)
+		}
+	}
+	values := make([]string, 0, len(part.Variant))
+	for _, option := range part.Variant {
+		data.Options[option.Name] = option.Value
+		values = append(values, option.Value)
+	}
+	data.Values = strings.Join(values, ", ")
+	return data
+}
+
+// Propose fills in the templates for a part
+func (namer *Namer) Propose(part *partcatalog.PartData) (Proposal, error) {
+	data := namer.Data(part)
+	var proposal Proposal
+	for _, t := range []struct {
+		template *template.Template
+		result   *string
+	}{
+		{namer.folder, &proposal.Folder},
+		{namer.partName, &proposal.PartName},
+		{namer.configuration, &proposal.Configuration},
+	} {
+		var sb strings.Builder
+		if err := t.template.Execute(&sb, data); err != nil {
+			return proposal, err
+		}
+		*t.result = cleanName(sb.String())
+	}
+	// Empty levels would come from an empty vendor or section
+	var folders []string
+	for _, folder := range strings.Split(proposal.Folder, "/") {
+		if folder = strings.TrimSpace(folder); folder != "" {
+			folders = append(folders, folder)
+		}
+	}
+	proposal.Folder = strings.Join(folders, "/")
+	return proposal, nil
+}
+
+// cleanName tidies the spaces in a name, which often has non-breaking or doubled spaces from the website
+func cleanName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// WriteNamingProposals writes the proposed naming for the New and Changed parts as a backtick
+// separated file, returning how many parts were written
+func WriteNamingProposals(path string, namer *Namer, parts []*partcatalog.PartData) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "Status`Section`Name`Part #`Folder`Part Name`Configuration")
+	count := 0
+	for _, part := range parts {
+		if part.SpiderStatus != partcatalog.NewPart && part.SpiderStatus != partcatalog.PartChanged {
+			continue
+		}
+		proposal, err := namer.Propose(part)
+		if err != nil {
+			return count, fmt.Errorf("naming %s: %w", part.SKU, err)
+		}
+		fmt.Fprintf(w, "%s`%s`%s`%s`%s`%s`%s\n", part.SpiderStatus, part.Section, part.Name, part.SKU,
+			proposal.Folder, proposal.PartName, proposal.Configuration)
+		count++
+	}
+	if err := w.Flush(); err != nil {
+		return count, err
+	}
+	return count, f.Close()
+}
@@ -0,0 +1,213 @@
+// Package onshape checks the Onshape models named in the catalog against a listing of our Onshape
+// library.  It works entirely from an exported file, so no Onshape API access is needed.
+//
+// The listing is either a JSON array of objects or a CSV file with a header line, giving for each part
+// of the library its documentId, elementId and name (a partId, documentName and configuration can also be
+// given).  A configurable part is listed once for each of its configurations, with the configuration's name.
+// The CSV headers are matched ignoring case, spaces and underscores, so "Document ID" works too.
+//
+// It also proposes how the models of new parts should be named in the library, from templates which
+// each vendor can set (see Naming).
+package onshape
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Host is where Onshape documents live
+const Host = "cad.onshape.com"
+
+// Ref picks out a document, and optionally a tab (element) within it, from an Onshape URL
+type Ref struct {
+	DocumentID    string
+	WorkspaceType string // w for a workspace, v for a version or m for a microversion
+	WorkspaceID   string
+	ElementID     string
+}
+
+// ParseURL reads a document URL such as https://cad.onshape.com/documents/<did>/w/<wid>/e/<eid>
+func ParseURL(raw string) (Ref, error) {
+	var ref Ref
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ref, err
+	}
+	if !strings.EqualFold(u.Hostname(), Host) {
+		return ref, fmt.Errorf("%q is not on %s", raw, Host)
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 || segments[0] != "documents" || !isID(segments[1]) {
+		return ref, fmt.Errorf("%q is not an Onshape document", raw)
+	}
+	ref.DocumentID = strings.ToLower(segments[1])
+	for i := 2; i+1 < len(segments); i += 2 {
+		id := strings.ToLower(segments[i+1])
+		if !isID(id) {
+			return ref, fmt.Errorf("%q has a bad ID %q", raw, segments[i+1])
+		}
+		switch segments[i] {
+		case "w", "v", "m":
+			ref.WorkspaceType, ref.WorkspaceID = segments[i], id
+		case "e":
+			ref.ElementID = id
+		}
+	}
+	return ref, nil
+}
+
+// isID reports whether s looks like an Onshape ID, which is 24 hexadecimal digits
+func isID(s string) bool {
+	if len(s) != 24 {
+		return false
+	}
+	for _, c := range strings.ToLower(s) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Part is one part of the library listing
+type Part struct {
+	DocumentID   string `json:"documentId"`
+	DocumentName string `json:"documentName,omitempty"`
+	ElementID    string `json:"elementId"`
+	PartID       string `json:"partId,omitempty"`
+	Name         string `json:"name"`
+	// Configuration is the name of the configuration when the part is configurable
+	Configuration string `json:"configuration,omitempty"`
+}
+
+// Library is the listing of the parts in our Onshape documents
+type Library struct {
+	Parts []*Part
+	// byDocument and byElement index the parts by their lower case IDs
+	byDocument map[string][]*Part
+	byElement  map[string][]*Part
+}
+
+// NewLibrary indexes a listing of parts
+func NewLibrary(parts []*Part) *Library {
+	library := &Library{Parts: parts, byDocument: make(map[string][]*Part), byElement: make(map[string][]*Part)}
+	for _, part := range parts {
+		part.DocumentID = strings.ToLower(strings.TrimSpace(part.DocumentID))
+		part.ElementID = strings.ToLower(strings.TrimSpace(part.ElementID))
+		part.Name = strings.TrimSpace(part.Name)
+		part.Configuration = strings.TrimSpace(part.Configuration)
+		library.byDocument[part.DocumentID] = append(library.byDocument[part.DocumentID], part)
+		if part.ElementID != "" {
+			library.byElement[part.DocumentID+"/"+part.ElementID] = append(library.byElement[part.DocumentID+"/"+part.ElementID], part)
+		}
+	}
+	return library
+}
+
+// Find gives the parts in the document (or the tab of it) that a reference points at, and false when
+// the document or tab isn't in the library
+func (library *Library) Find(ref Ref) ([]*Part, bool) {
+	if ref.ElementID != "" {
+		parts, found := library.byElement[ref.DocumentID+"/"+ref.ElementID]
+		return parts, found
+	}
+	parts, found := library.byDocument[ref.DocumentID]
+	return parts, found
+}
+
+// LoadLibrary reads an exported listing, as JSON when the file ends in .json and as CSV otherwise
+func LoadLibrary(path string) (*Library, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var parts []*Part
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		parts, err = readJSON(f)
+	} else {
+		parts, err = readCSV(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Onshape library %s. Caused by: %v", path, err)
+	}
+	return NewLibrary(parts), nil
+}
+
+// readJSON reads a JSON array of parts
+func readJSON(r io.Reader) ([]*Part, error) {
+	var parts []*Part
+	if err := json.NewDecoder(r).Decode(&parts); err != nil {
+		return nil, err
+	}
+	for i, part := range parts {
+		if part.DocumentID == "" || part.Name == "" {
+			return nil, fmt.Errorf("entry %d has no documentId or name", i+1)
+		}
+	}
+	return parts, nil
+}
+
+// readCSV reads a CSV listing, finding the columns by their headers
+func readCSV(r io.Reader) ([]*Part, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	columns := make(map[string]int)
+	for i, header := range records[0] {
+		key := strings.NewReplacer(" ", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(header)))
+		switch key {
+		case "partname":
+			key = "name"
+		case "configurationname":
+			key = "configuration"
+		}
+		columns[key] = i
+	}
+	for _, required := range []string{"documentid", "name"} {
+		if _, found := columns[required]; !found {
+			return nil, fmt.Errorf("there is no %s column", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, found := columns[name]; found && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	var parts []*Part
+	for _, record := range records[1:] {
+		part := &Part{
+			DocumentID:    field(record, "documentid"),
+			DocumentName:  field(record, "documentname"),
+			ElementID:     field(record, "elementid"),
+			PartID:        field(record, "partid"),
+			Name:          field(record, "name"),
+			Configuration: field(record, "configuration"),
+		}
+		if strings.TrimSpace(part.DocumentID) != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts, nil
+}
+
+// label names the part for a report, along with its configuration when it has one
+func (part *Part) label() string {
+	if part.Configuration == "" {
+		return part.Name
+	}
+	return part.Name + " [" + part.Configuration + "]"
+}
@@ -0,0 +1,132 @@
+package onshape
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+const testDocument = "https://cad.onshape.com/documents/0123456789abcdef01234567/w/1111111111111111aaaaaaaa/e/"
+
+func TestParseURL(t *testing.T) {
+	ref, err := ParseURL(testDocument + "AAAAAAAAAAAAAAAAAAAAAAAA?renderMode=0")
+	want := Ref{"0123456789abcdef01234567", "w", "1111111111111111aaaaaaaa", "aaaaaaaaaaaaaaaaaaaaaaaa"}
+	if err != nil || ref != want {
+		t.Errorf("got %+v, %v expected %+v", ref, err, want)
+	}
+	for _, bad := range []string{
+		"https://www.onshape.com/en/",
+		"https://cad.onshape.com/documents/1234",
+		testDocument + "zz",
+	} {
+		if _, err := ParseURL(bad); err == nil {
+			t.Errorf("%s was accepted", bad)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, file := range []string{"testdata/library.json", "testdata/library.csv"} {
+		library, err := LoadLibrary(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := []*partcatalog.PartData{
+			{Row: 2, SKU: "REV-41-1300", OnshapeURL: testDocument + "aaaaaaaaaaaaaaaaaaaaaaaa"},
+			{Row: 3, SKU: "REV-41-130", OnshapeURL: testDocument + "aaaaaaaaaaaaaaaaaaaaaaaa"},
+			{Row: 4, SKU: "REV-41-1350", OnshapeURL: testDocument + "dddddddddddddddddddddddd"},
+			{Row: 5, SKU: "REV-41-1360", Status: "Done"},
+			{Row: 6, SKU: "REV-41-1370", OnshapeURL: "https://cad.onshape.com/help"},
+		}
+		var got []string
+		for _, result := range Check(parts, library, true) {
+			if result.Part != nil {
+				got = append(got, result.Status+" "+result.Part.SKU)
+			} else {
+				got = append(got, result.Status+" "+result.Model.Name)
+			}
+		}
+		want := []string{
+			"OK REV-41-1300",
+			"Mismatched REV-41-130",
+			"Missing REV-41-1350",
+			"Missing REV-41-1360",
+			"Bad URL REV-41-1370",
+			// The gear shares the document of REV-41-1300, but no row has its part number
+			"Orphaned REV-41-1301 Gear",
+			"Orphaned REV-41-1350 Spacer",
+			// Nothing points at the document of the bracket, which could belong to another vendor
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got\n%q\nexpected\n%q", file, got, want)
+		}
+	}
+}
+
+func TestCheckProposedNames(t *testing.T) {
+	namer, err := NewNamer(Naming{Vendor: "goBILDA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := []*partcatalog.PartData{
+		{Row: 2, SKU: "1309-0016-0008", BaseName: "Sonic Hub", Variant: []partcatalog.VariantAttribute{{Name: "Bore", Value: "8mm REX"}}},
+		{Row: 3, SKU: "1309-0016-0006", BaseName: "Sonic Hub", Variant: []partcatalog.VariantAttribute{{Name: "Bore", Value: "6mm D"}}},
+		{Row: 4, SKU: "2100-0001-0002", Name: "Grid Plate"},
+	}
+	// The library is modelled as proposed: the options are configurations of a part named for the product
+	listing := "Document ID,Element ID,Part Name,Configuration Name\n"
+	for _, part := range parts {
+		part.OnshapeURL = testDocument + "aaaaaaaaaaaaaaaaaaaaaaaa"
+		proposal, err := namer.Propose(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listing += "0123456789abcdef01234567,aaaaaaaaaaaaaaaaaaaaaaaa," + proposal.PartName + ",\"" + proposal.Configuration + "\"\n"
+	}
+	models, err := readCSV(strings.NewReader(listing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range Check(parts, NewLibrary(models), true) {
+		if result.Status != StatusOK {
+			t.Errorf("%s is %s: %s", result.Part.SKU, result.Status, result.Details)
+		}
+	}
+}
+
+func TestNaming(t *testing.T) {
+	namer, err := NewNamer(Naming{Vendor: "goBILDA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := &partcatalog.PartData{
+		Section: "MOTION > Hubs/Collars > Sonic  Hubs",
+		Name:    "Sonic Hub (8mm REX Bore) - 2 Pack",
+		SKU:     "1309-0016-0008",
+	}
+	got, err := namer.Propose(part)
+	want := Proposal{"goBILDA/MOTION/Hubs-Collars/Sonic Hubs", "1309-0016-0008 Sonic Hub (8mm REX Bore) - 2 Pack", ""}
+	if err != nil || got != want {
+		t.Errorf("got %+v, %v expected %+v", got, err, want)
+	}
+
+	part.BaseName = "Sonic Hub"
+	part.Variant = []partcatalog.VariantAttribute{{Name: "Bore", Value: "8mm REX"}, {Name: "Pack Size", Value: "2 Pack"}}
+	if got, _ = namer.Propose(part); got.PartName != "Sonic Hub" || got.Configuration != "1309-0016-0008 8mm REX, 2 Pack" {
+		t.Errorf("unexpected variant naming %+v", got)
+	}
+
+	namer, err = NewNamer(Naming{Vendor: "REV", PartName: `{{.BaseName}} ({{index .Options "Bore"}})`, Folder: `{{.Vendor}}/{{index .Sections 0 | lower}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = namer.Propose(part); got.PartName != "Sonic Hub (8mm REX)" || got.Folder != "REV/motion" {
+		t.Errorf("unexpected custom naming %+v", got)
+	}
+
+	if _, err := NewNamer(Naming{PartName: "{{.SKU"}); err == nil {
+		t.Errorf("bad template accepted")
+	}
+}
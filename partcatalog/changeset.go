@@ -0,0 +1,287 @@
+package partcatalog
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ChangeCategory says what kind of edit to the spreadsheet a change proposes
+type ChangeCategory string
+
+const (
+	// CategoryRename - the vendor has renamed the part
+	CategoryRename ChangeCategory = "rename"
+	// CategoryMove - the vendor has moved the part to another section
+	CategoryMove ChangeCategory = "move"
+	// CategoryURL - the part is on a different page of the website
+	CategoryURL ChangeCategory = "url update"
+	// CategorySKU - the vendor has changed the part number
+	CategorySKU ChangeCategory = "part number"
+	// CategoryNewRow - the part isn't in the catalog yet
+	CategoryNewRow ChangeCategory = "new row"
+	// CategoryRetire - the part is discontinued or no longer on the website
+	CategoryRetire ChangeCategory = "retire"
+)
+
+// RetiredMarker is added to the name of a retired part, which the matchers already ignore
+const RetiredMarker = "[DISCONTINUED]"
+
+// SheetChange is one proposed edit to a cell of the catalog spreadsheet.  A new row is proposed as
+// several changes with Row 0, one for each of its columns, tied together by the SKU
+type SheetChange struct {
+	Category ChangeCategory
+	Row      int    // Row of the spreadsheet (the header is row 1), 0 for a new row
+	SKU      string // Part number of the row, to help find it
+	Column   string // Standard header of the column to change
+	Old      string // What the cell holds now
+	New      string // What it should be changed to
+}
+
+// ChangeSetOptions control what BuildChangeSet proposes
+type ChangeSetOptions struct {
+	// MovedURLs maps catalog URLs which now redirect to where they redirect, for parts whose URL was
+	// updated without a note
+	MovedURLs map[string]string
+	// Retire proposes retiring the catalog parts which the spider didn't find.  It should only be used
+	// after a run which crawled the whole site.  A part whose URL is in MovedURLs gets the URL update
+	// instead, since it may well be on the new page
+	Retire bool
+}
+
+// BuildChangeSet turns the results of a run into the edits that would bring the catalog up to date.
+// parts are all the parts output by the run, which the matchers have tied to their catalog rows.
+// The changes are in row order with the new rows last
+func BuildChangeSet(catalog *PartCatalogData, parts []*PartData, opts ChangeSetOptions) []SheetChange {
+	rows := make(map[int]*PartData)
+	for _, part := range catalog.Partdata {
+		rows[part.Row] = part
+	}
+
+	var result []SheetChange
+	seen := make(map[SheetChange]bool)
+	propose := func(change SheetChange) {
+		if change.Old != change.New && !seen[change] {
+			seen[change] = true
+			result = append(result, change)
+		}
+	}
+
+	for _, part := range parts {
+		row, found := rows[part.Row]
+		if part.Row == 0 || !found {
+			if part.SpiderStatus == NewPart && part.SKU != "" {
+				for _, column := range []struct{ name, value string }{
+					{ColumnSection, part.Section},
+					{ColumnName, part.Name},
+					{ColumnSKU, part.SKU},
+					{ColumnURL, part.URL},
+					{ColumnModelURL, part.ModelURL},
+				} {
+					if column.value != "" {
+						propose(SheetChange{CategoryNewRow, 0, part.SKU, column.name, "", column.value})
+					}
+				}
+			}
+			continue
+		}
+		if slices.Contains(catalog.ExcludeFromSearch, row) {
+			continue
+		}
+
+		switch part.SpiderStatus {
+		case PartNotFoundBySpider:
+			if _, moved := opts.MovedURLs[row.URL]; opts.Retire && !moved && !strings.Contains(row.Name, RetiredMarker) {
+				propose(SheetChange{CategoryRetire, row.Row, row.SKU, ColumnName, row.Name, row.Name + " " + RetiredMarker})
+			}
+		case DiscontinuedPart:
+			if !strings.Contains(row.Name, RetiredMarker) {
+				propose(SheetChange{CategoryRetire, row.Row, row.SKU, ColumnName, row.Name, row.Name + " " + RetiredMarker})
+			}
+		}
+
+		urlNoted := false
+		for _, change := range part.Changes() {
+			switch change.Field {
+			case "Name":
+				propose(SheetChange{CategoryRename, row.Row, row.SKU, ColumnName, row.Name, strings.TrimSpace(change.New)})
+			case "Section":
+				propose(SheetChange{CategoryMove, row.Row, row.SKU, ColumnSection, row.Section, strings.TrimSpace(change.New)})
+			case "SKU":
+				propose(SheetChange{CategorySKU, row.Row, row.SKU, ColumnSKU, row.SKU, part.SKU})
+			case "URL":
+				urlNoted = true
+				propose(SheetChange{CategoryURL, row.Row, row.SKU, ColumnURL, row.URL, part.URL})
+			}
+		}
+		if newURL, moved := opts.MovedURLs[row.URL]; moved && !urlNoted {
+			propose(SheetChange{CategoryURL, row.Row, row.SKU, ColumnURL, row.URL, newURL})
+		}
+	}
+
+	slices.SortStableFunc(result, func(a, b SheetChange) int {
+		switch {
+		case a.Row == b.Row:
+			return 0
+		case a.Row == 0:
+			return 1
+		case b.Row == 0:
+			return -1
+		}
+		return a.Row - b.Row
+	})
+	return result
+}
+
+// changeSetHeader is the first line of a change set CSV file
+var changeSetHeader = []string{"Category", "Row", "Part #", "Column", "Old", "New"}
+
+// WriteChangeSetCSV writes the changes as a CSV file for review.  Lines can be deleted (or the New
+// value edited) before it is applied
+func WriteChangeSetCSV(w io.Writer, changes []SheetChange) error {
+	cw := csv.NewWriter(w)
+	cw.Write(changeSetHeader)
+	for _, change := range changes {
+		row := ""
+		if change.Row > 0 {
+			row = strconv.Itoa(change.Row)
+		}
+		cw.Write([]string{string(change.Category), row, change.SKU, change.Column, change.Old, change.New})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadChangeSetCSV reads back a file written by WriteChangeSetCSV
+func ReadChangeSetCSV(r io.Reader) ([]SheetChange, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || !slices.Equal(records[0], changeSetHeader) {
+		return nil, fmt.Errorf("not a change set: the header should be %s", strings.Join(changeSetHeader, ","))
+	}
+	var result []SheetChange
+	for i, record := range records[1:] {
+		change := SheetChange{ChangeCategory(record[0]), 0, record[2], record[3], record[4], record[5]}
+		if record[1] != "" {
+			if change.Row, err = strconv.Atoi(record[1]); err != nil || change.Row < 2 {
+				return nil, fmt.Errorf("line %d: bad row %q", i+2, record[1])
+			}
+		}
+		if _, known := columnSetters[change.Column]; !known {
+			return nil, fmt.Errorf("line %d: unknown column %q", i+2, change.Column)
+		}
+		result = append(result, change)
+	}
+	return result, nil
+}
+
+// columnSetters update the columns which a change can be made to
+var columnSetters = map[string]func(part *PartData, value string){
+	ColumnSection:  func(part *PartData, value string) { part.Section = value },
+	ColumnName:     func(part *PartData, value string) { part.Name = value },
+	ColumnSKU:      func(part *PartData, value string) { part.SKU = value },
+	ColumnURL:      func(part *PartData, value string) { part.URL = value },
+	ColumnModelURL: func(part *PartData, value string) { part.ModelURL = value },
+	ColumnStatus:   func(part *PartData, value string) { part.Status = value },
+	ColumnNotes:    func(part *PartData, value string) { part.Notes = value },
+}
+
+// columnValue gives the value of a column which can be changed
+func columnValue(part *PartData, column string) string {
+	switch column {
+	case ColumnSection:
+		return part.Section
+	case ColumnName:
+		return part.Name
+	case ColumnSKU:
+		return part.SKU
+	case ColumnURL:
+		return part.URL
+	case ColumnModelURL:
+		return part.ModelURL
+	case ColumnStatus:
+		return part.Status
+	case ColumnNotes:
+		return part.Notes
+	}
+	return ""
+}
+
+// ApplyChangeSet makes the changes to the parts of a catalog snapshot, adding any new rows at the end.
+// A change is skipped when its row no longer holds the Old value (the sheet was edited since), when
+// another change sets the same cell differently, or when a new row's part number is already in the
+// catalog.  It returns how many changes were made along with why each skipped one was skipped
+func ApplyChangeSet(snapshot *Snapshot, changes []SheetChange) (applied int, skipped []error) {
+	rows := make(map[int]*PartData)
+	skus := make(map[string]bool)
+	lastRow := 1
+	for _, part := range snapshot.Parts {
+		rows[part.Row] = part
+		skus[strings.TrimSpace(part.SKU)] = true
+		lastRow = max(lastRow, part.Row)
+	}
+
+	// Find the cells which more than one change wants to set
+	type cell struct {
+		row    int
+		sku    string
+		column string
+	}
+	values := make(map[cell]string)
+	conflicts := make(map[cell]bool)
+	for _, change := range changes {
+		key := cell{change.Row, change.SKU, change.Column}
+		if change.Row > 0 {
+			key.sku = ""
+		}
+		if value, found := values[key]; found && value != change.New {
+			conflicts[key] = true
+		}
+		values[key] = change.New
+	}
+
+	added := make(map[string]*PartData)
+	for _, change := range changes {
+		key := cell{change.Row, change.SKU, change.Column}
+		if change.Row > 0 {
+			key.sku = ""
+		}
+		if conflicts[key] {
+			skipped = append(skipped, fmt.Errorf("row %d %s: more than one change for %s", change.Row, change.SKU, change.Column))
+			continue
+		}
+		if change.Row == 0 {
+			if skus[strings.TrimSpace(change.SKU)] {
+				skipped = append(skipped, fmt.Errorf("new row %s: the part number is already in the catalog", change.SKU))
+				continue
+			}
+			part, found := added[change.SKU]
+			if !found {
+				lastRow++
+				part = &PartData{Row: lastRow, SKU: change.SKU, SpiderStatus: PartNotFoundBySpider}
+				added[change.SKU] = part
+				snapshot.Parts = append(snapshot.Parts, part)
+			}
+			columnSetters[change.Column](part, change.New)
+			applied++
+			continue
+		}
+		part, found := rows[change.Row]
+		if !found {
+			skipped = append(skipped, fmt.Errorf("row %d %s: not in the catalog", change.Row, change.SKU))
+			continue
+		}
+		if current := columnValue(part, change.Column); current != change.Old {
+			skipped = append(skipped, fmt.Errorf("row %d %s: %s is now %q rather than %q", change.Row, change.SKU, change.Column, current, change.Old))
+			continue
+		}
+		columnSetters[change.Column](part, change.New)
+		applied++
+	}
+	return applied, skipped
+}
@@ -0,0 +1,75 @@
+package partcatalog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestChangeSet(t *testing.T) {
+	catalog := NewPartCatalogData()
+	gear := &PartData{Row: 2, Section: "MOTION > Gears", Name: "Gear", SKU: "1-1", URL: "https://www.example.com/gear", SpiderStatus: PartNotFoundBySpider}
+	belt := &PartData{Row: 3, Section: "MOTION > Belts", Name: "Belt", SKU: "1-2", URL: "https://www.example.com/belt", SpiderStatus: PartNotFoundBySpider}
+	hub := &PartData{Row: 4, Section: "MOTION > Hubs", Name: "Hub", SKU: "1-3", URL: "https://www.example.com/old-hub", SpiderStatus: PartNotFoundBySpider}
+	// The spider didn't find the shaft, but its URL redirects so it may just have moved
+	shaft := &PartData{Row: 5, Section: "MOTION > Shafts", Name: "Shaft", SKU: "1-5", URL: "https://www.example.com/old-shaft", SpiderStatus: PartNotFoundBySpider}
+	for _, part := range []*PartData{gear, belt, hub, shaft} {
+		catalog.addPart(part, nil)
+	}
+
+	// What the matchers leave for the parts found on the website
+	parts := []*PartData{
+		{Row: 2, Section: "MOTION > Gears", Name: "Gear", SKU: "1-1", URL: "https://www.example.com/spur-gear",
+			SpiderStatus: PartChanged, Notes: "New Name:Spur Gear,  Old URL:https://www.example.com/gear"},
+		{Row: 4, Section: "MOTION > Hubs", Name: "Hub", SKU: "1-3", URL: "https://www.example.com/hub", SpiderStatus: UnchangedPart},
+		{Section: "MOTION > Gears", Name: "Pinion", SKU: "1-4", URL: "https://www.example.com/pinion", SpiderStatus: NewPart},
+		belt,
+		shaft,
+	}
+	changes := BuildChangeSet(catalog, parts, ChangeSetOptions{
+		MovedURLs: map[string]string{
+			"https://www.example.com/old-hub":   "https://www.example.com/hub",
+			"https://www.example.com/old-shaft": "https://www.example.com/shaft",
+		},
+		Retire: true,
+	})
+	want := []SheetChange{
+		{CategoryRename, 2, "1-1", ColumnName, "Gear", "Spur Gear"},
+		{CategoryURL, 2, "1-1", ColumnURL, "https://www.example.com/gear", "https://www.example.com/spur-gear"},
+		{CategoryRetire, 3, "1-2", ColumnName, "Belt", "Belt [DISCONTINUED]"},
+		{CategoryURL, 4, "1-3", ColumnURL, "https://www.example.com/old-hub", "https://www.example.com/hub"},
+		{CategoryURL, 5, "1-5", ColumnURL, "https://www.example.com/old-shaft", "https://www.example.com/shaft"},
+		{CategoryNewRow, 0, "1-4", ColumnSection, "", "MOTION > Gears"},
+		{CategoryNewRow, 0, "1-4", ColumnName, "", "Pinion"},
+		{CategoryNewRow, 0, "1-4", ColumnSKU, "", "1-4"},
+		{CategoryNewRow, 0, "1-4", ColumnURL, "", "https://www.example.com/pinion"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("got changes\n%+v\nexpected\n%+v", changes, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteChangeSetCSV(&buf, changes); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadChangeSetCSV(&buf)
+	if err != nil || !reflect.DeepEqual(read, changes) {
+		t.Fatalf("change set didn't survive CSV: %v\n%+v", err, read)
+	}
+
+	// The belt was renamed in the sheet after the run, so retiring it is skipped
+	snapshot := &Snapshot{Parts: []*PartData{
+		{Row: 2, Name: "Gear", SKU: "1-1", URL: "https://www.example.com/gear"},
+		{Row: 3, Name: "Timing Belt", SKU: "1-2"},
+		{Row: 4, Name: "Hub", SKU: "1-3", URL: "https://www.example.com/old-hub"},
+		{Row: 5, Name: "Shaft", SKU: "1-5", URL: "https://www.example.com/old-shaft"},
+	}}
+	applied, skipped := ApplyChangeSet(snapshot, read)
+	if applied != 8 || len(skipped) != 1 {
+		t.Errorf("applied %d and skipped %v", applied, skipped)
+	}
+	added := snapshot.Parts[4]
+	if snapshot.Parts[0].Name != "Spur Gear" || added.Row != 6 || added.Name != "Pinion" || added.URL != "https://www.example.com/pinion" {
+		t.Errorf("unexpected catalog %+v %+v", snapshot.Parts[0], added)
+	}
+}
@@ -0,0 +1,98 @@
+package partcatalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// sheetsScope is the access the spider needs to the catalog spreadsheets.
+// If modifying it, delete your previously saved token.json.
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets.readonly"
+
+// ErrNoToken is returned when there is no saved OAuth token and we aren't allowed to ask for one
+var ErrNoToken = errors.New("no usable OAuth token")
+
+// Credentials says how to authenticate with Google to read the catalog spreadsheets
+type Credentials struct {
+	// File is either a service account key or an OAuth client secret (credentials.json)
+	File string
+	// TokenFile keeps the user's OAuth token when File is a client secret
+	TokenFile string
+	// Interactive allows asking for an authorization code on the terminal when there is no saved token.
+	// Without it a missing token is an error, so that a scheduled run never hangs waiting for input
+	Interactive bool
+}
+
+// DefaultCredentials finds the credentials from the environment.  FTC_SPIDER_CREDENTIALS names the
+// credentials file, falling back to GOOGLE_APPLICATION_CREDENTIALS and then credentials.json in the
+// working directory.  FTC_SPIDER_TOKEN names the token file (token.json by default).  Asking for an
+// authorization code is only allowed when stdin is a terminal
+func DefaultCredentials() Credentials {
+	creds := Credentials{File: "credentials.json", TokenFile: "token.json", Interactive: isTerminal(os.Stdin)}
+	for _, name := range []string{"FTC_SPIDER_CREDENTIALS", "GOOGLE_APPLICATION_CREDENTIALS"} {
+		if file := os.Getenv(name); file != "" {
+			creds.File = file
+			break
+		}
+	}
+	if file := os.Getenv("FTC_SPIDER_TOKEN"); file != "" {
+		creds.TokenFile = file
+	}
+	return creds
+}
+
+// isTerminal reports whether the file is attached to a terminal rather than a pipe or file.  cron and
+// most containers give us /dev/null, which is also a character device, so it is checked for separately
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(fi, null) {
+		return false
+	}
+	return true
+}
+
+// Client creates an HTTP client authorized to read the spreadsheets.  A service account key (or the
+// authorized_user file written by gcloud) is used directly, while an OAuth client secret needs the
+// user's saved token
+func (c Credentials) Client(ctx context.Context) (*http.Client, error) {
+	b, err := os.ReadFile(c.File)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials file (set -credentials or FTC_SPIDER_CREDENTIALS). Caused by: %v", err)
+	}
+	var kind struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &kind); err != nil {
+		return nil, fmt.Errorf("unable to parse credentials file %s. Caused by: %v", c.File, err)
+	}
+
+	switch kind.Type {
+	case "":
+		// An OAuth client secret, handled below
+	case "service_account", "authorized_user":
+		// These carry everything needed to get a token
+		creds, err := google.CredentialsFromJSON(ctx, b, sheetsScope)
+		if err != nil {
+			return nil, fmt.Errorf("unable to use %s credentials from %s. Caused by: %v", kind.Type, c.File, err)
+		}
+		return oauth2.NewClient(ctx, creds.TokenSource), nil
+	default:
+		return nil, fmt.Errorf("credentials file %s holds %s credentials, which aren't supported", c.File, kind.Type)
+	}
+
+	config, err := google.ConfigFromJSON(b, sheetsScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client secret file to config. Caused by: %v", err)
+	}
+	return getClient(config, c.TokenFile, c.Interactive)
+}
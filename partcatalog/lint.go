@@ -0,0 +1,177 @@
+package partcatalog
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Severity says how serious a problem found by LintCatalog is
+type Severity int
+
+const (
+	// SeverityWarning - something which looks wrong but doesn't stop the part being matched
+	SeverityWarning Severity = 0
+	// SeverityError - something which stops the part being matched against the website
+	SeverityError Severity = 1
+)
+
+func (severity Severity) String() string {
+	if severity == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// LintIssue is one data quality problem in the catalog
+type LintIssue struct {
+	Row      int      // Row of the spreadsheet, counting the header as row 1
+	Severity Severity // How serious the problem is
+	Column   string   // Standard header of the column with the problem
+	SKU      string   // Part number of the row, to help find it
+	Message  string   // What is wrong
+}
+
+func (issue LintIssue) String() string {
+	return fmt.Sprintf("row %d: %s: %s: %s", issue.Row, issue.Severity, issue.Column, issue.Message)
+}
+
+// LintOptions control the checks made by LintCatalog
+type LintOptions struct {
+	// Sections are the sections known for the vendor (typically from the last run).  A part whose section
+	// is neither one of them nor the start of one is reported.  The check is skipped when there are none
+	Sections []string
+}
+
+// LintCatalog checks every part of the catalog for problems which stop it being matched against the
+// website or make the spreadsheet harder to maintain.  The issues are returned in row order.
+// Blank rows are ignored, and parts excluded from matching are only checked for malformed URLs
+func LintCatalog(catalog *PartCatalogData, opts LintOptions) []LintIssue {
+	var issues []LintIssue
+	report := func(part *PartData, severity Severity, column string, format string, args ...any) {
+		issues = append(issues, LintIssue{part.Row, severity, column, part.SKU, fmt.Sprintf(format, args...)})
+	}
+
+	sections := make(map[string]bool)
+	for _, section := range opts.Sections {
+		// A section in the catalog may be a parent of where the spider found parts
+		for section != "" {
+			sections[section] = true
+			parent, _, found := cutLast(section, " > ")
+			if !found {
+				break
+			}
+			section = parent
+		}
+	}
+
+	seen := make(map[string]*PartData)
+	for _, part := range catalog.Partdata {
+		if isBlankRow(part) {
+			continue
+		}
+		excluded := slices.Contains(catalog.ExcludeFromSearch, part)
+
+		if msg := checkURL(part.URL); msg != "" {
+			report(part, SeverityError, ColumnURL, "%s", msg)
+		}
+		if msg := checkURL(part.ModelURL); msg != "" {
+			report(part, SeverityWarning, ColumnModelURL, "%s", msg)
+		}
+		if msg := checkOnshapeURL(part.OnshapeURL); msg != "" {
+			report(part, SeverityError, ColumnOnshapeURL, "%s", msg)
+		}
+		if excluded {
+			continue
+		}
+
+		if strings.TrimSpace(part.Name) == "" {
+			report(part, SeverityError, ColumnName, "name is blank")
+		} else if part.Name != strings.TrimSpace(part.Name) {
+			report(part, SeverityWarning, ColumnName, "name %q has spaces around it", part.Name)
+		}
+
+		switch {
+		case strings.TrimSpace(part.SKU) == "":
+			report(part, SeverityError, ColumnSKU, "part number is blank")
+		case part.SKU != strings.TrimSpace(part.SKU):
+			report(part, SeverityError, ColumnSKU, "part number %q has spaces around it", part.SKU)
+		case strings.IndexFunc(part.SKU, unicode.IsSpace) >= 0:
+			report(part, SeverityWarning, ColumnSKU, "part number %q has spaces in it", part.SKU)
+		}
+		if part.SKU != "" {
+			if dup, found := seen[part.SKU]; found {
+				report(part, SeverityError, ColumnSKU, "duplicate part number, first used on row %d", dup.Row)
+			} else {
+				seen[part.SKU] = part
+			}
+		}
+
+		if part.URL == "" {
+			report(part, SeverityWarning, ColumnURL, "URL is blank")
+		}
+
+		switch {
+		case strings.TrimSpace(part.Section) == "":
+			report(part, SeverityWarning, ColumnSection, "section is blank")
+		case len(sections) > 0 && !sections[part.Section]:
+			report(part, SeverityWarning, ColumnSection, "section %q isn't one of the vendor's sections", part.Section)
+		}
+	}
+
+	slices.SortStableFunc(issues, func(a, b LintIssue) int {
+		if a.Row != b.Row {
+			return a.Row - b.Row
+		}
+		return int(b.Severity) - int(a.Severity)
+	})
+	return issues
+}
+
+// isBlankRow reports whether a row of the spreadsheet has nothing in it
+func isBlankRow(part *PartData) bool {
+	return strings.TrimSpace(part.Name+part.SKU+part.URL+part.Section+part.ModelURL+part.OnshapeURL) == ""
+}
+
+// checkURL returns what is wrong with a URL from the catalog, or "" when it is fine or blank
+func checkURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	if raw != strings.TrimSpace(raw) {
+		return fmt.Sprintf("URL %q has spaces around it", raw)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Sprintf("URL %q is malformed: %v", raw, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Sprintf("URL %q is not a web address", raw)
+	}
+	if strings.ContainsAny(raw, " \t\n") {
+		return fmt.Sprintf("URL %q has spaces in it", raw)
+	}
+	return ""
+}
+
+// checkOnshapeURL returns what is wrong with an Onshape URL, which has to be a cad.onshape.com document
+func checkOnshapeURL(raw string) string {
+	if msg := checkURL(raw); msg != "" || raw == "" {
+		return msg
+	}
+	u, _ := url.Parse(raw)
+	if !strings.EqualFold(u.Hostname(), "cad.onshape.com") || !strings.HasPrefix(u.Path, "/documents/") {
+		return fmt.Sprintf("%q is not an Onshape document on cad.onshape.com", raw)
+	}
+	return ""
+}
+
+// cutLast is strings.Cut on the last occurrence of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,37 @@
+package partcatalog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintCatalog(t *testing.T) {
+	catalog := NewPartCatalogData()
+	for _, part := range []*PartData{
+		{Row: 2, Section: "MOTION > Gears", Name: "Gear", SKU: "1-1", URL: "https://www.example.com/gear",
+			OnshapeURL: "https://cad.onshape.com/documents/0123456789abcdef01234567/w/0123456789abcdef01234567/e/0123456789abcdef01234567"},
+		{Row: 3, Section: "MOTION > Gears", Name: "", SKU: " 1-2", URL: "www.example.com/gear"},
+		{Row: 4},
+		{Row: 5, Section: "MOTION > Belts", Name: "Belt", SKU: "1-1", URL: "https://www.example.com/belt",
+			OnshapeURL: "https://www.onshape.com/en/"},
+		{Row: 6, Section: "STRUCTURE", Name: "--Header", SKU: "(No Part Number)", URL: "https://www.example.com/structure"},
+	} {
+		catalog.addPart(part, func(part *PartData) bool { return part.Name == "--Header" })
+	}
+
+	var got []string
+	for _, issue := range LintCatalog(catalog, LintOptions{Sections: []string{"MOTION > Gears > Spur"}}) {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`row 3: error: URL: URL "www.example.com/gear" is not a web address`,
+		`row 3: error: Name: name is blank`,
+		`row 3: error: Part #: part number " 1-2" has spaces around it`,
+		`row 5: error: Onshape URL: "https://www.onshape.com/en/" is not an Onshape document on cad.onshape.com`,
+		`row 5: error: Part #: duplicate part number, first used on row 2`,
+		`row 5: warning: Section: section "MOTION > Belts" isn't one of the vendor's sections`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got issues\n%q\nexpected\n%q", got, want)
+	}
+}
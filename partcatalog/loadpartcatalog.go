@@ -5,30 +5,37 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/sheets/v4"
 )
 
 // https://developers.google.com/sheets/api/quickstart/go
 // Retrieve a token, saves the token, then returns the generated client.
-func getClient(config *oauth2.Config) *http.Client {
-	// The file token.json stores the user's access and refresh tokens, and is
+// When there is no saved token and we can't ask for one, it fails rather than waiting on stdin
+func getClient(config *oauth2.Config, tokFile string, interactive bool) (*http.Client, error) {
+	// The token file stores the user's access and refresh tokens, and is
 	// created automatically when the authorization flow completes for the first
 	// time.
-	tokFile := "token.json"
 	tok, err := tokenFromFile(tokFile)
 	if err != nil {
+		if !interactive {
+			return nil, fmt.Errorf("%w in %s: run once from a terminal to create it, or use a service account key", ErrNoToken, tokFile)
+		}
 		tok, err = getTokenFromWeb(config)
-		if err == nil {
-			saveToken(tokFile, tok)
+		if err != nil {
+			return nil, err
+		}
+		if err := saveToken(tokFile, tok); err != nil {
+			slog.Warn("unable to save token", "path", tokFile, "err", err)
 		}
 	}
-	return config.Client(context.Background(), tok)
+	return config.Client(context.Background(), tok), nil
 }
 
 func getTokenFromWeb(config *oauth2.Config) (*oauth2.Token, error) {
@@ -69,7 +76,7 @@ func tokenFromFile(file string) (*oauth2.Token, error) {
 
 // Saves a token to a file path.
 func saveToken(path string, token *oauth2.Token) error {
-	fmt.Printf("Saving credential file to: %s\n", path)
+	slog.Info("saving credential file", "path", path)
 	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return fmt.Errorf("unable to cache oauth token. Caused by %v", err)
@@ -79,40 +86,46 @@ func saveToken(path string, token *oauth2.Token) error {
 	return nil
 }
 
+// LoadOptions control how the catalog spreadsheet is read
+type LoadOptions struct {
+	// Credentials are used to read the spreadsheet
+	Credentials Credentials
+	// Sheet is the layout of the spreadsheet
+	Sheet SheetConfig
+}
+
 // LoadPartCatalog -
 // Get Part# and URL from gobilda ALL spreadsheet:
 // https://docs.google.com/spreadsheets/d/15XT3v9O0VOmyxqXrgR8tWDyb_CRLQT5-xPfWPdbx4RM/edit
 func LoadPartCatalog(spreadsheetIDPtr *string, excludeFilter func(*PartData) bool) (*PartCatalogData, error) {
+	return LoadPartCatalogWithOptions(spreadsheetIDPtr, excludeFilter, LoadOptions{Credentials: DefaultCredentials()})
+}
+
+// LoadPartCatalogWithOptions is LoadPartCatalog with the credentials (and anything else about reading
+// the spreadsheet) given rather than taken from the environment
+func LoadPartCatalogWithOptions(spreadsheetIDPtr *string, excludeFilter func(*PartData) bool, opts LoadOptions) (*PartCatalogData, error) {
 
 	var referenceData = NewPartCatalogData()
 
 	if spreadsheetIDPtr == nil {
-		fmt.Println("No SpreadsheetID was give, so no spreadsheet laoded")
+		slog.Warn("no SpreadsheetID was given, so no spreadsheet loaded")
 		return referenceData, nil
 	}
 	spreadsheetID := *spreadsheetIDPtr
 
-	b, err := os.ReadFile("credentials.json")
-	if err != nil {
-		return nil, fmt.Errorf("unable to read client secret file. Caused by: %v", err)
-	}
-
-	// If modifying these scopes, delete your previously saved token.json.
-	config, err := google.ConfigFromJSON(b, "https://www.googleapis.com/auth/spreadsheets.readonly")
+	client, err := opts.Credentials.Client(context.Background())
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse client secret file to config. Caused by: %v", err)
+		return nil, err
 	}
-	client := getClient(config)
 
 	srv, err := sheets.New(client)
 	if err != nil {
 		return nil, err
 	}
 
-	readRange := "All"
-	response, err := srv.Spreadsheets.Values.Get(spreadsheetID, readRange).Do()
+	response, err := srv.Spreadsheets.Values.Get(spreadsheetID, opts.Sheet.readRange()).Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to find 'ALL' sheet in spreadsheet %s. Caused by: %v", spreadsheetID, err)
+		return nil, fmt.Errorf("unable to find '%s' sheet in spreadsheet %s. Caused by: %v", opts.Sheet.TabName(), spreadsheetID, err)
 	}
 
 	if len(response.Values) == 0 {
@@ -122,10 +135,13 @@ func LoadPartCatalog(spreadsheetIDPtr *string, excludeFilter func(*PartData) boo
 	referenceData.Partdata = make([]*PartData, 0, len(response.Values))
 	for ii, cols := range response.Values {
 		if ii == 0 {
-			getColumnIndexes(referenceData, cols)
+			if err := getColumnIndexes(referenceData, cols, opts.Sheet); err != nil {
+				return nil, err
+			}
 			continue // header row
 		}
 		partdata := getPartData(referenceData, cols)
+		partdata.Row = ii + 1
 		referenceData.addPart(partdata, excludeFilter)
 	}
 	return referenceData, nil
@@ -153,8 +169,8 @@ func getPartData(referenceData *PartCatalogData, cols []interface{}) *PartData {
 			partdata.ModelURL = col.(string)
 		case jj == referenceData.OnshapeURLColumnIndex:
 			partdata.OnshapeURL = col.(string)
-		case jj >= referenceData.ExtraColumnIndex && jj <= referenceData.ExtraColumnIndex+6:
-			partdata.Extra[jj-referenceData.ExtraColumnIndex] = col.(string)
+		case referenceData.extraFor(jj) >= 0:
+			partdata.Extra[referenceData.extraFor(jj)] = col.(string)
 		case jj == referenceData.StatusColumnIndex:
 			partdata.Status = col.(string)
 		case jj == referenceData.NotesColumnIndex:
@@ -165,43 +181,86 @@ func getPartData(referenceData *PartCatalogData, cols []interface{}) *PartData {
 	}
 	return partdata
 }
-func getColumnIndexes(referenceData *PartCatalogData, cols []interface{}) {
-	referenceData.OrderColumnIndex = -1
-	referenceData.SectionColumnIndex = -1
-	referenceData.NameColumnIndex = -1
-	referenceData.SKUColumnIndex = -1
-	referenceData.URLColumnIndex = -1
-	referenceData.ModelURLColumnIndex = -1
-	referenceData.ExtraColumnIndex = -1
-	referenceData.OnshapeURLColumnIndex = -1
-	referenceData.StatusColumnIndex = -1
-	referenceData.NotesColumnIndex = -1
 
+// getColumnIndexes finds the columns from the header row.  It fails when a required column is missing,
+// since otherwise every part would silently come out as New
+func getColumnIndexes(referenceData *PartCatalogData, cols []interface{}, sheet SheetConfig) error {
+	positions := make(map[string]int)
+	headers := make([]string, 0, len(cols))
 	for jj, col := range cols {
-		switch col.(string) {
-		case "Order":
-			referenceData.OrderColumnIndex = jj
-		case "Section":
-			referenceData.SectionColumnIndex = jj
-		case "Name":
-			referenceData.NameColumnIndex = jj
-		case "Part #":
-			referenceData.SKUColumnIndex = jj
-		case "URL":
-			referenceData.URLColumnIndex = jj
-		case "Model URL":
-			referenceData.ModelURLColumnIndex = jj
-		case "Onshape URL":
-			referenceData.OnshapeURLColumnIndex = jj
-		case "Extra 1":
-			referenceData.ExtraColumnIndex = jj
-		case "Status":
-			referenceData.StatusColumnIndex = jj
-		case "Model Status":
-			referenceData.StatusColumnIndex = jj
-		case "Notes":
-			referenceData.NotesColumnIndex = jj
-		default:
+		header, _ := col.(string)
+		headers = append(headers, header)
+		if key := normalizeHeader(header); key != "" {
+			if _, duplicate := positions[key]; !duplicate {
+				positions[key] = jj
+			}
 		}
 	}
+	find := func(header string) int {
+		if jj, found := positions[normalizeHeader(header)]; found {
+			return jj
+		}
+		return -1
+	}
+
+	columns := []struct {
+		name  string
+		index *int
+	}{
+		{ColumnOrder, &referenceData.OrderColumnIndex},
+		{ColumnSection, &referenceData.SectionColumnIndex},
+		{ColumnName, &referenceData.NameColumnIndex},
+		{ColumnSKU, &referenceData.SKUColumnIndex},
+		{ColumnURL, &referenceData.URLColumnIndex},
+		{ColumnModelURL, &referenceData.ModelURLColumnIndex},
+		{ColumnOnshapeURL, &referenceData.OnshapeURLColumnIndex},
+		{ColumnStatus, &referenceData.StatusColumnIndex},
+		{ColumnNotes, &referenceData.NotesColumnIndex},
+	}
+	for _, column := range columns {
+		*column.index = -1
+		for _, header := range sheet.headers(column.name) {
+			if jj := find(header); jj >= 0 {
+				*column.index = jj
+				break
+			}
+		}
+	}
+
+	extras, configured := sheet.extraHeaders()
+	others := 0
+	for i := range referenceData.ExtraColumnIndexes {
+		referenceData.ExtraColumnIndexes[i] = -1
+		if i < len(extras) {
+			referenceData.ExtraColumnIndexes[i] = find(extras[i])
+			if i > 0 && referenceData.ExtraColumnIndexes[i] >= 0 {
+				others++
+			}
+		}
+	}
+	if first := referenceData.ExtraColumnIndexes[0]; !configured && first >= 0 && others == 0 {
+		// Older sheets only label the first of the extra columns
+		for i := range referenceData.ExtraColumnIndexes {
+			if first+i < len(cols) {
+				referenceData.ExtraColumnIndexes[i] = first + i
+			}
+		}
+	}
+	referenceData.ExtraColumnIndex = referenceData.ExtraColumnIndexes[0]
+
+	var missing []string
+	for _, column := range columns {
+		if *column.index >= 0 {
+			continue
+		}
+		if slices.Contains(sheet.required(), column.name) {
+			missing = append(missing, column.name)
+		} else {
+			slog.Warn("catalog column not found", "phase", "load", "tab", sheet.TabName(), "column", column.name)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingColumnsError{Tab: sheet.TabName(), Missing: missing, Found: headers}
+	}
+	return nil
 }
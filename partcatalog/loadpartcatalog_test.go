@@ -18,3 +18,28 @@ func TestLoadPartCatalog(t *testing.T) {
 		t.Log("error should be nil")
 	}
 }
+
+func TestGetColumnIndexes(t *testing.T) {
+	header := []interface{}{"Section", "Name", " sku ", "Web Page", "Model Status", "Extra 1", "", "", "", "", "", ""}
+
+	referenceData := NewPartCatalogData()
+	err := getColumnIndexes(referenceData, header, SheetConfig{})
+	missing, ok := err.(*MissingColumnsError)
+	if !ok || len(missing.Missing) != 2 || missing.Missing[0] != ColumnSKU || missing.Missing[1] != ColumnURL {
+		t.Fatalf("expected Part # and URL to be missing, got %v", err)
+	}
+
+	sheet := SheetConfig{Aliases: map[string][]string{ColumnSKU: {"SKU"}, ColumnURL: {"Web Page"}}}
+	if err := getColumnIndexes(referenceData, header, sheet); err != nil {
+		t.Fatal(err)
+	}
+	if referenceData.SKUColumnIndex != 2 || referenceData.URLColumnIndex != 3 || referenceData.StatusColumnIndex != 4 || referenceData.OrderColumnIndex != -1 {
+		t.Errorf("unexpected indexes %+v", referenceData)
+	}
+	if referenceData.ExtraColumnIndexes != [7]int{5, 6, 7, 8, 9, 10, 11} {
+		t.Errorf("extra columns are %v", referenceData.ExtraColumnIndexes)
+	}
+	if referenceData.extraFor(4) != -1 || referenceData.extraFor(7) != 2 {
+		t.Errorf("extraFor gave the wrong columns")
+	}
+}
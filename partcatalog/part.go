@@ -2,6 +2,9 @@ package partcatalog
 
 import (
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
 	"sync"
 )
 
@@ -36,19 +39,71 @@ func (status SpiderStatus) String() string {
 	return names[status]
 }
 
+// MarshalText writes the status by name so that JSON output is readable
+func (status SpiderStatus) MarshalText() ([]byte, error) {
+	return []byte(status.String()), nil
+}
+
+// UnmarshalText reads back a status written by MarshalText
+func (status *SpiderStatus) UnmarshalText(text []byte) error {
+	parsed, ok := ParseSpiderStatus(string(text))
+	if !ok {
+		return fmt.Errorf("unknown spider status '%s'", text)
+	}
+	*status = parsed
+	return nil
+}
+
 // PartData - detailed information about an individual part in our part catalog
 type PartData struct {
-	Order        uint         // General output order for sorting the spreadsheet
-	Section      string       // The path where the part occurs
-	Name         string       // Name of the model file
-	SKU          string       // Part number/SKU
-	URL          string       // URL on the vendor website for the part
-	ModelURL     string       // URL on the vendor website for any 3d model
-	Extra        [7]string    // Extra items associated with the part
-	OnshapeURL   string       // Location of the Onshape model
-	Status       string       // Status of the Onshape model (Done, Bundle, etc)
-	SpiderStatus SpiderStatus // Status from the latest spidering.
-	Notes        string       // Any general information about the part
+	Order        uint         `json:"order"`        // General output order for sorting the spreadsheet
+	Section      string       `json:"section"`      // The path where the part occurs
+	Name         string       `json:"name"`         // Name of the model file
+	SKU          string       `json:"sku"`          // Part number/SKU
+	URL          string       `json:"url"`          // URL on the vendor website for the part
+	ModelURL     string       `json:"modelUrl"`     // URL on the vendor website for any 3d model
+	Extra        [7]string    `json:"extra"`        // Extra items associated with the part
+	OnshapeURL   string       `json:"onshapeUrl"`   // Location of the Onshape model
+	Status       string       `json:"modelStatus"`  // Status of the Onshape model (Done, Bundle, etc)
+	SpiderStatus SpiderStatus `json:"spiderStatus"` // Status from the latest spidering.
+	Notes        string       `json:"notes"`        // Any general information about the part
+
+	Row int `json:"row,omitempty"` // Row of the catalog spreadsheet the part was loaded from (the header is row 1)
+
+	// When the part is one of the options of a product, these tie it back to the product
+	ParentID string             `json:"parentId,omitempty"` // Vendor's identifier for the product the part is an option of
+	BaseName string             `json:"baseName,omitempty"` // Name of the product without the option
+	Variant  []VariantAttribute `json:"variant,omitempty"`  // The options which pick out this part (e.g. Color=Red)
+
+	Specs  map[string]SpecValue `json:"specs,omitempty"`  // Specifications from the product page by name (Bore, Pitch, Material...)
+	Images []string             `json:"images,omitempty"` // URLs of the product pictures, the primary one first
+}
+
+// VariantAttribute is one option which distinguishes a variant from the others of the same product
+type VariantAttribute struct {
+	Name  string `json:"name"`  // Such as Color, Length or Bore
+	Value string `json:"value"` // Such as Red, 100mm or 8mm REX
+}
+
+// FormatVariant writes the variant attributes as a single column, e.g. "Color=Red; Length=100mm"
+func FormatVariant(variant []VariantAttribute) string {
+	parts := make([]string, 0, len(variant))
+	for _, attr := range variant {
+		parts = append(parts, attr.Name+"="+attr.Value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ParseVariant reads back a column written by FormatVariant
+func ParseVariant(text string) []VariantAttribute {
+	var result []VariantAttribute
+	for _, part := range strings.Split(text, "; ") {
+		name, value, found := strings.Cut(part, "=")
+		if found {
+			result = append(result, VariantAttribute{strings.TrimSpace(name), strings.TrimSpace(value)})
+		}
+	}
+	return result
 }
 
 // PartCatalogData - collection of part numbers and urls
@@ -67,10 +122,22 @@ type PartCatalogData struct {
 	SKUColumnIndex        int
 	URLColumnIndex        int
 	ModelURLColumnIndex   int
-	ExtraColumnIndex      int
+	ExtraColumnIndex      int // The first of ExtraColumnIndexes
 	OnshapeURLColumnIndex int
 	StatusColumnIndex     int
 	NotesColumnIndex      int
+	// ExtraColumnIndexes are the columns loaded into each of the Extra values, -1 when missing
+	ExtraColumnIndexes [7]int
+}
+
+// extraFor returns which Extra value a column is loaded into, or -1 when it isn't an extra column
+func (catalog *PartCatalogData) extraFor(column int) int {
+	for i, index := range catalog.ExtraColumnIndexes {
+		if index == column && index >= 0 {
+			return i
+		}
+	}
+	return -1
 }
 
 // NewPartCatalogData - constructor
@@ -100,7 +167,7 @@ func (catalog *PartCatalogData) addPart(part *PartData, excludeFilter func(*Part
 	} else {
 		dup, ok := catalog.PartNumber[part.SKU]
 		if ok {
-			fmt.Printf("row %2d: duplicate part number '%s' found (original row %d)\n", len(catalog.Partdata), part.SKU, dup.Order)
+			slog.Warn("duplicate part number", "row", part.Row, "sku", part.SKU, "originalRow", dup.Row)
 		} else {
 			catalog.PartNumber[part.SKU] = part
 		}
@@ -109,11 +176,25 @@ func (catalog *PartCatalogData) addPart(part *PartData, excludeFilter func(*Part
 	//part.Println()
 }
 
-func (partData *PartData) toString() string {
-	return fmt.Sprintf("%d SKU: '%v' Product: '%v' Model:'%v' on page '%v'", partData.Order, partData.SKU, partData.Name, partData.ModelURL, partData.URL)
+// IndexURLs rebuilds the lookup by URL using normalized URLs so that trivial differences (http vs https,
+// trailing slashes...) between the spreadsheet and the website don't stop a part from being found
+func (catalog *PartCatalogData) IndexURLs(normalize func(string) string) {
+	catalog.URL = make(map[string]*PartData)
+	for _, part := range catalog.Partdata {
+		if part.URL != "" && !slices.Contains(catalog.ExcludeFromSearch, part) {
+			catalog.URL[normalize(part.URL)] = part
+		}
+	}
 }
 
-// Println generates the product line for the output file and also prints a status message on stdout
-func (partData *PartData) Println() {
-	fmt.Printf("%s\n", partData.toString())
+// LogValue lets a part be passed directly as a structured logging attribute
+func (partData *PartData) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Any("order", partData.Order),
+		slog.String("sku", partData.SKU),
+		slog.String("name", partData.Name),
+		slog.String("status", partData.SpiderStatus.String()),
+		slog.String("model", partData.ModelURL),
+		slog.String("url", partData.URL),
+	)
 }
@@ -0,0 +1,115 @@
+package partcatalog
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FieldChange describes one difference between the catalog and the website that the spider
+// recorded in the Notes of a part
+type FieldChange struct {
+	Field string // Which field changed (Name, Section, SKU, URL)
+	Old   string // Value in the catalog
+	New   string // Value found on the website
+}
+
+// ParseSpiderStatus converts the name written in the output file back into a SpiderStatus
+func ParseSpiderStatus(name string) (SpiderStatus, bool) {
+	for status := NewPart; status <= UnchangedPart; status++ {
+		if strings.EqualFold(status.String(), strings.TrimSpace(name)) {
+			return status, true
+		}
+	}
+	return NewPart, false
+}
+
+// Changes decodes the notes that the CheckMatch routines leave for a changed part.
+// When the website differs, the spider keeps the catalog Name and Section and notes the new value,
+// but it takes the website SKU and URL and notes the old value.
+func (partData *PartData) Changes() []FieldChange {
+	var result []FieldChange
+	for _, note := range strings.Split(partData.Notes, ", ") {
+		note = strings.TrimSpace(note)
+		switch {
+		case strings.HasPrefix(note, "New Name:"):
+			result = append(result, FieldChange{"Name", partData.Name, strings.TrimPrefix(note, "New Name:")})
+		case strings.HasPrefix(note, "New Section:"):
+			result = append(result, FieldChange{"Section", partData.Section, strings.TrimPrefix(note, "New Section:")})
+		case strings.HasPrefix(note, "Old SKU:"):
+			result = append(result, FieldChange{"SKU", strings.TrimPrefix(note, "Old SKU:"), partData.SKU})
+		case strings.HasPrefix(note, "Old URL:"):
+			result = append(result, FieldChange{"URL", strings.TrimPrefix(note, "Old URL:"), partData.URL})
+		}
+	}
+	return result
+}
+
+// LoadRunOutput reads back the backtick separated file written by a spider run.
+// The columns are located by their header names so older output files can still be read.
+// Error lines (the ones with *** in the second column) are skipped.
+func LoadRunOutput(path string) ([]*PartData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	columns := map[string]int{}
+	result := make([]*PartData, 0)
+	linenum := 0
+	for scanner.Scan() {
+		linenum++
+		cols := strings.Split(strings.TrimRight(scanner.Text(), "\r"), "`")
+		if linenum == 1 {
+			for i, name := range cols {
+				columns[name] = i
+			}
+			if _, found := columns["Part #"]; !found {
+				return nil, fmt.Errorf("%s does not look like spider output, no 'Part #' column in the header", path)
+			}
+			continue
+		}
+		if len(cols) > 1 && strings.HasPrefix(cols[1], "***") {
+			continue
+		}
+		get := func(name string) string {
+			idx, found := columns[name]
+			if !found || idx >= len(cols) {
+				return ""
+			}
+			return cols[idx]
+		}
+		partData := new(PartData)
+		order, err := strconv.ParseUint(get("Order"), 10, 32)
+		if err == nil {
+			partData.Order = uint(order)
+		}
+		partData.Section = get("Section")
+		partData.Name = get("Name")
+		partData.SKU = get("Part #")
+		partData.URL = get("URL")
+		partData.ModelURL = get("Model URL")
+		for i := range partData.Extra {
+			partData.Extra[i] = get(fmt.Sprintf("Extra %d", i+1))
+		}
+		partData.OnshapeURL = get("Onshape URL")
+		partData.Status = get("Model Status")
+		partData.SpiderStatus, _ = ParseSpiderStatus(get("Spider Status"))
+		partData.Notes = get("Notes")
+		partData.ParentID = get("Parent ID")
+		partData.BaseName = get("Base Name")
+		partData.Variant = ParseVariant(get("Variant"))
+		partData.Specs = ParseSpecs(get("Specs"))
+		partData.Images = strings.Fields(get("Images"))
+		result = append(result, partData)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package partcatalog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The columns of the catalog spreadsheet, named by their standard header
+const (
+	ColumnOrder      = "Order"
+	ColumnSection    = "Section"
+	ColumnName       = "Name"
+	ColumnSKU        = "Part #"
+	ColumnURL        = "URL"
+	ColumnModelURL   = "Model URL"
+	ColumnOnshapeURL = "Onshape URL"
+	ColumnStatus     = "Status"
+	ColumnNotes      = "Notes"
+)
+
+// DefaultTab is the sheet of the spreadsheet which holds the catalog
+const DefaultTab = "All"
+
+// defaultAliases are the other headers which have been used for the standard columns
+var defaultAliases = map[string][]string{
+	ColumnStatus: {"Model Status"},
+}
+
+// defaultRequired are the columns without which the catalog can't be matched against the website
+var defaultRequired = []string{ColumnSection, ColumnName, ColumnSKU, ColumnURL}
+
+// SheetConfig describes the layout of a catalog spreadsheet.  The zero value is the standard layout
+type SheetConfig struct {
+	// Tab is the name of the sheet to read, DefaultTab when empty
+	Tab string
+	// Aliases are other headers a column can have, keyed by its standard header (e.g. ColumnSKU: {"SKU"}).
+	// They are used as well as the built in ones such as Model Status for Status
+	Aliases map[string][]string
+	// ExtraColumns are the headers of the columns loaded into Extra, in order (at most seven).
+	// When empty, Extra 1 to Extra 7 are used, and if only Extra 1 is found the six columns after it
+	ExtraColumns []string
+	// Required are the standard headers of the columns which must be found, defaulting to
+	// Section, Name, Part # and URL
+	Required []string
+}
+
+// TabName is the sheet to read
+func (c SheetConfig) TabName() string {
+	if c.Tab == "" {
+		return DefaultTab
+	}
+	return c.Tab
+}
+
+// readRange is the A1 notation for reading the whole tab.  It is quoted so that any tab name works
+func (c SheetConfig) readRange() string {
+	return "'" + strings.ReplaceAll(c.TabName(), "'", "''") + "'"
+}
+
+// headers gives every header which is accepted for a standard column, the standard one first
+func (c SheetConfig) headers(column string) []string {
+	result := []string{column}
+	result = append(result, defaultAliases[column]...)
+	return append(result, c.Aliases[column]...)
+}
+
+// extraHeaders gives the headers of the extra columns and whether they were configured
+func (c SheetConfig) extraHeaders() ([]string, bool) {
+	if len(c.ExtraColumns) > 0 {
+		return c.ExtraColumns, true
+	}
+	result := make([]string, len(PartData{}.Extra))
+	for i := range result {
+		result[i] = fmt.Sprintf("Extra %d", i+1)
+	}
+	return result, false
+}
+
+// required gives the standard headers of the columns which must be present
+func (c SheetConfig) required() []string {
+	if c.Required != nil {
+		return c.Required
+	}
+	return defaultRequired
+}
+
+// normalizeHeader lets headers match regardless of case and surrounding spaces
+func normalizeHeader(header string) string {
+	return strings.ToLower(strings.Join(strings.Fields(header), " "))
+}
+
+// MissingColumnsError says which required columns couldn't be found in the header row
+type MissingColumnsError struct {
+	Tab     string
+	Missing []string
+	Found   []string
+}
+
+func (e *MissingColumnsError) Error() string {
+	return fmt.Sprintf("sheet %q is missing the required columns %s (the headers are %s). Rename the columns or add aliases to the target's sheet configuration",
+		e.Tab, quoteList(e.Missing), quoteList(e.Found))
+}
+
+// quoteList formats headers for an error message
+func quoteList(headers []string) string {
+	quoted := make([]string, len(headers))
+	for i, header := range headers {
+		quoted[i] = fmt.Sprintf("%q", header)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,158 @@
+package partcatalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snapshot is the catalog as it was loaded for a run, kept so that the run can be traced back to the
+// version of the spreadsheet it was matched against and so that curator edits can be followed over time
+type Snapshot struct {
+	Taken         time.Time   `json:"taken"`
+	SpreadsheetID string      `json:"spreadsheetId"`
+	Tab           string      `json:"tab"`
+	Parts         []*PartData `json:"parts"`
+}
+
+// NewSnapshot captures the parts of a catalog
+func NewSnapshot(catalog *PartCatalogData, spreadsheetID string, sheet SheetConfig, taken time.Time) *Snapshot {
+	return &Snapshot{Taken: taken, SpreadsheetID: spreadsheetID, Tab: sheet.TabName(), Parts: catalog.Partdata}
+}
+
+// SnapshotPath is where the snapshot taken at a given time is kept, next to the run output base
+// (e.g. rev-catalog-20241005T143000Z.json for rev.txt)
+func SnapshotPath(base string, taken time.Time) string {
+	return base + "-catalog-" + taken.UTC().Format("20060102T150405Z") + ".json"
+}
+
+// WriteJSON saves the snapshot
+func (snapshot *Snapshot) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// LoadSnapshot reads back a snapshot written by WriteJSON
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := new(Snapshot)
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("unable to read catalog snapshot %s. Caused by: %v", path, err)
+	}
+	return snapshot, nil
+}
+
+// ChangeKind says how a row differs between two snapshots
+type ChangeKind string
+
+const (
+	// RowAdded - the row is only in the newer snapshot
+	RowAdded ChangeKind = "Added"
+	// RowRemoved - the row is only in the older snapshot
+	RowRemoved ChangeKind = "Removed"
+	// RowEdited - some of the fields of the row were changed
+	RowEdited ChangeKind = "Edited"
+)
+
+// SnapshotChange is one row which differs between two snapshots.  Old is nil for an added row and New
+// is nil for a removed one
+type SnapshotChange struct {
+	Kind   ChangeKind
+	Old    *PartData
+	New    *PartData
+	Fields []FieldChange // The fields which were edited
+}
+
+// Part is the part the change is about, preferring the newer version
+func (change SnapshotChange) Part() *PartData {
+	if change.New != nil {
+		return change.New
+	}
+	return change.Old
+}
+
+// DiffSnapshots compares two snapshots of the same catalog.  Rows are matched by part number, since
+// curators insert and sort rows and so the row numbers move.  A part number which is on several rows
+// is matched in the order the rows appear, and rows without a part number are matched by name and URL.
+// The added and edited rows come first in the order of the newer snapshot, then the removed rows
+func DiffSnapshots(older, newer *Snapshot) []SnapshotChange {
+	previous := make(map[string]*PartData)
+	for key, part := range snapshotKeys(older.Parts) {
+		previous[key] = part
+	}
+
+	var result []SnapshotChange
+	for key, part := range snapshotKeys(newer.Parts) {
+		old, found := previous[key]
+		if !found {
+			result = append(result, SnapshotChange{Kind: RowAdded, New: part})
+			continue
+		}
+		delete(previous, key)
+		if fields := diffParts(old, part); len(fields) > 0 {
+			result = append(result, SnapshotChange{Kind: RowEdited, Old: old, New: part, Fields: fields})
+		}
+	}
+	slices.SortStableFunc(result, func(a, b SnapshotChange) int { return a.New.Row - b.New.Row })
+
+	var removed []SnapshotChange
+	for _, part := range previous {
+		removed = append(removed, SnapshotChange{Kind: RowRemoved, Old: part})
+	}
+	slices.SortFunc(removed, func(a, b SnapshotChange) int { return a.Old.Row - b.Old.Row })
+	return append(result, removed...)
+}
+
+// snapshotKeys gives each non-blank row the key it is matched by
+func snapshotKeys(parts []*PartData) map[string]*PartData {
+	result := make(map[string]*PartData)
+	for _, part := range parts {
+		if isBlankRow(part) {
+			continue
+		}
+		base := "SKU:" + strings.TrimSpace(part.SKU)
+		if strings.TrimSpace(part.SKU) == "" {
+			base = "Name:" + strings.TrimSpace(part.Name) + "\x00" + strings.TrimSpace(part.URL)
+		}
+		key := base
+		for n := 2; result[key] != nil; n++ {
+			key = base + "#" + strconv.Itoa(n)
+		}
+		result[key] = part
+	}
+	return result
+}
+
+// diffParts lists the catalog fields which differ between two versions of a row.  The row number
+// itself and the results of the spider aren't compared
+func diffParts(old, new *PartData) []FieldChange {
+	var result []FieldChange
+	compare := func(field, before, after string) {
+		if before != after {
+			result = append(result, FieldChange{Field: field, Old: before, New: after})
+		}
+	}
+	compare(ColumnOrder, strconv.FormatUint(uint64(old.Order), 10), strconv.FormatUint(uint64(new.Order), 10))
+	compare(ColumnSection, old.Section, new.Section)
+	compare(ColumnName, old.Name, new.Name)
+	compare(ColumnSKU, old.SKU, new.SKU)
+	compare(ColumnURL, old.URL, new.URL)
+	compare(ColumnModelURL, old.ModelURL, new.ModelURL)
+	for i := range old.Extra {
+		compare(fmt.Sprintf("Extra %d", i+1), old.Extra[i], new.Extra[i])
+	}
+	compare(ColumnOnshapeURL, old.OnshapeURL, new.OnshapeURL)
+	compare(ColumnStatus, old.Status, new.Status)
+	compare(ColumnNotes, old.Notes, new.Notes)
+	return result
+}
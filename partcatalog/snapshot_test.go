@@ -0,0 +1,48 @@
+package partcatalog
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	older := &Snapshot{Parts: []*PartData{
+		{Row: 2, Name: "Gear", SKU: "1-1", URL: "https://www.example.com/gear"},
+		{Row: 3, Name: "Belt", SKU: "1-2"},
+		{Row: 4, Name: "Spacer", SKU: "1-3", Status: "Done"},
+		{Row: 5, Name: "Spacer", SKU: "1-3"},
+	}}
+	newer := &Snapshot{Parts: []*PartData{
+		{Row: 2, Name: "Pulley", SKU: "1-4"},
+		{Row: 3, Name: "Gear", SKU: "1-1", URL: "https://www.example.com/gears"},
+		{Row: 4},
+		{Row: 5, Name: "Spacer", SKU: "1-3", Status: "Done"},
+		{Row: 6, Name: "Spacer", SKU: "1-3"},
+	}}
+
+	// Check that the rows survive being saved
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	newer.Taken = time.Date(2024, 10, 5, 14, 30, 0, 0, time.UTC)
+	if err := newer.WriteJSON(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := DiffSnapshots(older, loaded)
+	var got []string
+	for _, change := range changes {
+		got = append(got, string(change.Kind)+" "+change.Part().SKU)
+	}
+	want := []string{"Added 1-4", "Edited 1-1", "Removed 1-2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got changes %q, expected %q", got, want)
+	}
+	if fields := changes[1].Fields; !reflect.DeepEqual(fields, []FieldChange{{ColumnURL, "https://www.example.com/gear", "https://www.example.com/gears"}}) {
+		t.Errorf("unexpected edited fields %+v", fields)
+	}
+}
@@ -0,0 +1,242 @@
+package partcatalog
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SpecValue is one specification of a part such as a Bore of 8mm REX
+type SpecValue struct {
+	Raw     string  `json:"raw"`             // The text as it appeared on the website
+	Value   float64 `json:"value,omitempty"` // The leading number, when there is one
+	Unit    string  `json:"unit,omitempty"`  // The unit of the leading number, in a standard form
+	Numeric bool    `json:"numeric"`         // Whether Value was parsed from the text
+}
+
+// specValuePattern picks up a leading number (including fractions such as 1/4 or 1-1/2) and what follows it
+var specValuePattern = regexp.MustCompile(`^\s*(\d+-\d+/\d+|\d+\s+\d+/\d+|\d+/\d+|\d*\.?\d+)(\s*)(.*)$`)
+
+// specUnits maps the ways vendors write units to the form we store
+var specUnits = map[string]string{
+	"mm":          "mm",
+	"millimeter":  "mm",
+	"millimeters": "mm",
+	"cm":          "cm",
+	"m":           "m",
+	"in":          "in",
+	"inch":        "in",
+	"inches":      "in",
+	`"`:           "in",
+	"”":           "in",
+	"ft":          "ft",
+	"feet":        "ft",
+	"g":           "g",
+	"grams":       "g",
+	"kg":          "kg",
+	"lb":          "lb",
+	"lbs":         "lb",
+	"oz":          "oz",
+	"rpm":         "rpm",
+	"v":           "V",
+	"volts":       "V",
+	"a":           "A",
+	"amps":        "A",
+	"w":           "W",
+	"watts":       "W",
+	"°":           "deg",
+	"deg":         "deg",
+	"degrees":     "deg",
+	"kg-cm":       "kg-cm",
+	"kgf-cm":      "kg-cm",
+	"oz-in":       "oz-in",
+	"n-m":         "N-m",
+	"nm":          "N-m",
+	"n·m":         "N-m",
+	"teeth":       "teeth",
+	"tooth":       "teeth",
+	"t":           "teeth",
+}
+
+// specAliases are the names that different vendors use for the same specification
+var specAliases = map[string]string{
+	"bore size":       "Bore",
+	"bore type":       "Bore",
+	"pitch size":      "Pitch",
+	"overall length":  "Length",
+	"length (mm)":     "Length",
+	"weight (g)":      "Weight",
+	"net weight":      "Weight",
+	"hole spacing":    "Hole Pattern",
+	"pattern":         "Hole Pattern",
+	"materials":       "Material",
+	"# of teeth":      "Teeth",
+	"tooth count":     "Teeth",
+	"number of teeth": "Teeth",
+}
+
+// SpecName turns the label from a specification table into the name we store it under
+func SpecName(label string) string {
+	label = strings.Join(strings.Fields(strings.TrimRight(strings.TrimSpace(label), ":")), " ")
+	if alias, found := specAliases[strings.ToLower(label)]; found {
+		return alias
+	}
+	return label
+}
+
+// ParseSpecValue extracts the number and unit from a specification
+func ParseSpecValue(raw string) SpecValue {
+	raw = strings.Join(strings.Fields(raw), " ")
+	result := SpecValue{Raw: raw}
+	match := specValuePattern.FindStringSubmatch(raw)
+	if match == nil {
+		return result
+	}
+	value, ok := parseSpecNumber(match[1])
+	if !ok {
+		return result
+	}
+	// The unit is the first word after the number, if it is one we know
+	rest := strings.TrimSpace(match[3])
+	unit := ""
+	if rest != "" {
+		word := ""
+		if fields := strings.FieldsFunc(rest, func(r rune) bool {
+			return r == ' ' || r == ',' || r == '(' || r == ')' || r == ';'
+		}); len(fields) > 0 {
+			word = strings.ToLower(fields[0])
+		}
+		if known, found := specUnits[word]; found {
+			unit = known
+		} else {
+			// Inches and degrees are often run together with what follows, e.g. 1/2"x3"
+			for _, symbol := range []string{`"`, "”", "°"} {
+				if strings.HasPrefix(word, symbol) {
+					unit = specUnits[symbol]
+					break
+				}
+			}
+		}
+		// Something like 6061-T6 is a name rather than a measurement
+		if unit == "" && match[2] == "" && word != "" {
+			return result
+		}
+	}
+	result.Value = value
+	result.Unit = unit
+	result.Numeric = true
+	return result
+}
+
+// parseSpecNumber understands decimals as well as fractions such as 1/4, 1-1/2 and 1 1/2
+func parseSpecNumber(text string) (float64, bool) {
+	whole := 0.0
+	if pos := strings.IndexAny(text, "- "); pos > 0 && strings.Contains(text, "/") {
+		w, err := strconv.ParseFloat(text[:pos], 64)
+		if err != nil {
+			return 0, false
+		}
+		whole = w
+		text = text[pos+1:]
+	}
+	if num, den, isFraction := strings.Cut(text, "/"); isFraction {
+		n, err1 := strconv.ParseFloat(num, 64)
+		d, err2 := strconv.ParseFloat(den, 64)
+		if err1 != nil || err2 != nil || d == 0 {
+			return 0, false
+		}
+		return whole + n/d, true
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	return whole + value, err == nil
+}
+
+// Spec finds a specification of the part by name, ignoring case and allowing any of the aliases for the name
+func (partData *PartData) Spec(name string) (SpecValue, bool) {
+	name = SpecName(name)
+	if value, found := partData.Specs[name]; found {
+		return value, true
+	}
+	for specName, value := range partData.Specs {
+		if strings.EqualFold(specName, name) {
+			return value, true
+		}
+	}
+	return SpecValue{}, false
+}
+
+// FormatSpecs writes the specifications as a single column sorted by name, e.g. "Bore=8mm REX; Material=Aluminum".
+// A ; or \ in a value (or an = in a name) is escaped with a \ so that the column can be read back
+func FormatSpecs(specs map[string]SpecValue) string {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, specNameEscaper.Replace(name)+"="+specValueEscaper.Replace(specs[name].Raw))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// The escaping used by FormatSpecs
+var (
+	specValueEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`)
+	specNameEscaper  = strings.NewReplacer(`\`, `\\`, ";", `\;`, "=", `\=`)
+)
+
+// ParseSpecs reads back a column written by FormatSpecs
+func ParseSpecs(text string) map[string]SpecValue {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	result := make(map[string]SpecValue)
+	for _, part := range splitSpecs(text) {
+		if part.found {
+			result[strings.TrimSpace(part.name)] = ParseSpecValue(part.value)
+		}
+	}
+	return result
+}
+
+// specField is one name=value pair of a column written by FormatSpecs, with the escaping removed
+type specField struct {
+	name, value string
+	found       bool // Whether there was an =
+}
+
+// splitSpecs splits a column written by FormatSpecs at the unescaped "; " separators and the first
+// unescaped = of each part
+func splitSpecs(text string) []specField {
+	var result []specField
+	var current specField
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text):
+			i++
+			sb.WriteByte(text[i])
+		case c == '=' && !current.found:
+			current.name, current.found = sb.String(), true
+			sb.Reset()
+		case c == ';' && strings.HasPrefix(text[i:], "; "):
+			i++
+			current.value = sb.String()
+			if !current.found {
+				current.name, current.value = current.value, ""
+			}
+			result = append(result, current)
+			current = specField{}
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	current.value = sb.String()
+	if !current.found {
+		current.name, current.value = current.value, ""
+	}
+	return append(result, current)
+}
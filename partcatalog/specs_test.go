@@ -0,0 +1,45 @@
+package partcatalog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSpecValue(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want SpecValue
+	}{
+		{"8mm REX", SpecValue{Raw: "8mm REX", Value: 8, Unit: "mm", Numeric: true}},
+		{"1/4 in", SpecValue{Raw: "1/4 in", Value: 0.25, Unit: "in", Numeric: true}},
+		{"1-1/2\"", SpecValue{Raw: "1-1/2\"", Value: 1.5, Unit: "in", Numeric: true}},
+		{"  48   teeth ", SpecValue{Raw: "48 teeth", Value: 48, Unit: "teeth", Numeric: true}},
+		{"6061-T6", SpecValue{Raw: "6061-T6"}},
+		{"Aluminum", SpecValue{Raw: "Aluminum"}},
+		{"", SpecValue{}},
+		// Nothing but separators after the number
+		{"12,", SpecValue{Raw: "12,", Value: 12, Numeric: true}},
+		{"5 ()", SpecValue{Raw: "5 ()", Value: 5, Numeric: true}},
+		{"3 ;", SpecValue{Raw: "3 ;", Value: 3, Numeric: true}},
+	}
+	for _, test := range tests {
+		if got := ParseSpecValue(test.raw); got != test.want {
+			t.Errorf("ParseSpecValue(%q) = %+v, expected %+v", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestSpecsRoundTrip(t *testing.T) {
+	tests := []map[string]SpecValue{
+		nil,
+		{"Bore": ParseSpecValue("8mm REX"), "Material": ParseSpecValue("Aluminum")},
+		{"Finish": ParseSpecValue("Black; anodized"), "Notes": ParseSpecValue(`C:\parts`)},
+		{"Ratio=": ParseSpecValue("3:1"), "Fit": ParseSpecValue("a=b; c")},
+	}
+	for _, specs := range tests {
+		text := FormatSpecs(specs)
+		if got := ParseSpecs(text); !reflect.DeepEqual(got, specs) {
+			t.Errorf("%q read back as %+v, expected %+v", text, got, specs)
+		}
+	}
+}
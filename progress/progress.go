@@ -0,0 +1,130 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+	"github.com/toebes/ftc_parts_spider/spiderdata"
+)
+
+// Display periodically shows how far along a run is.  On a terminal it redraws a small
+// block of lines in place, otherwise it writes a one line summary each time
+type Display struct {
+	stats    *spiderdata.RunStats
+	qc       *spiderdata.QueueCounter
+	out      io.Writer
+	tty      bool
+	interval time.Duration
+	lines    int
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// The statuses shown on the parts line along with the short label for each
+var statusLabels = []struct {
+	status partcatalog.SpiderStatus
+	label  string
+}{
+	{partcatalog.NewPart, "New"},
+	{partcatalog.PartChanged, "Changed"},
+	{partcatalog.UnchangedPart, "Same"},
+	{partcatalog.DiscontinuedPart, "Discontinued"},
+	{partcatalog.PartNotFoundBySpider, "Not Found"},
+}
+
+// Start begins refreshing the display on stdout at the given interval
+func Start(stats *spiderdata.RunStats, qc *spiderdata.QueueCounter, interval time.Duration) *Display {
+	d := &Display{
+		stats:    stats,
+		qc:       qc,
+		out:      os.Stdout,
+		tty:      IsTerminal(os.Stdout),
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+	d.wg.Add(1)
+	go d.run()
+	return d
+}
+
+// Stop shows the final state and stops refreshing
+func (d *Display) Stop() {
+	close(d.done)
+	d.wg.Wait()
+	d.render()
+}
+
+func (d *Display) run() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.render()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// render draws the current state of the run
+func (d *Display) render() {
+	summary := d.stats.Summary("")
+	inflight, queued := d.qc.GetCounts()
+	elapsed := time.Duration(summary.ElapsedSeconds * float64(time.Second))
+	errors := 0
+	for _, count := range summary.Errors {
+		errors += count
+	}
+
+	pages := pagesLine(summary.PagesFetched, inflight, queued, elapsed)
+	var parts []string
+	for _, entry := range statusLabels {
+		parts = append(parts, fmt.Sprintf("%s %d", entry.label, summary.Totals[entry.status.String()]))
+	}
+	products := "Parts: " + strings.Join(parts, ", ") + fmt.Sprintf("  Errors: %d", errors)
+	current := "Current: " + d.stats.CurrentURL()
+
+	if !d.tty {
+		fmt.Fprintf(d.out, "%s | %s | %s\n", pages, products, current)
+		return
+	}
+	// Move back up over what we drew last time and clear each line as we redraw it
+	if d.lines > 0 {
+		fmt.Fprintf(d.out, "\x1b[%dA", d.lines)
+	}
+	for _, line := range []string{pages, products, current} {
+		fmt.Fprintf(d.out, "\x1b[2K%s\n", line)
+	}
+	d.lines = 3
+}
+
+// pagesLine describes how far the crawl has got, with the rate so far and how long the pages which are
+// still to be fetched will take at that rate
+func pagesLine(fetched int, inflight int, queued int, elapsed time.Duration) string {
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(fetched) / elapsed.Seconds()
+	}
+	eta := "?"
+	if rate > 0 {
+		eta = time.Duration(float64(queued+inflight) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("Pages: %d fetched, %d in flight, %d queued  %.1f pages/s  elapsed %v  ETA %s",
+		fetched, inflight, queued, rate, elapsed.Round(time.Second), eta)
+}
+
+// IsTerminal reports whether the file is attached to a terminal rather than a pipe or file
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,67 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+	"github.com/toebes/ftc_parts_spider/spiderdata"
+)
+
+func TestPagesLine(t *testing.T) {
+	tests := []struct {
+		fetched, inflight, queued int
+		elapsed                   time.Duration
+		want                      string
+	}{
+		// Nothing to go on yet
+		{0, 0, 5, 0, "Pages: 0 fetched, 0 in flight, 5 queued  0.0 pages/s  elapsed 0s  ETA ?"},
+		{0, 1, 5, 10 * time.Second, "Pages: 0 fetched, 1 in flight, 5 queued  0.0 pages/s  elapsed 10s  ETA ?"},
+		{100, 2, 8, 40 * time.Second, "Pages: 100 fetched, 2 in flight, 8 queued  2.5 pages/s  elapsed 40s  ETA 4s"},
+		// The fraction of a second each page takes adds up over a long queue
+		{7, 0, 100, 2 * time.Second, "Pages: 7 fetched, 0 in flight, 100 queued  3.5 pages/s  elapsed 2s  ETA 29s"},
+		{90, 0, 1000, 90*time.Second + 400*time.Millisecond, "Pages: 90 fetched, 0 in flight, 1000 queued  1.0 pages/s  elapsed 1m30s  ETA 16m44s"},
+		// Nothing left to fetch
+		{50, 0, 0, 25 * time.Second, "Pages: 50 fetched, 0 in flight, 0 queued  2.0 pages/s  elapsed 25s  ETA 0s"},
+	}
+	for _, test := range tests {
+		if got := pagesLine(test.fetched, test.inflight, test.queued, test.elapsed); got != test.want {
+			t.Errorf("pagesLine(%d, %d, %d, %v) = %q, expected %q", test.fetched, test.inflight, test.queued, test.elapsed, got, test.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	stats := spiderdata.NewRunStats()
+	stats.PageFetched("https://www.example.com/gears")
+	stats.RecordPart(&partcatalog.PartData{SKU: "1-1", SpiderStatus: partcatalog.NewPart})
+	stats.RecordPart(&partcatalog.PartData{SKU: "1-2", SpiderStatus: partcatalog.UnchangedPart})
+	stats.RecordError("Fetch error")
+	qc := &spiderdata.QueueCounter{}
+	qc.Increment()
+
+	// Without a terminal each refresh is a single line
+	var out bytes.Buffer
+	d := &Display{stats: stats, qc: qc, out: &out}
+	d.render()
+	d.render()
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := " | Parts: New 1, Changed 0, Same 1, Discontinued 0, Not Found 0  Errors: 1 | Current: https://www.example.com/gears"
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "Pages: 1 fetched, 0 in flight, 1 queued") || !strings.HasSuffix(lines[0], want) {
+		t.Errorf("render wrote %q", out.String())
+	}
+
+	// On a terminal the block is redrawn in place
+	out.Reset()
+	d = &Display{stats: stats, qc: qc, out: &out, tty: true}
+	d.render()
+	d.render()
+	if got := strings.Count(out.String(), "\x1b[3A"); got != 1 {
+		t.Errorf("moved back over the block %d times, expected once", got)
+	}
+	if got := strings.Count(out.String(), "\x1b[2K"); got != 6 {
+		t.Errorf("cleared %d lines, expected 6", got)
+	}
+}
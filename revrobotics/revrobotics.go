@@ -2,9 +2,11 @@ package revrobotics
 
 import (
 	"fmt"
+	"log/slog"
 	"regexp"
 	"strings"
 
+	"github.com/toebes/ftc_parts_spider/onshape"
 	"github.com/toebes/ftc_parts_spider/partcatalog"
 	"github.com/toebes/ftc_parts_spider/spiderdata"
 
@@ -15,11 +17,15 @@ import (
 var RevRoboticsTarget = spiderdata.SpiderTarget{
 	Outfile:        "rev_robotics.txt",
 	SpreadsheetID:  "1Rs6HgY-WZzOyxMhI53NjcfcuxjO-hh3gsDmO4Jk6PFA", //"19Mc9Uj0zoaRr_KmPncf_svNOp9WqIgrzaD7fEiNlBr0",
+	OnshapeNaming:  onshape.Naming{Vendor: "REV Robotics"},
 	Presets:        []string{},
 	StripSKU:       false,
 	Seed:           "https://www.revrobotics.com/ftc/",
 	ParsePageFunc:  ParseRevRoboticsPage,
 	CheckMatchFunc: CheckRevRoboticsMatch,
+	IncludeRules:   []spiderdata.URLRule{spiderdata.Host("www.revrobotics.com")},
+	TrailingSlash:  true,
+	StorefrontAPI:  true,
 
 	SectionNameDeletes: []string{},
 	SectionAllowedMap:  map[string]string{},
@@ -158,7 +164,7 @@ func CheckRevRoboticsMatch(ctx *spiderdata.Context, partData *partcatalog.PartDa
 		}
 		// If the URL changes then we really want to use it.
 		// Just stash away the old URL so we know what happened
-		if !strings.EqualFold(partData.URL, entry.URL) {
+		if !spiderdata.SameURL(ctx, partData.URL, entry.URL) {
 			// In the case where there was a sku= on the URL we want to keep the one with it
 			urlString := partData.URL
 			newURL, strippedNew := spiderdata.CleanURL(ctx, partData.URL)
@@ -170,6 +176,9 @@ func CheckRevRoboticsMatch(ctx *spiderdata.Context, partData *partcatalog.PartDa
 			// had the URL silently.
 			if strings.EqualFold(oldURL, newURL) {
 				partData.URL = urlString
+			} else if spiderdata.ReconcileMovedURL(ctx, partData, entry) {
+				// The catalog URL redirects here, so it has just moved.  Keep the new one and
+				// leave it for the URL moved report
 			} else {
 				partData.SpiderStatus = partcatalog.PartChanged
 				partData.Notes += extra + " Old URL:" + entry.URL
@@ -194,6 +203,8 @@ func CheckRevRoboticsMatch(ctx *spiderdata.Context, partData *partcatalog.PartDa
 		}
 		// Prevent us from outputting the same entry more than once.
 		entry.SpiderStatus = partData.SpiderStatus
+		// Remember which row of the spreadsheet it came from so that any changes can be applied to it
+		partData.Row = entry.Row
 	} else {
 		partData.SpiderStatus = partcatalog.NewPart
 		partData.Status = "Not Done"
@@ -258,7 +269,7 @@ func getBreadCrumbName(ctx *spiderdata.Context, url string, bc *goquery.Selectio
 	})
 	savename, found := ctx.G.BreadcrumbMap[url]
 	if found && savename != "" {
-		fmt.Printf("== For %v extracted breadcrumb '%v' but instead using '%v'\n", url, result, savename)
+		slog.Debug("using saved breadcrumb", "url", url, "extracted", result, "breadcrumb", savename)
 		result = savename
 	}
 	return result
@@ -300,7 +311,7 @@ func processPagination(ctx *spiderdata.Context, breadcrumbs string, categoryprod
 		item.Find("a").Each(func(i int, elem *goquery.Selection) {
 			url, _ := elem.Attr("href")
 			// elemtext := elem.Text()
-			fmt.Printf("Found pagination item url=%s\n", url)
+			slog.Debug("pagination item", "url", url)
 			found = true
 			if !ctx.G.SingleOnly {
 				spiderdata.EnqueURL(ctx, url, breadcrumbs)
@@ -318,7 +329,7 @@ func processSubCategories(ctx *spiderdata.Context, breadcrumbs string, categoryp
 		item.Find("a").Each(func(i int, elem *goquery.Selection) {
 			url, _ := elem.Attr("href")
 			elemtext := elem.Text()
-			fmt.Printf("Found subCategory item name=%s url=%s\n", elemtext, url)
+			slog.Debug("subcategory item", "name", elemtext, "url", url)
 			found = true
 			subcrumb := spiderdata.MakeBreadCrumb(ctx, breadcrumbs, elemtext)
 			if !ctx.G.SingleOnly {
@@ -337,7 +348,7 @@ func processSubProducts(ctx *spiderdata.Context, breadcrumbs string, categorypro
 		item.Find("h4.card-title a").Each(func(i int, elem *goquery.Selection) {
 			url, _ := elem.Attr("href")
 			elemtext := elem.Text()
-			fmt.Printf("Found ProductCard item name=%s url=%s\n", elemtext, url)
+			slog.Debug("product card item", "name", elemtext, "url", url)
 			found = true
 			if !ctx.G.SingleOnly {
 				spiderdata.EnqueURL(ctx, url, breadcrumbs)
@@ -386,7 +397,7 @@ func findAllDownloads(ctx *spiderdata.Context, url string, root *goquery.Selecti
 						title = "DRAWING:" + title
 					}
 					result[title] = spiderdata.DownloadEnt{URL: dlurl, Used: false}
-					fmt.Printf("Save Download '%s'='%s'\n", title, dlurl)
+					slog.Debug("save download", "title", title, "href", dlurl)
 					// } else {
 					// We don't actually care to complain about these bad URLs as they aren't problematic
 					//     if title != "" {
@@ -425,7 +436,7 @@ func findAllDownloads(ctx *spiderdata.Context, url string, root *goquery.Selecti
 							atitle = "DRAWING:" + atitle
 						}
 						result[atitle] = spiderdata.DownloadEnt{URL: dlurl, Used: false}
-						fmt.Printf("Save Download '%s'='%s'\n", atitle, dlurl)
+						slog.Debug("save download", "title", atitle, "href", dlurl)
 					} else {
 						if title == "" {
 							spiderdata.OutputError(ctx, "No URL found associated with %s on %s\n", title, url)
@@ -561,7 +572,7 @@ func processProductGrid(ctx *spiderdata.Context, breadcrumbs string, _ /*url*/ s
 		pg.Find("li.product h4.card-title a").Each(func(i int, a *goquery.Selection) {
 			urlloc, _ := a.Attr("href")
 			product := a.Text()
-			fmt.Printf("**ProductGrid Found item name=%s url=%s\n", product, urlloc)
+			slog.Debug("product grid item", "name", product, "url", urlloc)
 			found = true
 			if !ctx.G.SingleOnly {
 
@@ -582,7 +593,7 @@ func processQaatcList(ctx *spiderdata.Context, breadcrumbs string, _ /*url*/ str
 		pg.Find("li.qaatc__item  a.qaatc__name").Each(func(i int, a *goquery.Selection) {
 			urlloc, _ := a.Attr("href")
 			product := a.Text()
-			fmt.Printf("**processQaatcList Found item name=%s url=%s\n", product, urlloc)
+			slog.Debug("qaatc list item", "name", product, "url", urlloc)
 			found = true
 			if !ctx.G.SingleOnly {
 				spiderdata.EnqueURL(ctx, urlloc, breadcrumbs)
@@ -593,8 +604,9 @@ func processQaatcList(ctx *spiderdata.Context, breadcrumbs string, _ /*url*/ str
 }
 
 // --------------------------------------------------------------------------------------------
-// processProduct takes a standard page which has a single product on it and outputs the information
-func processProduct(ctx *spiderdata.Context, productname string, url string, product *goquery.Selection) (found bool) {
+// processProduct takes a standard page which has a single product on it and outputs the information.
+// Each of the options is also checked for being marked discontinued on its own
+func processProduct(ctx *spiderdata.Context, productname string, url string, product *goquery.Selection, availability spiderdata.Availability) (found bool) {
 	outpad := make([]string, 7)
 	found = false
 	spiderdata.OutputCategory(ctx, productname, false)
@@ -621,8 +633,29 @@ func processProduct(ctx *spiderdata.Context, productname string, url string, pro
 
 	downloadurls := findAllDownloads(ctx, url, product)
 	_, isSingle := SingleSKUs[sku]
+	parentID := spiderdata.ProductID(product)
+	specs := spiderdata.ExtractSpecs(product)
+	images := spiderdata.ExtractImages(ctx, product)
+	if sku != "" {
+		spiderdata.RecordKit(ctx, sku, localname, url, spiderdata.ExtractKitComponents(ctx, product))
+	}
 
-	if changesetInputs.Length() > 0 && !isSingle {
+	if parts, ok := spiderdata.StorefrontParts(ctx, product, url); ok && !isSingle {
+		// The storefront API knows the SKU of every option, so they don't have to be picked out of the labels
+		availability := spiderdata.StorefrontAvailability(ctx, availability)
+		for _, partData := range parts {
+			partData.SKU = fixSku(partData.SKU)
+			outpad[6], _ = getKeyDownloadURL(partData.SKU, downloadurls, "STEP")
+			copy(partData.Extra[:], outpad)
+			partData.ModelURL = getDownloadURL(ctx, partData.SKU, downloadurls)
+			partData.Specs = specs
+			if len(images) > 0 {
+				partData.Images = images
+			}
+			spiderdata.OutputProductData(ctx, partData, availability)
+		}
+		found = true
+	} else if changesetInputs.Length() > 0 && !isSingle {
 		//fmt.Printf("Has Changeset\n")
 		changesetInputs.Each(func(i int, input *goquery.Selection) {
 			id, hasid := input.Attr("id")
@@ -653,7 +686,19 @@ func processProduct(ctx *spiderdata.Context, productname string, url string, pro
 						//      <input class="form-radio" type="radio" id="attribute_radio_114" name="attribute[53]" value="114" required="" data-state="false">
 						//      <label data-product-attribute-value="114" class="form-label" for="attribute_radio_114">30cm</label>
 						outpad[6], _ = getKeyDownloadURL(itemsku, downloadurls, "STEP")
-						spiderdata.OutputProduct(ctx, itemname, itemsku, url, getDownloadURL(ctx, itemsku, downloadurls), false, outpad)
+						// The option value is the short label when there is one, otherwise what followed the SKU
+						value := matches[2]
+						if hasdatalabel {
+							value = datalabel
+						}
+						optionName := spiderdata.OptionName(input.Closest(".form-field").Find("label.form-label--alternate").Text(), "Option")
+						partData := spiderdata.NewProductData(itemname, itemsku, url, getDownloadURL(ctx, itemsku, downloadurls), outpad)
+						partData.ParentID = parentID
+						partData.BaseName = strings.TrimSpace(localname)
+						partData.Variant = []partcatalog.VariantAttribute{{Name: optionName, Value: strings.TrimSpace(value)}}
+						partData.Specs = specs
+						partData.Images = images
+						spiderdata.OutputProductData(ctx, partData, max(availability, spiderdata.TextAvailability(label, "")))
 					}
 				}
 			}
@@ -662,7 +707,10 @@ func processProduct(ctx *spiderdata.Context, productname string, url string, pro
 	} else if sku != "" {
 		// fmt.Printf("No Changeset\n")
 		outpad[6], _ = getKeyDownloadURL(sku, downloadurls, "STEP")
-		spiderdata.OutputProduct(ctx, localname, sku, url, getDownloadURL(ctx, sku, downloadurls), false, outpad)
+		partData := spiderdata.NewProductData(localname, sku, url, getDownloadURL(ctx, sku, downloadurls), outpad)
+		partData.Specs = specs
+		partData.Images = images
+		spiderdata.OutputProductData(ctx, partData, availability)
 		found = true
 	}
 
@@ -677,7 +725,7 @@ func ParseRevRoboticsPage(ctx *spiderdata.Context, doc *goquery.Document) {
 
 	found := false
 	breadcrumbs := getBreadCrumbName(ctx, url, doc.Find("ul.breadcrumbs"))
-	fmt.Printf("Breadcrumb:%s\n", breadcrumbs)
+	slog.Debug("parse page", "url", url, "breadcrumb", breadcrumbs)
 	doc.Find("ul.navList").Each(func(i int, navItems *goquery.Selection) {
 		// fmt.Printf("Found Navlist\n")
 		if navItems.ParentsFiltered("ul.navList").Length() == 0 {
@@ -714,7 +762,9 @@ func ParseRevRoboticsPage(ctx *spiderdata.Context, doc *goquery.Document) {
 	})
 	if !found {
 		doc.Find("div.productView").Each(func(i int, product *goquery.Selection) {
-			if processProduct(ctx, breadcrumbs, url, product) {
+			// The options are skipped here since only some of them may be discontinued
+			availability := spiderdata.ProductAvailability(doc, product, "[data-product-option-change]")
+			if processProduct(ctx, breadcrumbs, url, product, availability) {
 				found = true
 			}
 		})
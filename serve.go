@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/toebes/ftc_parts_spider/dashboard"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// serveCommand loads one or more run outputs and serves a web UI for looking through them
+//
+//	ftc_parts_spider serve [-addr localhost:8080] rev.txt [servocity.txt ...]
+func serveCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	images := fs.String("images", "", "Directory of thumbnails saved by a run with -images")
+	logging := loggingFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s serve [flags] <run output> ...\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := logging("command", fs.Name()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var runs []dashboard.Run
+	for _, path := range fs.Args() {
+		parts, err := partcatalog.LoadRunOutput(path)
+		if err != nil {
+			slog.Error("unable to load run output", "phase", "load", "path", path, "err", err)
+			return 1
+		}
+		slog.Info("loaded run output", "phase", "load", "path", path, "parts", len(parts))
+		runs = append(runs, dashboard.Run{Name: filepath.Base(path), Parts: parts})
+	}
+
+	server, err := dashboard.New(runs, *images)
+	if err != nil {
+		slog.Error("unable to create the dashboard", "phase", "load", "err", err)
+		return 1
+	}
+	slog.Info("serving", "phase", "serve", "url", "http://"+*addr+"/")
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		slog.Error("server stopped", "phase", "serve", "err", err)
+		return 1
+	}
+	return 0
+}
@@ -1,10 +1,12 @@
 package servocity
 
 import (
-	"fmt"
+	"log/slog"
 	"regexp"
 	"strings"
 
+	"github.com/toebes/ftc_parts_spider/embedjson"
+	"github.com/toebes/ftc_parts_spider/onshape"
 	"github.com/toebes/ftc_parts_spider/partcatalog"
 	"github.com/toebes/ftc_parts_spider/spiderdata"
 
@@ -15,6 +17,7 @@ import (
 var ServocityTarget = spiderdata.SpiderTarget{
 	Outfile:       "servocity.txt",
 	SpreadsheetID: "15Mm-Thdcpl5fVPs3vnyFUXWthuaV1tacXPJ7xQuoB8A",
+	OnshapeNaming: onshape.Naming{Vendor: "ServoCity"},
 	Presets: []string{"https://www.servocity.com/structure/",
 		"https://www.servocity.com/motion/",
 		"https://www.servocity.com/electronics/",
@@ -25,6 +28,12 @@ var ServocityTarget = spiderdata.SpiderTarget{
 	Seed:           "https://www.servocity.com/electronics/",
 	ParsePageFunc:  ParseServocityPage,
 	CheckMatchFunc: CheckServocityMatch,
+	IncludeRules:   []spiderdata.URLRule{spiderdata.Host("www.servocity.com")},
+	TrailingSlash:  true,
+	StorefrontAPI:  true,
+
+	// The options of a product are picked with ?sku=, so those are different pages
+	SignificantParams: []string{"sku"},
 
 	SectionNameDeletes: []string{
 		"Shop by Electrical Connector Style > ",
@@ -160,7 +169,7 @@ func CheckServocityMatch(ctx *spiderdata.Context, partData *partcatalog.PartData
 		}
 		// If the URL changes then we really want to use it.
 		// Just stash away the old URL so we know what happened
-		if !strings.EqualFold(partData.URL, entry.URL) {
+		if !spiderdata.SameURL(ctx, partData.URL, entry.URL) {
 			// In the case where there was a sku= on the URL we want to keep the one with it
 			urlString := partData.URL
 			newURL, strippedNew := spiderdata.CleanURL(ctx, partData.URL)
@@ -172,6 +181,9 @@ func CheckServocityMatch(ctx *spiderdata.Context, partData *partcatalog.PartData
 			// had the URL silently.
 			if strings.EqualFold(oldURL, newURL) {
 				partData.URL = urlString
+			} else if spiderdata.ReconcileMovedURL(ctx, partData, entry) {
+				// The catalog URL redirects here, so it has just moved.  Keep the new one and
+				// leave it for the URL moved report
 			} else {
 				partData.SpiderStatus = partcatalog.PartChanged
 				partData.Notes += extra + " Old URL:" + entry.URL
@@ -196,6 +208,8 @@ func CheckServocityMatch(ctx *spiderdata.Context, partData *partcatalog.PartData
 		}
 		// Prevent us from outputting the same entry more than once.
 		entry.SpiderStatus = partData.SpiderStatus
+		// Remember which row of the spreadsheet it came from so that any changes can be applied to it
+		partData.Row = entry.Row
 	} else {
 		partData.SpiderStatus = partcatalog.NewPart
 		partData.Status = "Not Done"
@@ -222,7 +236,7 @@ func findAllDownloads(ctx *spiderdata.Context, url string, root *goquery.Selecti
 			title = strings.Replace(title, ".zip", "", -1)
 			title = strings.TrimSpace(title)
 			result[title] = spiderdata.DownloadEnt{URL: dlurl, Used: false}
-			fmt.Printf("Save Download '%s'='%s'\n", title, dlurl)
+			slog.Debug("save download", "title", title, "href", dlurl)
 		} else {
 			if title == "" {
 				spiderdata.OutputError(ctx, "No URL found associated with %s on %s\n", title, url)
@@ -405,7 +419,6 @@ func getBreadCrumbName(ctx *spiderdata.Context, url string, bc *goquery.Selectio
 
 func processSubCategory(ctx *spiderdata.Context, breadcrumbs string, categoryproducts *goquery.Selection) (found bool) {
 	found = false
-	fmt.Printf("processSubCategory\n")
 	categoryproducts.Find("li.navList-item,li.navPages-item").Each(func(i int, item *goquery.Selection) {
 		// fmt.Printf("-Found Category product LI element\n")
 		item.Find("a.navList-action,a.navPages-action").Each(func(i int, elem *goquery.Selection) {
@@ -414,7 +427,7 @@ func processSubCategory(ctx *spiderdata.Context, breadcrumbs string, categorypro
 			elem.Find("span").Each(func(i int, span *goquery.Selection) {
 				elemtext = span.Text()
 			})
-			fmt.Printf("Found item name=%s url=%s\n", elemtext, url)
+			slog.Debug("found subcategory", "name", elemtext, "url", url)
 			found = true
 			if !ctx.G.SingleOnly {
 				spiderdata.EnqueURL(ctx, url, breadcrumbs)
@@ -428,12 +441,11 @@ func processSubCategory(ctx *spiderdata.Context, breadcrumbs string, categorypro
 // processProductGrid takes a standard page which has a single product on it and outputs the information
 func processProductGrid(ctx *spiderdata.Context, breadcrumbs string, url string, pg *goquery.Selection) (found bool) {
 	found = false
-	fmt.Printf("Parents found: %d\n", pg.ParentFiltered("div.tab-content").Length())
 	if pg.ParentFiltered("div.tab-content").Length() == 0 {
 		pg.Find("li.product a[data-card-type],li.product a.card").Each(func(i int, a *goquery.Selection) {
 			urlloc, _ := a.Attr("href")
 			product, _ := a.Attr("title")
-			fmt.Printf("**ProductGrid Found item name=%v url=%v on %v\n", product, urlloc, url)
+			slog.Debug("product grid item", "name", product, "url", urlloc, "page", url)
 			found = true
 			if !ctx.G.SingleOnly {
 				spiderdata.EnqueURL(ctx, urlloc, spiderdata.MakeBreadCrumb(ctx, breadcrumbs, product))
@@ -453,7 +465,7 @@ func processProductTableList(ctx *spiderdata.Context, breadcrumbs string, _ /*ur
 			urlloc, _ := a.Attr("href")
 			product := a.Text()
 			product = strings.Trim(strings.ReplaceAll(product, "\n", ""), " ")
-			fmt.Printf("**processProductTableList Found item name=%s url=%s\n", product, urlloc)
+			slog.Debug("product table item", "name", product, "url", urlloc)
 			found = true
 			if !ctx.G.SingleOnly {
 				spiderdata.EnqueURL(ctx, urlloc, spiderdata.MakeBreadCrumb(ctx, breadcrumbs, product))
@@ -464,50 +476,19 @@ func processProductTableList(ctx *spiderdata.Context, breadcrumbs string, _ /*ur
 }
 
 // --------------------------------------------------------------------------------------------
-// processLazyLoad finds all the lazy loaded sub pages
+// processLazyLoad finds all the lazy loaded sub pages.  They are listed in the JSON passed to the
+// BigCommerce bootstrap, which looks like
+//
+//	window.stencilBootstrap("category", "{\"subcategories\":[{\"url\":\"https://www.servocity.com/chain/\",...}],...}").load();
 func processLazyLoad(ctx *spiderdata.Context, breadcrumbs string, _ /*url*/ string, js *goquery.Selection) (found bool) {
-	jstext := js.Text()
-	pos := strings.Index(jstext, "window.stencilBootstrap(")
-	if pos > 0 {
-		// fmt.Printf("Found Bootstrap: %v", jstext)
-		pos := strings.Index(jstext, "subcategories")
-		if pos > 0 {
-			jstext = jstext[pos:]
-			pos = strings.Index(jstext, ":[")
-			if pos > 0 {
-				pos2 := strings.Index(jstext, "],")
-				// fmt.Printf("Found Javascript pos=%d pos2=%d '%s'\n", pos, pos2, jstext[pos:pos2])
-				if pos2 > 0 {
-					// //         <script>
-					// // Exported in app.js
-					// window.stencilBootstrap("category", "{\"categoryProductsPerPage\":50,
-					// 				   \"subcategoryURLs\":[\"https://www.gobilda.com/chain/\",
-					// 				   \"https://www.gobilda.com/set-screw-sprockets/\",
-					// 				   \"https://www.gobilda.com/14mm-bore-aluminum-hub-mount-sprockets/\",
-					// 				   \"https://www.gobilda.com/14mm-bore-plastic-hub-mount-sprockets/\",
-					// 				   \"https://www.gobilda.com/32mm-bore-aluminum-hub-mount-sprockets/\"],
-					// 				   \"template\":\"pages/custom/category/category-stacked-shortest-name\",
-					// 				   \"themeSettings\":{\"alert-backgroundColor\":\"#ffffff\",\"alert-color\":\"#333333\",\"alert-color-alt\":\"#ffffff\",\"applePay-button\":\"black\",\"blockquote-cite-font-color\":\"#999999\",\"blog_size\":\"190x250\",\"body-bg\":\"#ffffff\",\"body-font\":\"Google_Roboto_300\",\"brand_size\":\"190x250\",\"brandpage_products_per_page\":12,\"button--default-borderColor\":\"#cccccc\",\"button--default-borderColorActive\":\"#757575\",\"button--default-borderColorHover\":\"#999999\",\"button--default-color\":\"#666666\",\"button--default-colorActive\":\"#000000\",\"button--default-colorHover\":\"#333333\",\"button--disabled-backgroundColor\":\"#cccccc\",\"button--disabled-borderColor\":\"transparent\",\"button--disabled-color\":\"#ffffff\",\"button--icon-svg-color\":\"#757575\",\"button--primary-backgroundColor\":\"#444444\",\"button--primary-backgroundColorActive\":\"#000000\",\"button--primary-backgroundColorHover\":\"#666666\",\"button--primary-color\":\"#ffffff\",\"button--primary-colorActive\":\"#ffffff\",\"button--primary-colorHover\":\"#ffffff\",\"card--alternate-backgroundColor\":\"#ffffff\",\"card--alternate-borderColor\":\"#ffffff\",\"card--alternate-color--hover\":\"#ffffff\",\"card-figcaption-button-background\":\"#ffffff\",\"card-figcaption-button-color\":\"#333333\",\"card-title-color\":\"#333333\",\"card-title-color-hover\":\"#757575\",\"carousel-arrow-bgColor\":\"#ffffff\",\"carousel-arrow-borderColor\":\"#ffffff\",\"carousel-arrow-color\":\"#999999\",\"carousel-bgColor\":\"#ffffff\",\"carousel-description-color\":\"#333333\",\"carousel-dot-bgColor\":\"#ffffff\",\"carousel-dot-color\":\"#333333\",\"carousel-dot-color-active\":\"#757575\",\"carousel-title-color\":\"#444444\",\"categorypage_products_per_page\":50,\"checkRadio-backgroundColor\":\"#ffffff\",\"checkRadio-borderColor\":\"#cccccc\",\"checkRadio-color\":\"#333333\",\"color-black\":\"#ffffff\",\"color-error\":\"#cc4749\",\"color-errorLight\":\"#ffdddd\",\"color-grey\":\"#999999\",\"color-greyDark\":\"#666666\",\"color-greyDarker\":\"#333333\",\"color-greyDarkest\":\"#000000\",\"color-greyLight\":\"#999999\",\"color-greyLighter\":\"#cccccc\",\"color-greyLightest\":\"#e5e5e5\",\"color-greyMedium\":\"#757575\",\"color-info\":\"#666666\",\"color-infoLight\":\"#dfdfdf\",\"color-primary\":\"#757575\",\"color-primaryDark\":\"#666666\",\"color-primaryDarker\":\"#333333\",\"color-primaryLight\":\"#999999\",\"color-secondary\":\"#ffffff\",\"color-secondaryDark\":\"#e5e5e5\",\"color-secondaryDarker\":\"#cccccc\",\"color-success\":\"#008a06\",\"color-successLight\":\"#d5ffd8\",\"color-textBase\":\"#333333\",\"color-textBase--active\":\"#757575\",\"color-textBase--hover\":\"#757575\",\"color-textHeading\":\"#444444\",\"color-textLink\":\"#333333\",\"color-textLink--active\":\"#757575\",\"color-textLink--hover\":\"#757575\",\"color-textSecondary\":\"#757575\",\"color-textSecondary--active\":\"#333333\",\"color-textSecondary--hover\":\"#333333\",\"color-warning\":\"#f1a500\",\"color-warningLight\":\"#fffdea\",\"color-white\":\"#ffffff\",\"color-whitesBase\":\"#e5e5e5\",\"color_actobotics-green\":\"#43b02a\",\"color_badge_product_sale_badges\":\"#007dc6\",\"color_gobilda-grey\":\"#2c2c2c\",\"color_gobilda-yellow\":\"#fad000\",\"color_hover_product_sale_badges\":\"#000000\",\"color_robotzone-red\":\"#da291c\",\"color_servocity-blue\":\"#0077c8\",\"color_text_product_sale_badges\":\"#ffffff\",\"container-border-global-color-base\":\"#e5e5e5\",\"container-fill-base\":\"#ffffff\",\"container-fill-dark\":\"#e5e5e5\",\"default_image_brand\":\"/assets/img/BrandDefault.gif\",\"default_image_gift_certificate\":\"/assets/img/GiftCertificate.png\",\"default_image_product\":\"/assets/img/ProductDefault.gif\",\"dropdown--quickSearch-backgroundColor\":\"#e5e5e5\",\"fontSize-h1\":28,\"fontSize-h2\":25,\"fontSize-h3\":22,\"fontSize-h4\":20,\"fontSize-h5\":15,\"fontSize-h6\":13,\"fontSize-root\":14,\"footer-backgroundColor\":\"#ffffff\",\"form-label-font-color\":\"#666666\",\"gallery_size\":\"300x300\",\"geotrust_ssl_common_name\":\"\",\"geotrust_ssl_seal_size\":\"M\",\"header-backgroundColor\":\"#ffffff\",\"headings-font\":\"Google_Montserrat_400\",\"hide_content_navigation\":false,\"homepage_blog_posts_count\":3,\"homepage_featured_products_column_count\":6,\"homepage_featured_products_count\":8,\"homepage_new_products_column_count\":6,\"homepage_new_products_count\":12,\"homepage_show_carousel\":true,\"homepage_stretch_carousel_images\":false,\"homepage_top_products_column_count\":6,\"homepage_top_products_count\":8,\"icon-color\":\"#757575\",\"icon-color-hover\":\"#999999\",\"icon-ratingEmpty\":\"#cccccc\",\"icon-ratingFull\":\"#757575\",\"input-bg-color\":\"#ffffff\",\"input-border-color\":\"#cccccc\",\"input-border-color-active\":\"#999999\",\"input-disabled-bg\":\"#ffffff\",\"input-font-color\":\"#666666\",\"label-backgroundColor\":\"#cccccc\",\"label-color\":\"#ffffff\",\"loadingOverlay-backgroundColor\":\"#ffffff\",\"logo-font\":\"Google_Oswald_300\",\"logo-position\":\"center\",\"logo_fontSize\":28,\"logo_size\":\"250x100\",\"medium_size\":\"800x800\",\"navPages-color\":\"#333333\",\"navPages-color-hover\":\"#757575\",\"navPages-subMenu-backgroundColor\":\"#e5e5e5\",\"navPages-subMenu-separatorColor\":\"#cccccc\",\"navUser-color\":\"#333333\",\"navUser-color-hover\":\"#757575\",\"navUser-dropdown-backgroundColor\":\"#ffffff\",\"navUser-dropdown-borderColor\":\"#cccccc\",\"navUser-indicator-backgroundColor\":\"#333333\",\"navigation_design\":\"simple\",\"optimizedCheckout-backgroundImage\":\"\",\"optimizedCheckout-backgroundImage-size\":\"1000x400\",\"optimizedCheckout-body-backgroundColor\":\"#ffffff\",\"optimizedCheckout-buttonPrimary-backgroundColor\":\"#333333\",\"optimizedCheckout-buttonPrimary-backgroundColorActive\":\"#000000\",\"optimizedCheckout-buttonPrimary-backgroundColorDisabled\":\"#cccccc\",\"optimizedCheckout-buttonPrimary-backgroundColorHover\":\"#666666\",\"optimizedCheckout-buttonPrimary-borderColor\":\"#cccccc\",\"optimizedCheckout-buttonPrimary-borderColorActive\":\"transparent\",\"optimizedCheckout-buttonPrimary-borderColorDisabled\":\"transparent\",\"optimizedCheckout-buttonPrimary-borderColorHover\":\"transparent\",\"optimizedCheckout-buttonPrimary-color\":\"#ffffff\",\"optimizedCheckout-buttonPrimary-colorActive\":\"#ffffff\",\"optimizedCheckout-buttonPrimary-colorDisabled\":\"#ffffff\",\"optimizedCheckout-buttonPrimary-colorHover\":\"#ffffff\",\"optimizedCheckout-buttonPrimary-font\":\"Google_Roboto_300\",\"optimizedCheckout-buttonSecondary-backgroundColor\":\"#ffffff\",\"optimizedCheckout-buttonSecondary-backgroundColorActive\":\"#e5e5e5\",\"optimizedCheckout-buttonSecondary-backgroundColorHover\":\"#f5f5f5\",\"optimizedCheckout-buttonSecondary-borderColor\":\"#cccccc\",\"optimizedCheckout-buttonSecondary-borderColorActive\":\"#757575\",\"optimizedCheckout-buttonSecondary-borderColorHover\":\"#999999\",\"optimizedCheckout-buttonSecondary-color\":\"#333333\",\"optimizedCheckout-buttonSecondary-colorActive\":\"#000000\",\"optimizedCheckout-buttonSecondary-colorHover\":\"#333333\",\"optimizedCheckout-buttonSecondary-font\":\"Google_Karla_400\",\"optimizedCheckout-colorFocus\":\"#4496f6\",\"optimizedCheckout-contentPrimary-color\":\"#333333\",\"optimizedCheckout-contentPrimary-font\":\"Google_Roboto_300\",\"optimizedCheckout-contentSecondary-color\":\"#757575\",\"optimizedCheckout-contentSecondary-font\":\"Google_Roboto_300\",\"optimizedCheckout-discountBanner-backgroundColor\":\"#e5e5e5\",\"optimizedCheckout-discountBanner-iconColor\":\"#333333\",\"optimizedCheckout-discountBanner-textColor\":\"#333333\",\"optimizedCheckout-form-textColor\":\"#666666\",\"optimizedCheckout-formChecklist-backgroundColor\":\"#ffffff\",\"optimizedCheckout-formChecklist-backgroundColorSelected\":\"#f5f5f5\",\"optimizedCheckout-formChecklist-borderColor\":\"#cccccc\",\"optimizedCheckout-formChecklist-color\":\"#333333\",\"optimizedCheckout-formField-backgroundColor\":\"#ffffff\",\"optimizedCheckout-formField-borderColor\":\"#cccccc\",\"optimizedCheckout-formField-errorColor\":\"#d14343\",\"optimizedCheckout-formField-inputControlColor\":\"#476bef\",\"optimizedCheckout-formField-placeholderColor\":\"#999999\",\"optimizedCheckout-formField-shadowColor\":\"#e5e5e5\",\"optimizedCheckout-formField-textColor\":\"#333333\",\"optimizedCheckout-header-backgroundColor\":\"#f5f5f5\",\"optimizedCheckout-header-borderColor\":\"#dddddd\",\"optimizedCheckout-header-textColor\":\"#333333\",\"optimizedCheckout-headingPrimary-color\":\"#333333\",\"optimizedCheckout-headingPrimary-font\":\"Google_Montserrat_400\",\"optimizedCheckout-headingSecondary-color\":\"#333333\",\"optimizedCheckout-headingSecondary-font\":\"Google_Montserrat_400\",\"optimizedCheckout-link-color\":\"#476bef\",\"optimizedCheckout-link-font\":\"Google_Karla_400\",\"optimizedCheckout-link-hoverColor\":\"#002fe1\",\"optimizedCheckout-loadingToaster-backgroundColor\":\"#333333\",\"optimizedCheckout-loadingToaster-textColor\":\"#ffffff\",\"optimizedCheckout-logo\":\"\",\"optimizedCheckout-logo-position\":\"left\",\"optimizedCheckout-logo-size\":\"250x100\",\"optimizedCheckout-orderSummary-backgroundColor\":\"#ffffff\",\"optimizedCheckout-orderSummary-borderColor\":\"#dddddd\",\"optimizedCheckout-show-backgroundImage\":false,\"optimizedCheckout-show-logo\":\"none\",\"optimizedCheckout-step-backgroundColor\":\"#757575\",\"optimizedCheckout-step-borderColor\":\"#dddddd\",\"optimizedCheckout-step-textColor\":\"#ffffff\",\"overlay-backgroundColor\":\"#333333\",\"pace-progress-backgroundColor\":\"#999999\",\"price_ranges\":true,\"product_list_display_mode\":\"grid\",\"product_sale_badges\":\"none\",\"product_size\":\"500x659\",\"productgallery_size\":\"318x318\",\"productgallery_size_three_column\":\"712x712\",\"productpage_related_products_count\":100,\"productpage_reviews_count\":9,\"productpage_similar_by_views_count\":10,\"productpage_videos_count\":8,\"productthumb_size\":\"100x100\",\"productview_thumb_size\":\"50x50\",\"restrict_to_login\":false,\"searchpage_products_per_page\":12,\"select-arrow-color\":\"#757575\",\"select-bg-color\":\"#ffffff\",\"shop_by_brand_show_footer\":true,\"shop_by_price_visible\":true,\"show_accept_amex\":false,\"show_accept_discover\":false,\"show_accept_mastercard\":false,\"show_accept_paypal\":false,\"show_accept_visa\":false,\"show_copyright_footer\":true,\"show_powered_by\":true,\"show_product_details_tabs\":true,\"show_product_dimensions\":false,\"show_product_quick_view\":true,\"show_product_weight\":true,\"social_icon_placement_bottom\":\"bottom_none\",\"social_icon_placement_top\":false,\"spinner-borderColor-dark\":\"#999999\",\"spinner-borderColor-light\":\"#ffffff\",\"storeName-color\":\"#333333\",\"swatch_option_size\":\"22x22\",\"thumb_size\":\"100x100\",\"zoom_size\":\"1280x1280\"},\"genericError\":\"Oops! Something went wrong.\",\"maintenanceMode\":{\"header\":null,\"message\":null,\"notice\":null,\"password\":null,\"securePath\":\"https://www.gobilda.com\"},\"urls\":{\"account\":{\"add_address\":\"/account.php?action=add_shipping_address\",\"addresses\":\"/account.php?action=address_book\",\"details\":\"/account.php?action=account_details\",\"inbox\":\"/account.php?action=inbox\",\"index\":\"/account.php\",\"orders\":{\"all\":\"/account.php?action=order_status\",\"completed\":\"/account.php?action=view_orders\",\"save_new_return\":\"/account.php?action=save_new_return\"},\"recent_items\":\"/account.php?action=recent_items\",\"returns\":\"/account.php?action=view_returns\",\"send_message\":\"/account.php?action=send_message\",\"update_action\":\"/account.php?action=update_account\",\"wishlists\":{\"add\":\"/wishlist.php?action=addwishlist\",\"all\":\"/wishlist.php\",\"delete\":\"/wishlist.php?action=deletewishlist\",\"edit\":\"/wishlist.php?action=editwishlist\"}},\"auth\":{\"check_login\":\"/login.php?action=check_login\",\"create_account\":\"/login.php?action=create_account\",\"forgot_password\":\"/login.php?action=reset_password\",\"login\":\"/login.php\",\"logout\":\"/login.php?action=logout\",\"save_new_account\":\"/login.php?action=save_new_account\",\"save_new_password\":\"/login.php?action=save_new_password\",\"send_password_email\":\"/login.php?action=send_password_email\"},\"brands\":\"https://www.gobilda.com/brands/\",\"cart\":\"/cart.php\",\"checkout\":{\"multiple_address\":\"/checkout.php?action=multiple\",\"single_address\":\"/checkout\"},\"compare\":\"/compare\",\"contact_us_submit\":\"/pages.php?action=sendContactForm\",\"gift_certificate\":{\"balance\":\"/giftcertificates.php?action=balance\",\"purchase\":\"/giftcertificates.php\",\"redeem\":\"/giftcertificates.php?action=redeem\"},\"home\":\"https://www.gobilda.com/\",\"product\":{\"post_review\":\"/postreview.php\"},\"rss\":{\"blog\":\"/rss.php?action=newblogs&type=rss\",\"blog_atom\":\"/rss.php?action=newblogs&type=atom\",\"products\":[]},\"search\":\"/search.php\",\"sitemap\":\"/sitemap.php\",\"subscribe\":{\"action\":\"/subscribe.php\"}}}").load();
-					// </script>
-					jstext = strings.ReplaceAll(jstext[pos+2:pos2], "\\\"", "\"")
-					urlset := strings.Split(jstext, ",")
-					for _, url := range urlset {
-						pos3 := strings.Index(url, "\"url\":\"")
-						if pos3 >= 0 {
-							urlpart := url[pos3+7:]
-							pos4 := strings.Index(urlpart, "\"")
-							if pos4 > 0 {
-								urlpart = urlpart[:pos4]
-							}
-							urlpart = strings.Trim(urlpart, "\"")
-							found = true
-							if !ctx.G.SingleOnly {
-								spiderdata.EnqueURL(ctx, urlpart, breadcrumbs)
-							}
-						}
-					}
-				}
-			}
+	args, hascall := embedjson.FromCall(js.Text(), "window.stencilBootstrap")
+	if !hascall || len(args) < 2 {
+		return
+	}
+	for _, urlpart := range args[1].Strings("subcategories[*].url") {
+		found = true
+		if !ctx.G.SingleOnly {
+			spiderdata.EnqueURL(ctx, urlpart, breadcrumbs)
 		}
 	}
 	return
@@ -515,7 +496,7 @@ func processLazyLoad(ctx *spiderdata.Context, breadcrumbs string, _ /*url*/ stri
 
 // --------------------------------------------------------------------------------------------
 // processProduct takes a standard page which has a single product on it and outputs the information
-func processProduct(ctx *spiderdata.Context, productname string, url string, product *goquery.Selection, isDiscontinued bool, addSKU bool) (found bool) {
+func processProduct(ctx *spiderdata.Context, productname string, url string, product *goquery.Selection, availability spiderdata.Availability, addSKU bool) (found bool) {
 	found = false
 	spiderdata.OutputCategory(ctx, productname, false)
 	localname := product.Find(".productView-header h1.productView-title").Text()
@@ -526,7 +507,6 @@ func processProduct(ctx *spiderdata.Context, productname string, url string, pro
 	if !hassku {
 		sku, hassku = product.Find("meta[itemprop=\"sku\"]").Attr("content")
 	}
-	fmt.Printf("Process Product\n")
 	changeset := product.Find("div.available")
 	//  <div data-product-option-change="" style="">
 	//    <div class="form-field" data-product-attribute="set-radio">
@@ -546,16 +526,37 @@ func processProduct(ctx *spiderdata.Context, productname string, url string, pro
 	}
 
 	downloadurls := findAllDownloads(ctx, url, product)
+	parentID := spiderdata.ProductID(product)
+	specs := spiderdata.ExtractSpecs(product)
+	images := spiderdata.ExtractImages(ctx, product)
 	if hassku {
-		if changeset.Children().Length() > 0 {
+		spiderdata.RecordKit(ctx, sku, localname, url, spiderdata.ExtractKitComponents(ctx, product))
+		if parts, ok := spiderdata.StorefrontParts(ctx, product, url); ok {
+			// The storefront API knows the SKU of every option, even the ones the page can't tell us
+			availability := spiderdata.StorefrontAvailability(ctx, availability)
+			for _, partData := range parts {
+				if addSKU && partData.ParentID == "" {
+					partData.URL, _ = spiderdata.CleanURL(ctx, url)
+					partData.URL += "?sku=" + partData.SKU
+				}
+				partData.ModelURL = getDownloadURL(ctx, sku, downloadurls)
+				partData.Specs = specs
+				if len(images) > 0 {
+					partData.Images = images
+				}
+				spiderdata.OutputProductData(ctx, partData, availability)
+			}
+		} else if changeset.Children().Length() > 0 {
 			changeset.Find("input.childProductOption").Each(func(i int, input *goquery.Selection) {
 				itemname := localname
+				var variant []partcatalog.VariantAttribute
 				itemsku, hassku := input.Attr("data-sku")
 				if hassku {
 					// We have the SKU, so we need to pop up to the parent and find the H4 entry with the name
 					itemextraname := input.Parent().Find("h4.card-title")
 					if itemextraname.Length() > 0 {
 						itemname += " - " + strings.TrimSpace(itemextraname.Text())
+						variant = append(variant, partcatalog.VariantAttribute{Name: optionLabel(input), Value: strings.TrimSpace(itemextraname.Text())})
 					}
 				} else {
 					itemsku = sku
@@ -574,17 +575,27 @@ func processProduct(ctx *spiderdata.Context, productname string, url string, pro
 						label := changeset.Find(tofind)
 						if label.Length() > 0 {
 							itemname += " " + label.Text()
+							variant = append(variant, partcatalog.VariantAttribute{Name: optionLabel(input), Value: strings.TrimSpace(label.Text())})
 						}
 					}
 				}
-				spiderdata.OutputProduct(ctx, itemname, itemsku, url, getDownloadURL(ctx, sku, downloadurls), isDiscontinued, nil)
+				partData := spiderdata.NewProductData(itemname, itemsku, url, getDownloadURL(ctx, sku, downloadurls), nil)
+				partData.ParentID = parentID
+				partData.BaseName = strings.TrimSpace(localname)
+				partData.Variant = variant
+				partData.Specs = specs
+				partData.Images = images
+				spiderdata.OutputProductData(ctx, partData, availability)
 			})
 		} else {
 			if addSKU {
 				url, _ = spiderdata.CleanURL(ctx, url)
 				url += "?sku=" + sku
 			}
-			spiderdata.OutputProduct(ctx, localname, sku, url, getDownloadURL(ctx, sku, downloadurls), isDiscontinued, nil)
+			partData := spiderdata.NewProductData(localname, sku, url, getDownloadURL(ctx, sku, downloadurls), nil)
+			partData.Specs = specs
+			partData.Images = images
+			spiderdata.OutputProductData(ctx, partData, availability)
 		}
 		found = true
 	}
@@ -652,7 +663,7 @@ func processTable(ctx *spiderdata.Context, productname string, url string, downl
 	thset := table.Find("thead tr th")
 	if thset.Length() < 1 {
 		thset = table.Find("tr:first-child td")
-		fmt.Printf("Secondary set length=%d\n", thset.Length())
+		slog.Debug("table has no header, using first row", "columns", thset.Length())
 	}
 	thset.Each(func(i int, th *goquery.Selection) {
 		p := th.Find("p")
@@ -686,6 +697,8 @@ func processTable(ctx *spiderdata.Context, productname string, url string, downl
 		table.Find("tbody tr").Each(func(i int, tr *goquery.Selection) {
 			sku := ""
 			var outpad []string
+			// Every column other than the SKU is also a specification of the part in that row
+			specs := make(map[string]partcatalog.SpecValue)
 			outname := productname
 			tr.Find("td").Each(func(i int, td *goquery.Selection) {
 				column := ""
@@ -695,6 +708,9 @@ func processTable(ctx *spiderdata.Context, productname string, url string, downl
 				} else {
 					column = td.Text()
 				}
+				if action := colnames[i].action; action != actSKU && action != actSkip && strings.TrimSpace(column) != "" {
+					specs[partcatalog.SpecName(colnames[i].name)] = partcatalog.ParseSpecValue(column)
+				}
 				switch colnames[i].action {
 				case actSKU:
 					sku = column
@@ -714,7 +730,9 @@ func processTable(ctx *spiderdata.Context, productname string, url string, downl
 				default:
 				}
 			})
-			spiderdata.OutputProduct(ctx, outname, sku, url, getDownloadURL(ctx, sku, downloadurls), false, outpad)
+			partData := spiderdata.NewProductData(outname, sku, url, getDownloadURL(ctx, sku, downloadurls), outpad)
+			partData.Specs = spiderdata.MergeSpecs(nil, specs)
+			spiderdata.OutputProductData(ctx, partData, spiderdata.Available)
 		})
 	}
 	return
@@ -834,6 +852,17 @@ func processTable(ctx *spiderdata.Context, productname string, url string, downl
 // 	return
 // }
 
+// optionLabel finds what a set of options is choosing (Color, Length, Bore...) from the label of the form
+// field holding the option, or the legend of the field when it doesn't have a label
+func optionLabel(input *goquery.Selection) string {
+	field := input.Closest(".form-field")
+	label := field.Find("label.form-label--alternate").First()
+	if label.Length() == 0 {
+		label = field.Find("legend").First()
+	}
+	return spiderdata.OptionName(label.Text(), "Option")
+}
+
 func processSimpleProductTable(ctx *spiderdata.Context, breadcrumbs string, url string, productname string, root *goquery.Selection, table *goquery.Selection) (found bool) {
 	found = false
 	downloadurls := findAllDownloads(ctx, url, root)
@@ -858,19 +887,19 @@ func ParseServocityPage(ctx *spiderdata.Context, doc *goquery.Document) {
 	spiderdata.MarkVisitedURL(ctx, url, breadcrumbs)
 
 	// see if this has been discontinued
-	isDiscontinued := (doc.Find("p.discontinued").Length() > 0)
+	availability := spiderdata.Available
+	if doc.Find("p.discontinued").Length() > 0 {
+		availability = spiderdata.Discontinued
+	}
 
-	fmt.Printf("Breadcrumb:%s\n", breadcrumbs)
+	slog.Debug("parse page", "url", url, "breadcrumb", breadcrumbs)
 	doc.Find("ul.navPages-list").Each(func(i int, categoryproducts *goquery.Selection) {
-		fmt.Printf("Found Navigation List\n")
 		if processSubCategory(ctx, breadcrumbs, categoryproducts) {
 			found = false
 		}
 	})
 	if !found {
-		fmt.Printf("Looking for productGrid\n")
 		doc.Find("ul.productGrid,ul.threeColumnProductGrid,div.productTableWrapper").Each(func(i int, product *goquery.Selection) {
-			fmt.Printf("ProcessingProductGrid\n")
 			if processProductGrid(ctx, breadcrumbs, url, product) {
 				found = true
 				// } else if processProductViewWithTable(ctx, breadcrumbs, url, product) {
@@ -881,7 +910,7 @@ func ParseServocityPage(ctx *spiderdata.Context, doc *goquery.Document) {
 	if !found {
 		products := doc.Find("div[itemtype=\"http://schema.org/Product\"]")
 		products.Each(func(i int, product *goquery.Selection) {
-			if processProduct(ctx, breadcrumbs, url, product, isDiscontinued, products.Length() > 1) {
+			if processProduct(ctx, breadcrumbs, url, product, availability, products.Length() > 1) {
 				found = true
 			}
 		})
@@ -892,7 +921,7 @@ func ParseServocityPage(ctx *spiderdata.Context, doc *goquery.Document) {
 			products := doc.Find("header.productView-header")
 
 			products.Each(func(i int, product *goquery.Selection) {
-				if processProduct(ctx, breadcrumbs, url, product.Parent(), isDiscontinued, products.Length() > 1) {
+				if processProduct(ctx, breadcrumbs, url, product.Parent(), availability, products.Length() > 1) {
 					found = true
 				}
 			})
@@ -925,7 +954,9 @@ func ParseServocityPage(ctx *spiderdata.Context, doc *goquery.Document) {
 	doc.Find("div.product-related a[data-card-type]").Each(func(i int, a *goquery.Selection) {
 		urlloc, _ := a.Attr("href")
 		product, _ := a.Attr("title")
-		fmt.Printf("**Related Found item name=%s url=%s\n", product, urlloc)
+		slog.Debug("related product", "name", product, "url", urlloc)
+		cardType, _ := a.Attr("data-card-type")
+		spiderdata.RecordRelated(ctx, urlloc, cardType, product)
 		if !ctx.G.SingleOnly {
 			spiderdata.EnqueURL(ctx, urlloc, spiderdata.MakeBreadCrumb(ctx, breadcrumbs, product))
 		}
@@ -0,0 +1,209 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Entry is one page listed in a sitemap
+type Entry struct {
+	Loc     string
+	LastMod time.Time // Zero when the sitemap doesn't say
+}
+
+// Options control which of the pages in the sitemaps are returned
+type Options struct {
+	// Since skips any page (or whole sitemap in an index) which has a lastmod before it
+	Since time.Time
+	// Allow keeps only the pages it returns true for (such as the ones the URL rules of the target
+	// allow).  All pages are kept when it is nil
+	Allow func(loc string) bool
+}
+
+// maxDepth limits how deeply sitemap index files can refer to other index files
+const maxDepth = 4
+
+// maxSize is the largest sitemap we will read, the protocol limit is 50MB uncompressed
+const maxSize = 64 << 20
+
+// document holds either of the two kinds of sitemap, a urlset listing pages or a sitemapindex listing other sitemaps
+type document struct {
+	XMLName  xml.Name
+	URLs     []location `xml:"url"`
+	Sitemaps []location `xml:"sitemap"`
+}
+
+type location struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// lastModFormats are the W3C datetime forms allowed for lastmod
+var lastModFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// ParseLastMod reads a lastmod value, returning the zero time when it can't be understood
+func ParseLastMod(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, format := range lastModFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Allowed checks whether a page URL should be returned
+func (o *Options) Allowed(loc string) bool {
+	return o.Allow == nil || o.Allow(loc)
+}
+
+// recent checks whether something last modified at lastmod should be looked at
+func (o *Options) recent(lastmod time.Time) bool {
+	return o.Since.IsZero() || lastmod.IsZero() || !lastmod.Before(o.Since)
+}
+
+// Discover finds the sitemaps for the site that site is on and returns all of the pages in them.
+// The sitemaps come from the Sitemap lines in robots.txt, or /sitemap.xml when there aren't any.
+// Sitemap index files are followed and gzipped sitemaps are uncompressed.  A sitemap which can't be
+// read is logged and skipped so that one bad file doesn't lose the rest
+func Discover(client *http.Client, site *url.URL, opts Options) ([]Entry, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	root := &url.URL{Scheme: site.Scheme, Host: site.Host}
+	sitemaps, err := RobotsSitemaps(client, root.ResolveReference(&url.URL{Path: "/robots.txt"}).String())
+	if err != nil {
+		slog.Warn("unable to read robots.txt", "phase", "sitemap", "site", root.String(), "err", err)
+	}
+	if len(sitemaps) == 0 {
+		sitemaps = []string{root.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String()}
+	}
+
+	var result []Entry
+	seenPage := make(map[string]bool)
+	seenMap := make(map[string]bool)
+	found := false
+	var walk func(loc string, depth int)
+	walk = func(loc string, depth int) {
+		if seenMap[loc] {
+			return
+		}
+		seenMap[loc] = true
+		doc, err := fetch(client, loc)
+		if err != nil {
+			slog.Warn("unable to read sitemap", "phase", "sitemap", "url", loc, "err", err)
+			return
+		}
+		found = true
+		for _, child := range doc.Sitemaps {
+			childLoc := strings.TrimSpace(child.Loc)
+			if childLoc == "" || !opts.recent(ParseLastMod(child.LastMod)) {
+				continue
+			}
+			if depth >= maxDepth {
+				slog.Warn("sitemap index nested too deeply", "phase", "sitemap", "url", childLoc)
+				continue
+			}
+			walk(childLoc, depth+1)
+		}
+		kept := 0
+		for _, page := range doc.URLs {
+			entry := Entry{Loc: strings.TrimSpace(page.Loc), LastMod: ParseLastMod(page.LastMod)}
+			if entry.Loc == "" || seenPage[entry.Loc] || !opts.recent(entry.LastMod) || !opts.Allowed(entry.Loc) {
+				continue
+			}
+			seenPage[entry.Loc] = true
+			result = append(result, entry)
+			kept++
+		}
+		slog.Info("read sitemap", "phase", "sitemap", "url", loc, "sitemaps", len(doc.Sitemaps), "pages", len(doc.URLs), "kept", kept)
+	}
+	for _, loc := range sitemaps {
+		walk(loc, 0)
+	}
+	if !found {
+		return nil, fmt.Errorf("no sitemap found for %s", root.String())
+	}
+	return result, nil
+}
+
+// RobotsSitemaps returns the sitemaps listed in a robots.txt file
+func RobotsSitemaps(client *http.Client, robotsURL string) ([]string, error) {
+	res, err := client.Get(robotsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		// Not having a robots.txt is perfectly normal
+		return nil, nil
+	}
+	var result []string
+	scanner := bufio.NewScanner(io.LimitReader(res.Body, maxSize))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "sitemap") {
+			if value = strings.TrimSpace(value); value != "" {
+				result = append(result, value)
+			}
+		}
+	}
+	return result, scanner.Err()
+}
+
+// fetch reads and parses a single sitemap, uncompressing it if it is gzipped
+func fetch(client *http.Client, loc string) (*document, error) {
+	res, err := client.Get(loc)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxSize))
+	if err != nil {
+		return nil, err
+	}
+	// The Go client already undoes a gzip Content-Encoding, so this only catches .xml.gz files
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		body, err = io.ReadAll(io.LimitReader(zr, maxSize))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return parse(body)
+}
+
+// parse reads either a urlset or a sitemapindex document
+func parse(body []byte) (*document, error) {
+	var doc document
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+		return &doc, nil
+	}
+	return nil, fmt.Errorf("not a sitemap: <%s>", doc.XMLName.Local)
+}
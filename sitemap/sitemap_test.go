@@ -0,0 +1,187 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSite serves the files given by path.  {{site}} in a file is replaced with the address of the
+// server, and the paths which were asked for are recorded
+type testSite struct {
+	*httptest.Server
+	mu        sync.Mutex
+	requested []string
+}
+
+func newTestSite(t *testing.T, files map[string]string) *testSite {
+	t.Helper()
+	site := &testSite{}
+	site.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site.mu.Lock()
+		site.requested = append(site.requested, r.URL.Path)
+		site.mu.Unlock()
+		body, found := files[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		body = strings.ReplaceAll(body, "{{site}}", site.URL)
+		if strings.HasSuffix(r.URL.Path, ".gz") {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write([]byte(body))
+			zw.Close()
+			body = buf.String()
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(site.Close)
+	return site
+}
+
+func (site *testSite) discover(t *testing.T, opts Options) []Entry {
+	t.Helper()
+	root, err := url.Parse(site.URL + "/products/gears")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := Discover(site.Client(), root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func urlset(site string, pages ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for _, page := range pages {
+		loc, lastmod, _ := strings.Cut(page, " ")
+		b.WriteString("<url><loc>" + site + loc + "</loc>")
+		if lastmod != "" {
+			b.WriteString("<lastmod>" + lastmod + "</lastmod>")
+		}
+		b.WriteString("</url>")
+	}
+	b.WriteString("</urlset>")
+	return b.String()
+}
+
+func index(site string, sitemaps ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for _, sitemap := range sitemaps {
+		loc, lastmod, _ := strings.Cut(sitemap, " ")
+		b.WriteString("<sitemap><loc>" + site + loc + "</loc>")
+		if lastmod != "" {
+			b.WriteString("<lastmod>" + lastmod + "</lastmod>")
+		}
+		b.WriteString("</sitemap>")
+	}
+	b.WriteString("</sitemapindex>")
+	return b.String()
+}
+
+func TestDiscover(t *testing.T) {
+	site := newTestSite(t, map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow: /cart\nSitemap: {{site}}/index.xml\nsitemap:{{site}}/extra.xml.gz\nSitemap: {{site}}/missing.xml\n",
+		"/index.xml":  index("{{site}}", "/pages.xml 2024-09-10", "/old.xml 2024-01-01", "/index.xml"),
+		"/pages.xml": urlset("{{site}}", "/gear 2024-09-10T08:00:00Z", "/old-gear 2024-08-01", "/hub", "/skip/gear",
+			"/gear 2024-09-10T08:00:00Z"),
+		"/old.xml":      urlset("{{site}}", "/older"),
+		"/extra.xml.gz": urlset("{{site}}", "/motor 2024-09"),
+		"/sitemap.xml":  urlset("{{site}}", "/never"),
+	})
+	entries := site.discover(t, Options{
+		Since: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+		Allow: func(loc string) bool { return !strings.Contains(loc, "/skip/") },
+	})
+	want := []Entry{
+		// The newer page, the one without a lastmod and the gzipped one are kept
+		{site.URL + "/gear", time.Date(2024, 9, 10, 8, 0, 0, 0, time.UTC)},
+		{site.URL + "/hub", time.Time{}},
+		{site.URL + "/motor", time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %v, expected %v", entries, want)
+	}
+	// The old sitemap of the index isn't read, and /sitemap.xml isn't needed when robots.txt lists them
+	for _, path := range site.requested {
+		if path == "/old.xml" || path == "/sitemap.xml" {
+			t.Errorf("%s was fetched", path)
+		}
+	}
+}
+
+func TestDiscoverFallback(t *testing.T) {
+	for _, robots := range []string{"", "User-agent: *\nDisallow: /cart\n"} {
+		files := map[string]string{"/sitemap.xml": urlset("{{site}}", "/gear")}
+		if robots != "" {
+			files["/robots.txt"] = robots
+		}
+		site := newTestSite(t, files)
+		entries := site.discover(t, Options{})
+		if len(entries) != 1 || entries[0].Loc != site.URL+"/gear" {
+			t.Errorf("robots.txt %q: got %v", robots, entries)
+		}
+	}
+
+	site := newTestSite(t, map[string]string{"/sitemap.xml": "<html><body>Not here</body></html>"})
+	root, _ := url.Parse(site.URL)
+	if entries, err := Discover(site.Client(), root, Options{}); err == nil {
+		t.Errorf("a site without a sitemap gave %v", entries)
+	}
+}
+
+func TestDiscoverDepth(t *testing.T) {
+	// Each index has a page of its own and the next index, which goes on past maxDepth
+	files := map[string]string{}
+	for i := 0; i <= maxDepth+2; i++ {
+		name := fmt.Sprintf("/index-%d.xml", i)
+		if i == 0 {
+			name = "/sitemap.xml"
+		}
+		files[name] = index("{{site}}", fmt.Sprintf("/pages-%d.xml", i), fmt.Sprintf("/index-%d.xml", i+1))
+		files[fmt.Sprintf("/pages-%d.xml", i)] = urlset("{{site}}", fmt.Sprintf("/page-%d", i))
+	}
+	site := newTestSite(t, files)
+	var got []string
+	for _, entry := range site.discover(t, Options{}) {
+		got = append(got, strings.TrimPrefix(entry.Loc, site.URL))
+	}
+	// The index at maxDepth is read, but the sitemaps it lists are not
+	want := []string{"/page-0", "/page-1", "/page-2", "/page-3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, expected %v", got, want)
+	}
+}
+
+func TestParseLastMod(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-09-01T12:30:45.5+02:00", time.Date(2024, 9, 1, 12, 30, 45, 500000000, time.FixedZone("", 2*60*60))},
+		{"2024-09-01T12:30+02:00", time.Date(2024, 9, 1, 12, 30, 0, 0, time.FixedZone("", 2*60*60))},
+		{"2024-09-01T12:30:45", time.Date(2024, 9, 1, 12, 30, 45, 0, time.UTC)},
+		{" 2024-09-01\n", time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-09", time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"last week", time.Time{}},
+		{"", time.Time{}},
+	}
+	for _, test := range tests {
+		if got := ParseLastMod(test.value); !got.Equal(test.want) {
+			t.Errorf("ParseLastMod(%q) = %v, expected %v", test.value, got, test.want)
+		}
+	}
+}
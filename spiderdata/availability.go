@@ -0,0 +1,132 @@
+package spiderdata
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/embedjson"
+	"golang.org/x/net/html"
+)
+
+// Availability - what the vendor page says about whether a product can still be ordered.
+// The values are ordered so that max() of two of them gives the stronger statement
+type Availability int
+
+const (
+	// Available - nothing on the page says the product is going away
+	Available Availability = iota
+	// Clearance - the product is being sold off and is likely to be discontinued once the stock is gone
+	Clearance
+	// Discontinued - the product is no longer sold
+	Discontinued
+)
+
+// The text which marks a product as being discontinued or on clearance.  These are checked in lower case
+var (
+	discontinuedMarkers = []string{
+		"discontinued",
+		"no longer available",
+		"no longer sold",
+		"no longer being sold",
+		"no longer offered",
+		"end of life",
+		"[obsolete]",
+	}
+	// Clearance on its own also shows up in specifications ("Clearance Hole") so it has to be a label or a phrase
+	clearanceMarkers = []string{
+		"on clearance",
+		"clearance item",
+		"clearance sale",
+		"clearance price",
+	}
+)
+
+// The parts of a product area where text talking about discontinued parts doesn't apply to the product itself.
+// Links and descriptions tend to say things like "replaces the discontinued ..."
+const ignoreAvailabilityText = "a, script, style, noscript, option, [class*=description], [class*=related]"
+
+// maxMarkerText is the longest piece of text that we treat as a label rather than a sentence in a description
+const maxMarkerText = 80
+
+// ProductAvailability combines the schema.org availability for the page with any labels on the product itself
+func ProductAvailability(doc *goquery.Document, product *goquery.Selection, skip string) Availability {
+	return max(SchemaAvailability(doc.Selection), TextAvailability(product, skip))
+}
+
+// SchemaAvailability looks at the schema.org availability of the products on a page.  This can either be
+// microdata such as
+//
+//	<link itemprop="availability" href="https://schema.org/Discontinued">
+//
+// or JSON-LD such as
+//
+//	<script type="application/ld+json">{"@type":"Product","offers":{"availability":"https://schema.org/Discontinued"}}</script>
+//
+// A page is only considered discontinued when every availability on it says so, since a page with several
+// variants may only have some of them discontinued.  LimitedAvailability only means the stock is low, so it
+// is still available
+func SchemaAvailability(root *goquery.Selection) Availability {
+	var values []string
+	root.Find("[itemprop=availability]").Each(func(i int, elem *goquery.Selection) {
+		if value, found := elem.Attr("href"); found {
+			values = append(values, value)
+		} else if value, found := elem.Attr("content"); found {
+			values = append(values, value)
+		}
+	})
+	for _, script := range embedjson.FromScripts(root, "application/ld+json") {
+		values = append(values, script.Strings("..availability")...)
+	}
+	if len(values) == 0 {
+		return Available
+	}
+	result := Discontinued
+	for _, value := range values {
+		if !strings.HasSuffix(strings.ToLower(value), "discontinued") {
+			result = Available
+		}
+	}
+	return result
+}
+
+// TextAvailability looks for short labels such as "Discontinued" or "Clearance" within the product.
+// skip is an optional selector for areas of the product to ignore (such as a list of variants which are checked separately)
+func TextAvailability(product *goquery.Selection, skip string) Availability {
+	ignore := ignoreAvailabilityText
+	if skip != "" {
+		ignore += ", " + skip
+	}
+	result := Available
+	product.Find("*").AddSelection(product).Contents().Each(func(i int, node *goquery.Selection) {
+		if result == Discontinued || len(node.Nodes) == 0 || node.Nodes[0].Type != html.TextNode {
+			return
+		}
+		text := strings.ToLower(strings.TrimSpace(node.Text()))
+		if text == "" || len(text) > maxMarkerText {
+			return
+		}
+		if node.ParentsUntilSelection(product).Filter(ignore).Length() > 0 {
+			return
+		}
+		result = max(result, textAvailability(text))
+	})
+	return result
+}
+
+// textAvailability checks a single piece of lower case text for the markers
+func textAvailability(text string) Availability {
+	for _, marker := range discontinuedMarkers {
+		if strings.Contains(text, marker) {
+			return Discontinued
+		}
+	}
+	if text == "clearance" {
+		return Clearance
+	}
+	for _, marker := range clearanceMarkers {
+		if strings.Contains(text, marker) {
+			return Clearance
+		}
+	}
+	return Available
+}
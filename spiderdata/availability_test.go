@@ -0,0 +1,65 @@
+package spiderdata
+
+import (
+	"os"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestAvailability(t *testing.T) {
+	tests := []struct {
+		fixture string
+		schema  Availability
+		text    Availability
+		product Availability
+	}{
+		{"schema-discontinued.html", Discontinued, Available, Discontinued},
+		// Only one of the variants is discontinued, so the page isn't
+		{"schema-variants.html", Available, Available, Available},
+		// Low stock isn't a clearance
+		{"schema-limited.html", Available, Available, Available},
+		// The description, the links and the "clearance holes" don't count, only the label
+		{"label-clearance.html", Available, Clearance, Clearance},
+		// The stronger statement wins, and the related products outside of the product are ignored
+		{"label-discontinued.html", Available, Discontinued, Discontinued},
+		{"label-none.html", Available, Available, Available},
+	}
+	for _, test := range tests {
+		f, err := os.Open("testdata/" + test.fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := goquery.NewDocumentFromReader(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		product := doc.Find(".product").First()
+		if got := SchemaAvailability(doc.Selection); got != test.schema {
+			t.Errorf("%s: SchemaAvailability = %v, expected %v", test.fixture, got, test.schema)
+		}
+		if got := TextAvailability(product, ""); got != test.text {
+			t.Errorf("%s: TextAvailability = %v, expected %v", test.fixture, got, test.text)
+		}
+		if got := ProductAvailability(doc, product, ""); got != test.product {
+			t.Errorf("%s: ProductAvailability = %v, expected %v", test.fixture, got, test.product)
+		}
+	}
+}
+
+func TestTextAvailabilitySkip(t *testing.T) {
+	f, err := os.Open("testdata/label-discontinued.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Skipping the notice leaves the clearance label
+	if got := TextAvailability(doc.Find(".product"), ".notice"); got != Clearance {
+		t.Errorf("TextAvailability skipping the notice = %v, expected %v", got, Clearance)
+	}
+}
@@ -0,0 +1,284 @@
+package spiderdata
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// CrawlState remembers what each page produced on the previous run so that an incremental run can
+// ask the vendor whether the page has changed and reuse the results when it hasn't.
+// It has its own lock because the HTTP transport asks it for the validators outside of Globals.Mu
+type CrawlState struct {
+	mu       sync.Mutex
+	previous map[string]*PageState
+	current  map[string]*PageState
+	// sitemap holds the lastmod of the pages from the sitemaps for this run
+	sitemap map[string]time.Time
+}
+
+// PageState is what we keep for a single page between runs.  An address whose page names a different
+// canonical address only has Canonical set, and everything else is kept under the canonical address
+type PageState struct {
+	Canonical      string         `json:"canonical,omitempty"`
+	ETag           string         `json:"etag,omitempty"`
+	LastModified   string         `json:"lastModified,omitempty"`
+	SitemapLastMod time.Time      `json:"sitemapLastMod"`
+	Fetched        time.Time      `json:"fetched"`
+	Products       []SavedProduct `json:"products,omitempty"`
+	Links          []SavedLink    `json:"links,omitempty"`
+	Related        []RelatedEdge  `json:"related,omitempty"`
+	Kits           []*Kit         `json:"kits,omitempty"`
+}
+
+// SavedProduct is a product as the parser found it, before it was checked against the catalog
+type SavedProduct struct {
+	Part         partcatalog.PartData `json:"part"`
+	Availability Availability         `json:"availability"`
+}
+
+// SavedLink is a page which was enqueued while processing a page
+type SavedLink struct {
+	URL        string `json:"url"`
+	Breadcrumb string `json:"breadcrumb,omitempty"`
+}
+
+// LoadCrawlState reads the state saved by the previous run.  A missing file gives an empty state
+// so that the first incremental run simply fetches everything
+func LoadCrawlState(path string) (*CrawlState, error) {
+	state := &CrawlState{
+		previous: make(map[string]*PageState),
+		current:  make(map[string]*PageState),
+		sitemap:  make(map[string]time.Time),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state.previous); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Save writes the pages processed on this run.  Pages which weren't reached this time are dropped
+// unless KeepUnreached was called
+func (s *CrawlState) Save(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(s.current); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// KeepUnreached carries the pages saved by the previous run which weren't reached on this one over to
+// this run's state.  A crawl which stops early uses this so that the pages it never got to can still be
+// replayed next time.  It returns how many pages were carried over
+func (s *CrawlState) KeepUnreached() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := 0
+	for url, page := range s.previous {
+		if _, reached := s.current[url]; !reached {
+			s.current[url] = page
+			kept++
+		}
+	}
+	return kept
+}
+
+// Pages returns how many pages will be saved
+func (s *CrawlState) Pages() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.current)
+}
+
+// Validators returns the ETag and Last-Modified values to send in a conditional request for a page
+func (s *CrawlState) Validators(url string) (etag string, lastModified string) {
+	if s == nil {
+		return "", ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, page := resolvePage(s.previous, url); page != nil {
+		return page.ETag, page.LastModified
+	}
+	return "", ""
+}
+
+// resolvePage finds what is kept for an address, following it to its canonical address when it has one
+func resolvePage(pages map[string]*PageState, url string) (string, *PageState) {
+	page := pages[url]
+	if page != nil && page.Canonical != "" {
+		url = page.Canonical
+		page = pages[url]
+	}
+	return url, page
+}
+
+// SitemapUnchanged notes the lastmod a sitemap gives for a page and reports whether the page is
+// the same as last run, in which case it doesn't need to be fetched at all
+func (s *CrawlState) SitemapUnchanged(url string, lastmod time.Time) bool {
+	if s == nil || lastmod.IsZero() {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sitemap[url] = lastmod
+	_, page := resolvePage(s.previous, url)
+	return page != nil && !page.SitemapLastMod.IsZero() && !lastmod.After(page.SitemapLastMod)
+}
+
+// BeginPage starts recording what a freshly fetched page produces.  url is the address which was
+// fetched and key is the one the page is processed under, which is its canonical address when the
+// page names a different one
+func (s *CrawlState) BeginPage(url string, key string, header http.Header) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key != url {
+		s.current[url] = &PageState{Canonical: key}
+	}
+	s.current[key] = &PageState{
+		ETag:           header.Get("ETag"),
+		LastModified:   header.Get("Last-Modified"),
+		SitemapLastMod: s.sitemap[url],
+		Fetched:        time.Now().UTC(),
+	}
+}
+
+// page returns the record for a page which is being processed, or nil when we aren't recording it
+func (s *CrawlState) page(url string) *PageState {
+	if s == nil || url == "" {
+		return nil
+	}
+	return s.current[url]
+}
+
+// recordProduct keeps a copy of a product found on the page being processed
+func (s *CrawlState) recordProduct(url string, partData *partcatalog.PartData, availability Availability) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if page := s.page(url); page != nil {
+		page.Products = append(page.Products, SavedProduct{Part: *partData, Availability: availability})
+	}
+}
+
+// recordLink keeps a link which the page being processed enqueued
+func (s *CrawlState) recordLink(url string, link string, breadcrumb string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if page := s.page(url); page != nil {
+		page.Links = append(page.Links, SavedLink{URL: link, Breadcrumb: breadcrumb})
+	}
+}
+
+// recordRelated keeps a related product link found on the page being processed
+func (s *CrawlState) recordRelated(url string, edge RelatedEdge) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if page := s.page(url); page != nil {
+		page.Related = append(page.Related, edge)
+	}
+}
+
+// recordKit keeps a kit found on the page being processed
+func (s *CrawlState) recordKit(url string, kit *Kit) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if page := s.page(url); page != nil {
+		page.Kits = append(page.Kits, kit)
+	}
+}
+
+// ReplayPage produces everything the previous run got from a page without processing it again.
+// The products are still checked against this run's catalog, and the links are enqueued so that the
+// rest of the site is reached.  It returns false when there is nothing saved for the page
+func ReplayPage(ctx *Context, url string) bool {
+	state := ctx.G.Crawl
+	if state == nil {
+		return false
+	}
+	state.mu.Lock()
+	key, previous := resolvePage(state.previous, url)
+	found := previous != nil
+	if found {
+		if key != url {
+			state.current[url] = &PageState{Canonical: key}
+		}
+		// Carry the validators over and let the normal output routines record the results again
+		sitemapLastMod := previous.SitemapLastMod
+		if lastmod, ok := state.sitemap[url]; ok {
+			sitemapLastMod = lastmod
+		}
+		state.current[key] = &PageState{
+			ETag:           previous.ETag,
+			LastModified:   previous.LastModified,
+			SitemapLastMod: sitemapLastMod,
+			Fetched:        previous.Fetched,
+		}
+	}
+	state.mu.Unlock()
+	if !found {
+		return false
+	}
+
+	replay := *ctx
+	replay.Url = key
+	ctx.G.Mu.Lock()
+	defer ctx.G.Mu.Unlock()
+	MarkVisitedURL(&replay, key, "")
+	for _, saved := range previous.Products {
+		partData := saved.Part
+		ctx.G.LastCategory = partData.Section
+		OutputProductData(&replay, &partData, saved.Availability)
+	}
+	for _, edge := range previous.Related {
+		RecordRelated(&replay, edge.RelatedURL, edge.Relation, edge.Name)
+	}
+	for _, kit := range previous.Kits {
+		ctx.G.LastCategory = kit.Section
+		RecordKit(&replay, kit.SKU, kit.Name, kit.URL, kit.Components)
+	}
+	if !ctx.G.SingleOnly {
+		for _, link := range previous.Links {
+			EnqueURL(&replay, link.URL, link.Breadcrumb)
+		}
+	}
+	if ctx.G.Stats != nil {
+		ctx.G.Stats.PageReused(url)
+	}
+	return true
+}
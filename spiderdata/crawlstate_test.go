@@ -0,0 +1,203 @@
+package spiderdata
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/canonurl"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// crawlContext gives a context for www.example.com which records into state and writes to a temporary file
+func crawlContext(t *testing.T, state *CrawlState) *Context {
+	t.Helper()
+	outfile, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { outfile.Close() })
+	return &Context{G: &Globals{
+		Canon:         &canonurl.Canonicalizer{Host: "www.example.com"},
+		TargetConfig:  &SpiderTarget{CheckMatchFunc: NilCheckMatch},
+		BreadcrumbMap: make(map[string]string),
+		Crawl:         state,
+		Outfile:       outfile,
+	}}
+}
+
+// reloadState saves the state and reads it back as the next run would
+func reloadState(t *testing.T, state *CrawlState) *CrawlState {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := state.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadCrawlState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reloaded
+}
+
+func TestCrawlStateCanonicalPage(t *testing.T) {
+	state, err := LoadCrawlState(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := crawlContext(t, state)
+	fetched := "https://www.example.com/Gear-Alias"
+	ctx.G.BreadcrumbMap[fetched] = "Home > Gears"
+
+	// The page is asked for by a different form of its address than the one it was enqueued under
+	ctx.Url = "http://example.com/Gear-Alias/"
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head><link rel="canonical" href="https://www.example.com/gear"></head></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, duplicate := CanonicalPage(ctx, doc)
+	if canonical != "https://www.example.com/gear" || duplicate {
+		t.Fatalf("CanonicalPage = %q, %v", canonical, duplicate)
+	}
+	if breadcrumb := ctx.G.BreadcrumbMap[canonical]; breadcrumb != "Home > Gears" {
+		t.Errorf("canonical page has breadcrumb %q, expected the one of the page", breadcrumb)
+	}
+
+	// What the page produces is recorded under its canonical address
+	ctx.Url = canonical
+	state.BeginPage(fetched, canonical, http.Header{"Etag": {`"v1"`}})
+	ctx.G.LastCategory = "Home > Gears"
+	OutputProduct(ctx, "Spur Gear", "1-1", fetched, "", Available, nil)
+
+	next := reloadState(t, state)
+	if etag, _ := next.Validators(fetched); etag != `"v1"` {
+		t.Errorf("Validators(%s) = %q, expected the ETag of the page", fetched, etag)
+	}
+	ctx = crawlContext(t, next)
+	if !ReplayPage(ctx, fetched) {
+		t.Fatal("nothing replayed for a page with a canonical address")
+	}
+	if len(ctx.G.Emitted) != 1 || ctx.G.Emitted[0].SKU != "1-1" || ctx.G.Emitted[0].Section != "Home > Gears" {
+		t.Errorf("replay emitted %+v", ctx.G.Emitted)
+	}
+	if _, visited := ctx.G.BreadcrumbMap[canonical]; !visited {
+		t.Error("the canonical page wasn't marked as visited by the replay")
+	}
+	// The replay is kept for the run after as well
+	if etag, _ := reloadState(t, next).Validators(fetched); etag != `"v1"` {
+		t.Errorf("the replayed page lost its ETag, got %q", etag)
+	}
+}
+
+func TestCrawlStateReplay(t *testing.T) {
+	state, err := LoadCrawlState(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := "https://www.example.com/gears"
+	link := "https://www.example.com/gears/spur"
+	lastmod := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	ctx := crawlContext(t, state)
+	ctx.Url = page
+	// Nothing was saved by a previous run, so the page has to be fetched
+	if state.SitemapUnchanged(page, lastmod) {
+		t.Error("a page which was never fetched is unchanged")
+	}
+	state.BeginPage(page, page, http.Header{"Etag": {`"v1"`}, "Last-Modified": {"Sun, 01 Sep 2024 00:00:00 GMT"}})
+	ctx.G.LastCategory = "Home > Gears"
+	OutputProduct(ctx, "Spur Gear", "1-1", link, "", Available, nil)
+	OutputProduct(ctx, "Old Gear", "1-2", page, "", Discontinued, nil)
+	// The link has already been seen, so it isn't sent to the queue
+	ctx.G.BreadcrumbMap[link] = ""
+	EnqueURL(ctx, link, "Home > Gears > Spur")
+
+	next := reloadState(t, state)
+	if etag, lastModified := next.Validators(page); etag != `"v1"` || lastModified != "Sun, 01 Sep 2024 00:00:00 GMT" {
+		t.Errorf("Validators = %q, %q", etag, lastModified)
+	}
+	if etag, lastModified := next.Validators(link); etag != "" || lastModified != "" {
+		t.Errorf("Validators for a page which wasn't fetched = %q, %q", etag, lastModified)
+	}
+	for _, check := range []struct {
+		lastmod time.Time
+		want    bool
+	}{
+		{lastmod, true},
+		{lastmod.Add(-time.Hour), true},
+		{lastmod.Add(time.Hour), false},
+		{time.Time{}, false},
+	} {
+		if got := next.SitemapUnchanged(page, check.lastmod); got != check.want {
+			t.Errorf("SitemapUnchanged(%v) = %v, expected %v", check.lastmod, got, check.want)
+		}
+	}
+
+	// The page comes back 304 Not Modified (or the sitemap says it hasn't changed)
+	ctx = crawlContext(t, next)
+	ctx.G.BreadcrumbMap[link] = ""
+	if ReplayPage(ctx, link) {
+		t.Error("replayed a page with nothing saved")
+	}
+	if !ReplayPage(ctx, page) {
+		t.Fatal("nothing replayed for the page")
+	}
+	var got []string
+	for _, part := range ctx.G.Emitted {
+		got = append(got, part.SKU+" "+part.SpiderStatus.String()+" "+part.Section)
+	}
+	want := []string{"1-1 " + partcatalog.NewPart.String() + " Home > Gears", "1-2 " + partcatalog.DiscontinuedPart.String() + " Home > Gears"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replay emitted %q, expected %q", got, want)
+	}
+	if breadcrumb := ctx.G.BreadcrumbMap[link]; breadcrumb != "Home > Gears > Spur" {
+		t.Errorf("the link was replayed with breadcrumb %q", breadcrumb)
+	}
+	if _, visited := ctx.G.BreadcrumbMap[page]; !visited {
+		t.Error("the replayed page wasn't marked as visited")
+	}
+
+	// What was replayed is saved again for the run after
+	if next.Pages() != 1 {
+		t.Errorf("%d pages to be saved after the replay, expected 1", next.Pages())
+	}
+	saved := reloadState(t, next).previous[page]
+	if saved == nil || len(saved.Products) != 2 || len(saved.Links) != 1 || !saved.SitemapLastMod.Equal(lastmod.Add(time.Hour)) {
+		t.Errorf("saved after the replay %+v", saved)
+	}
+}
+
+func TestCrawlStateKeepUnreached(t *testing.T) {
+	state, err := LoadCrawlState(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gears := "https://www.example.com/gears"
+	motors := "https://www.example.com/motors"
+	alias := "https://www.example.com/Motors/"
+	state.BeginPage(gears, gears, http.Header{"Etag": {`"g1"`}})
+	state.BeginPage(alias, motors, http.Header{"Etag": {`"m1"`}})
+	previous := reloadState(t, state)
+
+	// This run is stopped after the gears have changed, before it gets to the motors
+	previous.BeginPage(gears, gears, http.Header{"Etag": {`"g2"`}})
+	if kept := previous.KeepUnreached(); kept != 2 {
+		t.Errorf("KeepUnreached = %d, expected the motors page and its alias", kept)
+	}
+	next := reloadState(t, previous)
+	for url, want := range map[string]string{gears: `"g2"`, motors: `"m1"`, alias: `"m1"`} {
+		if etag, _ := next.Validators(url); etag != want {
+			t.Errorf("Validators(%s) = %q, expected %q", url, etag, want)
+		}
+	}
+
+	// A run which isn't stopped drops the pages it didn't reach
+	next.BeginPage(gears, gears, http.Header{"Etag": {`"g3"`}})
+	if etag, _ := reloadState(t, next).Validators(motors); etag != "" {
+		t.Errorf("an unreached page was kept with ETag %q", etag)
+	}
+}
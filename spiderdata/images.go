@@ -0,0 +1,76 @@
+package spiderdata
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// imageSelectors are where the vendors put the product pictures, most important first.
+// The schema.org image is normally the primary picture, followed by the galleries
+const imageSelectors = "[itemprop=image], " +
+	"[class*=productView-image] img, [class*=productView-thumbnail] a, [class*=productView-thumbnail] img, " +
+	"[class*=product-image] img, [class*=picture] img, [class*=gallery] img, [class*=gallery] a[data-image]"
+
+// imageAttributes are checked in order since the full size picture is often in a data attribute
+// while src only has a placeholder for lazy loading
+var imageAttributes = []string{"data-zoom-image", "data-image-gallery-zoom-image-url", "data-image", "data-large", "data-src", "content", "href", "src"}
+
+// fileExtension matches an extension naming a kind of file, as opposed to a dot in a product slug (1.50-bore)
+var fileExtension = regexp.MustCompile(`^\.[a-z][a-z0-9]{0,4}$`)
+
+// imageExtensions are the kinds of picture the thumbnails can be made from
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+// ExtractImages finds the URL of the primary product image followed by any gallery images.
+// The Open Graph image for the page is used as a last resort for the primary image
+func ExtractImages(ctx *Context, product *goquery.Selection) []string {
+	var result []string
+	seen := make(map[string]bool)
+	add := func(link string, picture bool) {
+		link = strings.TrimSpace(link)
+		if link == "" || strings.HasPrefix(link, "data:") || strings.HasPrefix(link, "#") {
+			return
+		}
+		link = resolveLink(ctx, link)
+		if !seen[link] && isImageURL(link, picture) {
+			seen[link] = true
+			result = append(result, link)
+		}
+	}
+	product.Find(imageSelectors).Each(func(i int, elem *goquery.Selection) {
+		// Only an <img> or a schema.org image is known to be a picture, a link could go to a page
+		picture := goquery.NodeName(elem) == "img" || elem.Is("[itemprop=image]")
+		for _, attr := range imageAttributes {
+			if link, found := elem.Attr(attr); found && link != "" {
+				add(link, picture)
+				return
+			}
+		}
+	})
+	if len(result) == 0 {
+		if link, found := product.Closest("html").Find("meta[property='og:image']").Attr("content"); found {
+			add(link, true)
+		}
+	}
+	return result
+}
+
+// isImageURL weeds out links to pages, pictures the thumbnails can't read and the placeholder images
+// used while loading.  Image servers often don't use an extension, so a link without a known one is only
+// taken when picture says that it came from an image
+func isImageURL(link string, picture bool) bool {
+	lower := strings.ToLower(link)
+	if strings.Contains(lower, "loading") || strings.Contains(lower, "placeholder") || strings.Contains(lower, "spacer") {
+		return false
+	}
+	p, _, _ := strings.Cut(lower, "?")
+	ext := path.Ext(p)
+	if slices.Contains(imageExtensions, ext) {
+		return true
+	}
+	return picture && (ext == "" || !fileExtension.MatchString(ext))
+}
@@ -0,0 +1,43 @@
+package spiderdata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractImages(t *testing.T) {
+	page := `<html><head><meta property="og:image" content="https://cdn.example.com/og.jpg"></head><body>
+<div class="product">
+  <img itemprop="image" src="https://cdn.example.com/images/stencil/1280x1280/products/123/456/gear__12345">
+  <div class="productView-thumbnail">
+    <a href="https://www.example.com/products/1.50-bore-hub/"><img data-src="https://cdn.example.com/gear-2.JPG?c=2" src="/img/loading.gif"></a>
+    <a href="https://cdn.example.com/gear-3.png"></a>
+    <a href="https://www.example.com/products/gear-kit"></a>
+    <img src="https://cdn.example.com/gear-4.webp">
+    <img src="https://cdn.example.com/gear-5.jpg">
+    <img src="https://cdn.example.com/gear-5.jpg">
+  </div>
+</div></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://cdn.example.com/images/stencil/1280x1280/products/123/456/gear__12345",
+		"https://cdn.example.com/gear-2.JPG?c=2",
+		"https://cdn.example.com/gear-3.png",
+		"https://cdn.example.com/gear-5.jpg",
+	}
+	if got := ExtractImages(&Context{}, doc.Find(".product")); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractImages = %q, expected %q", got, want)
+	}
+
+	// Without any product pictures the Open Graph image is used
+	doc.Find(".product").Empty()
+	if got := ExtractImages(&Context{}, doc.Find(".product")); !reflect.DeepEqual(got, []string{"https://cdn.example.com/og.jpg"}) {
+		t.Errorf("ExtractImages without pictures = %q", got)
+	}
+}
@@ -0,0 +1,227 @@
+package spiderdata
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// KitComponent is one line of the contents of a kit
+type KitComponent struct {
+	Quantity int
+	SKU      string
+	Name     string
+	URL      string
+}
+
+// Kit is a product which is made up of other products
+type Kit struct {
+	SKU        string
+	Name       string
+	URL        string
+	Section    string
+	Components []KitComponent
+}
+
+var (
+	// kitHeading matches the titles that vendors put over the list of what comes in a kit
+	kitHeading = regexp.MustCompile(`(?i)^\s*(kit\s+(includes|contents|contains)|(what('|’)?s|included)\s+in\s+the\s+(box|kit)|includes|contents|package\s+(includes|contents))\s*:?\s*$`)
+	// kitSKU matches the part numbers of the vendors we spider: REV-41-1300, 1120-0001-0001, 585000, am-1234
+	kitSKU = regexp.MustCompile(`\b([A-Za-z]{2,4}-\d[\w-]*|\d{4}-\d{4}-\d{4}|\d{3,4}-\d{3,4}(-\d+)*|\d{6})\b`)
+	// The quantity is either at the start "2 x Hub", "(2) Hub", "2x Hub" or at the end "Hub x2", "Hub (x2)".
+	// A number at the start without the parentheses or an x is a part number (585000 - 12mm Hub), not a quantity
+	kitLeadingQty  = regexp.MustCompile(`^\s*(?:\((\d+)\)|(\d+)\s*(?:x|X|×|pcs?\.?))\s+`)
+	kitTrailingQty = regexp.MustCompile(`\s*\(?\s*(x|X|×|qty:?)\s*(\d+)\s*\)?\s*$`)
+)
+
+// RecordKit saves the contents of a kit found on a product page to be written out at the end of the run
+func RecordKit(ctx *Context, sku string, name string, url string, components []KitComponent) {
+	if len(components) == 0 {
+		return
+	}
+	kit := &Kit{
+		SKU:        sku,
+		Name:       strings.TrimSpace(name),
+		URL:        url,
+		Section:    ctx.G.LastCategory,
+		Components: components,
+	}
+	ctx.G.Kits = append(ctx.G.Kits, kit)
+	ctx.G.Crawl.recordKit(ctx.Url, kit)
+}
+
+// ExtractKitComponents looks for a "Kit Includes" (or similar) heading in the product and
+// parses the table or list which follows it
+func ExtractKitComponents(ctx *Context, product *goquery.Selection) []KitComponent {
+	var result []KitComponent
+	seen := make(map[string]bool)
+	product.Find("h1, h2, h3, h4, h5, h6, strong, b, p, span, div").Each(func(i int, heading *goquery.Selection) {
+		// Only look at the element which actually holds the heading text, not everything that contains it
+		if heading.Children().Length() > 1 || !kitHeading.MatchString(heading.Text()) {
+			return
+		}
+		list := heading.NextAllFiltered("table, ul, ol").First()
+		if list.Length() == 0 {
+			list = heading.Parent().NextAllFiltered("table, ul, ol").First()
+		}
+		if list.Length() == 0 {
+			return
+		}
+		var components []KitComponent
+		if goquery.NodeName(list) == "table" {
+			components = kitTableComponents(ctx, list)
+		} else {
+			list.Find("li").Each(func(i int, item *goquery.Selection) {
+				if component, ok := kitLineComponent(ctx, item.Text(), item.Find("a[href]").First()); ok {
+					components = append(components, component)
+				}
+			})
+		}
+		for _, component := range components {
+			key := component.SKU + "`" + component.Name
+			if !seen[key] {
+				seen[key] = true
+				result = append(result, component)
+			}
+		}
+	})
+	return result
+}
+
+// kitTableComponents parses a table of kit contents.  When the table has a header we use it to find
+// the quantity, part number and name columns, otherwise each row is treated as a line of text
+func kitTableComponents(ctx *Context, table *goquery.Selection) []KitComponent {
+	qtyCol, skuCol, nameCol := -1, -1, -1
+	table.Find("tr").First().Find("th, td").Each(func(i int, cell *goquery.Selection) {
+		header := strings.ToLower(strings.TrimSpace(cell.Text()))
+		switch {
+		case strings.Contains(header, "qty") || strings.Contains(header, "quantity"):
+			qtyCol = i
+		case strings.Contains(header, "sku") || strings.Contains(header, "part"):
+			skuCol = i
+		case strings.Contains(header, "name") || strings.Contains(header, "description") || strings.Contains(header, "item"):
+			nameCol = i
+		}
+	})
+	hasHeader := skuCol >= 0 || (qtyCol >= 0 && nameCol >= 0)
+
+	var result []KitComponent
+	table.Find("tr").Each(func(row int, tr *goquery.Selection) {
+		if hasHeader && row == 0 {
+			return
+		}
+		cells := tr.Find("td, th")
+		link := tr.Find("a[href]").First()
+		if !hasHeader {
+			var texts []string
+			cells.Each(func(i int, cell *goquery.Selection) {
+				texts = append(texts, strings.TrimSpace(cell.Text()))
+			})
+			if component, ok := kitLineComponent(ctx, strings.Join(texts, " "), link); ok {
+				result = append(result, component)
+			}
+			return
+		}
+		cellText := func(col int) string {
+			if col < 0 || col >= cells.Length() {
+				return ""
+			}
+			return strings.TrimSpace(cells.Eq(col).Text())
+		}
+		component := KitComponent{Quantity: 1, SKU: cellText(skuCol), Name: cellText(nameCol)}
+		if qty, err := strconv.Atoi(strings.Trim(cellText(qtyCol), "()xX× ")); err == nil {
+			component.Quantity = qty
+		}
+		if href, found := link.Attr("href"); found {
+			component.URL = resolveLink(ctx, href)
+		}
+		if component.SKU != "" || component.Name != "" {
+			result = append(result, component)
+		}
+	})
+	return result
+}
+
+// kitLineComponent parses a single line of kit contents such as "2 x Core Hex Motor (REV-41-1300)"
+func kitLineComponent(ctx *Context, text string, link *goquery.Selection) (KitComponent, bool) {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return KitComponent{}, false
+	}
+	component := KitComponent{Quantity: 1}
+	if match := kitLeadingQty.FindStringSubmatch(text); match != nil {
+		component.Quantity, _ = strconv.Atoi(match[1] + match[2])
+		text = text[len(match[0]):]
+	} else if match := kitTrailingQty.FindStringSubmatch(text); match != nil {
+		component.Quantity, _ = strconv.Atoi(match[2])
+		text = text[:len(text)-len(match[0])]
+	}
+	if loc := kitSKU.FindStringIndex(text); loc != nil {
+		component.SKU = text[loc[0]:loc[1]]
+		text = text[:loc[0]] + text[loc[1]:]
+	}
+	// Clean up what is left around where the SKU was to get the name
+	component.Name = strings.Trim(strings.ReplaceAll(text, "()", ""), " -–:,")
+	if href, found := link.Attr("href"); found {
+		component.URL = resolveLink(ctx, href)
+	}
+	return component, component.Name != "" || component.SKU != ""
+}
+
+// WriteKitContents writes every component of every kit found, one per line, checking each of the
+// component SKUs against the catalog and the parts found by the spider.  It returns how many
+// of the components are missing from the catalog
+func WriteKitContents(path string, kits []*Kit, catalog *partcatalog.PartCatalogData, emitted []*partcatalog.PartData) (missing int, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	spidered := make(map[string]bool)
+	for _, partData := range emitted {
+		if partData.SpiderStatus != partcatalog.PartNotFoundBySpider {
+			spidered[strings.ToUpper(partData.SKU)] = true
+		}
+	}
+	inCatalog := make(map[string]*partcatalog.PartData)
+	if catalog != nil {
+		for sku, partData := range catalog.PartNumber {
+			inCatalog[strings.ToUpper(sku)] = partData
+		}
+	}
+
+	fmt.Fprintf(f, "%v`%v`%v`%v`%v`%v`%v`%v`%v`%v\n",
+		"Kit Part #", "Kit Name", "Kit URL", "Section", "Qty", "Part #", "Name", "URL", "Catalog Name", "Status")
+	for _, kit := range kits {
+		for _, component := range kit.Components {
+			status := "OK"
+			catalogName := ""
+			sku := strings.ToUpper(component.SKU)
+			entry, found := inCatalog[sku]
+			switch {
+			case component.SKU == "":
+				status = "No Part Number"
+				missing++
+			case found:
+				catalogName = entry.Name
+				if !spidered[sku] {
+					status = "Not Found by Spider"
+				}
+			default:
+				status = "Missing from Catalog"
+				missing++
+			}
+			fmt.Fprintf(f, "%v`%v`%v`%v`%v`%v`%v`%v`%v`%v\n",
+				kit.SKU, kit.Name, kit.URL, kit.Section,
+				component.Quantity, component.SKU, component.Name, component.URL,
+				catalogName, status)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,101 @@
+package spiderdata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func kitDocument(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestKitLineComponent(t *testing.T) {
+	tests := []struct {
+		text string
+		want KitComponent
+		ok   bool
+	}{
+		{"2 x Core Hex Motor (REV-41-1300)", KitComponent{Quantity: 2, SKU: "REV-41-1300", Name: "Core Hex Motor"}, true},
+		{"(4) 1120-0001-0001 Screw", KitComponent{Quantity: 4, SKU: "1120-0001-0001", Name: "Screw"}, true},
+		{"3x Hub", KitComponent{Quantity: 3, Name: "Hub"}, true},
+		{"2 pcs. Spacer", KitComponent{Quantity: 2, Name: "Spacer"}, true},
+		{"Shaft Collar x2", KitComponent{Quantity: 2, Name: "Shaft Collar"}, true},
+		{"Servo Horn (x4)", KitComponent{Quantity: 4, Name: "Servo Horn"}, true},
+		// A bare number at the start is a part number or part of the name, not a quantity
+		{"585000 - 12mm Hub", KitComponent{Quantity: 1, SKU: "585000", Name: "12mm Hub"}, true},
+		{"12 Tooth Gear", KitComponent{Quantity: 1, Name: "12 Tooth Gear"}, true},
+		{"  ", KitComponent{}, false},
+	}
+	for _, test := range tests {
+		got, ok := kitLineComponent(&Context{}, test.text, &goquery.Selection{})
+		if got != test.want || ok != test.ok {
+			t.Errorf("kitLineComponent(%q) = %+v, %v, expected %+v, %v", test.text, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestKitTableComponents(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  []KitComponent
+	}{
+		{"header", `<table>
+<tr><th>Qty</th><th>Part #</th><th>Description</th></tr>
+<tr><td>2</td><td><a href="https://www.example.com/hub">585000</a></td><td>12mm Hub</td></tr>
+<tr><td>(1x)</td><td>REV-41-1300</td><td>Core Hex Motor</td></tr>
+<tr><td></td><td></td><td></td></tr>
+</table>`, []KitComponent{
+			{Quantity: 2, SKU: "585000", Name: "12mm Hub", URL: "https://www.example.com/hub"},
+			{Quantity: 1, SKU: "REV-41-1300", Name: "Core Hex Motor"},
+		}},
+		// Without a header each row is read as a line of text
+		{"no header", `<table>
+<tr><td>2 x</td><td>Hub</td></tr>
+<tr><td>585000</td><td>Servo</td></tr>
+</table>`, []KitComponent{
+			{Quantity: 2, Name: "Hub"},
+			{Quantity: 1, SKU: "585000", Name: "Servo"},
+		}},
+	}
+	for _, test := range tests {
+		doc := kitDocument(t, test.table)
+		if got := kitTableComponents(&Context{}, doc.Find("table")); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, expected %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExtractKitComponents(t *testing.T) {
+	tests := []struct {
+		name    string
+		product string
+		want    []KitComponent
+	}{
+		{"list", `<div class="product"><h3>Kit Includes:</h3>
+<ul><li>2 x <a href="https://www.example.com/hub">Hub (585000)</a></li><li>Hub (585000)</li><li>Servo Horn x4</li></ul></div>`,
+			[]KitComponent{
+				{Quantity: 2, SKU: "585000", Name: "Hub", URL: "https://www.example.com/hub"},
+				{Quantity: 4, Name: "Servo Horn"},
+			}},
+		// The heading can be inside a paragraph with the table after the paragraph
+		{"table", `<div class="product"><p><strong>What's in the box</strong></p>
+<table><tr><td>(2) REV-41-1300 Core Hex Motor</td></tr></table></div>`,
+			[]KitComponent{{Quantity: 2, SKU: "REV-41-1300", Name: "Core Hex Motor"}}},
+		{"not a kit", `<div class="product"><h3>Features</h3><ul><li>2 x Faster</li></ul></div>`, nil},
+	}
+	for _, test := range tests {
+		doc := kitDocument(t, test.product)
+		if got := ExtractKitComponents(&Context{}, doc.Find(".product")); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, expected %+v", test.name, got, test.want)
+		}
+	}
+}
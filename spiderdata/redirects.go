@@ -0,0 +1,319 @@
+package spiderdata
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// RedirectHop is one step of a redirect chain: the address that was asked for and the status which
+// sent us somewhere else
+type RedirectHop struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// Redirect is the full chain followed from one address to the page which finally came back
+type Redirect struct {
+	From string        `json:"from"`
+	To   string        `json:"to"`
+	Hops []RedirectHop `json:"hops"`
+}
+
+// MovedURL is a catalog entry whose URL now redirects somewhere else
+type MovedURL struct {
+	SKU     string
+	Name    string
+	Section string
+	OldURL  string
+	NewURL  string
+	Hops    []RedirectHop
+	// Status says what became of it: Moved when the new page has the same part number (the catalog
+	// entry was reconciled with it), Different Page when the part was found on another page and
+	// Not Found when the spider never found the part at all
+	Status string
+}
+
+const (
+	// MovedReconciled - the catalog URL redirects to the page the part was found on
+	MovedReconciled = "Moved"
+	// MovedDifferentPage - the catalog URL redirects, but not to the page the part was found on
+	MovedDifferentPage = "Different Page"
+	// MovedNotFound - the catalog URL redirects and the part wasn't found anywhere
+	MovedNotFound = "Not Found"
+)
+
+// RedirectTracker remembers the redirect chain of every fetch so that catalog entries whose URL has
+// moved can be recognized.  It has its own lock because the fetches are recorded outside of Globals.Mu.
+// Nothing is fetched to find out where a catalog URL goes: the catalog URLs are crawled like any other
+// page, so their chains turn up as the crawl goes on
+type RedirectTracker struct {
+	mu sync.Mutex
+	// chains is keyed by the page key of every address in a chain, so an intermediate hop finds the end too
+	chains map[string]*Redirect
+	// fetched holds the page key of every address which has been fetched, redirected or not
+	fetched map[string]bool
+	// pending are the parts waiting for their catalog URL to be fetched before they are output
+	pending []pendingMove
+	moved   map[string]*MovedURL
+}
+
+// pendingMove is a part found under a different URL than the catalog entry's, when the catalog URL
+// hadn't been fetched yet
+type pendingMove struct {
+	part  *partcatalog.PartData
+	entry *partcatalog.PartData
+}
+
+// NewRedirectTracker creates an empty tracker
+func NewRedirectTracker() *RedirectTracker {
+	return &RedirectTracker{
+		chains:  make(map[string]*Redirect),
+		fetched: make(map[string]bool),
+		moved:   make(map[string]*MovedURL),
+	}
+}
+
+// RedirectChain gets the redirects which were followed to produce a response, in the order they
+// happened.  It is empty when the response came straight from the address asked for
+func RedirectChain(res *http.Response) []RedirectHop {
+	var hops []RedirectHop
+	if res == nil || res.Request == nil {
+		return hops
+	}
+	for req := res.Request; req.Response != nil && req.Response.Request != nil; req = req.Response.Request {
+		hop := RedirectHop{URL: req.Response.Request.URL.String(), Status: req.Response.StatusCode}
+		hops = append([]RedirectHop{hop}, hops...)
+	}
+	return hops
+}
+
+// RecordRedirects notes that an address was fetched and keeps the redirect chain of the fetch when
+// there was one
+func RecordRedirects(ctx *Context, res *http.Response) {
+	tracker := ctx.G.Redirects
+	if tracker == nil || res == nil || res.Request == nil {
+		return
+	}
+	hops := RedirectChain(res)
+	final, _ := CleanURL(ctx, res.Request.URL.String())
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.fetched[final] = true
+	if len(hops) == 0 {
+		return
+	}
+	redirect := &Redirect{From: hops[0].URL, To: res.Request.URL.String(), Hops: hops}
+	slog.Debug("redirected", "phase", "crawl", "from", redirect.From, "to", redirect.To, "chain", FormatChain(redirect))
+	for _, hop := range hops {
+		key, _ := CleanURL(ctx, hop.URL)
+		tracker.chains[key] = redirect
+		tracker.fetched[key] = true
+	}
+}
+
+// FormatChain describes a redirect chain for the reports, e.g. 301 https://a/ -> https://b/
+func FormatChain(redirect *Redirect) string {
+	if redirect == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, hop := range redirect.Hops {
+		fmt.Fprintf(&sb, "%d %s -> ", hop.Status, hop.URL)
+	}
+	sb.WriteString(redirect.To)
+	return sb.String()
+}
+
+// ResolveRedirect finds where an address ends up from the chains recorded while crawling.  It returns
+// nil when the address doesn't redirect, and fetched is false when the address hasn't been fetched so
+// it can't be told yet
+func ResolveRedirect(ctx *Context, raw string) (redirect *Redirect, fetched bool) {
+	tracker := ctx.G.Redirects
+	if tracker == nil || strings.TrimSpace(raw) == "" {
+		return nil, false
+	}
+	key, _ := CleanURL(ctx, raw)
+	tracker.mu.Lock()
+	redirect = tracker.chains[key]
+	fetched = tracker.fetched[key]
+	tracker.mu.Unlock()
+	return movedFrom(ctx, key, redirect), fetched
+}
+
+// movedFrom gives the part of a chain starting at the address asked for.  A redirect which only
+// normalizes the address (such as adding a trailing slash) is ignored since the page hasn't really moved
+func movedFrom(ctx *Context, key string, redirect *Redirect) *Redirect {
+	if redirect == nil {
+		return nil
+	}
+	if to, _ := CleanURL(ctx, redirect.To); to == key {
+		return nil
+	}
+	for i, hop := range redirect.Hops {
+		if hopKey, _ := CleanURL(ctx, hop.URL); hopKey == key {
+			return &Redirect{From: hop.URL, To: redirect.To, Hops: redirect.Hops[i:]}
+		}
+	}
+	return redirect
+}
+
+// ReconcileMovedURL checks whether the URL of a catalog entry redirects to the page the part was
+// just found on.  When it does the URL has simply moved, so the part keeps the new URL without being
+// flagged as changed.  Any catalog URL which redirects is remembered for the URL moved report.
+//
+// When the catalog URL hasn't been fetched yet it returns false, so the part is flagged as changed for
+// now, and the part is held back by OutputPartData until ReconcilePending looks at it after the crawl
+func ReconcileMovedURL(ctx *Context, partData *partcatalog.PartData, entry *partcatalog.PartData) bool {
+	redirect, fetched := ResolveRedirect(ctx, entry.URL)
+	if redirect == nil {
+		if !fetched && ctx.G.Redirects != nil {
+			ctx.G.Redirects.mu.Lock()
+			ctx.G.Redirects.pending = append(ctx.G.Redirects.pending, pendingMove{part: partData, entry: entry})
+			ctx.G.Redirects.mu.Unlock()
+		}
+		return false
+	}
+	return ctx.G.Redirects.reconcile(ctx, partData, entry, redirect)
+}
+
+// reconcile records where a catalog URL redirects, reporting whether it is to the page the part was found on
+func (t *RedirectTracker) reconcile(ctx *Context, partData *partcatalog.PartData, entry *partcatalog.PartData, redirect *Redirect) bool {
+	to, _ := CleanURL(ctx, redirect.To)
+	page, _ := CleanURL(ctx, partData.URL)
+	status := MovedDifferentPage
+	if to == page {
+		status = MovedReconciled
+	}
+	t.recordMoved(entry, redirect, status)
+	return status == MovedReconciled
+}
+
+// isPending reports whether a part is being held back until the crawl is over
+func (t *RedirectTracker) isPending(partData *partcatalog.PartData) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, pending := range t.pending {
+		if pending.part == partData {
+			return true
+		}
+	}
+	return false
+}
+
+// ReconcilePending outputs the parts which were held back because their catalog URL hadn't been
+// fetched when they were found.  By now the crawl has been to every catalog URL, so when one redirects
+// to the page its part was found on the Old URL note is taken back out again, and the part is no longer
+// Changed unless something else about it changed.  It must be called once the queue is done
+func ReconcilePending(ctx *Context) {
+	tracker := ctx.G.Redirects
+	if tracker == nil {
+		return
+	}
+	tracker.mu.Lock()
+	pending := tracker.pending
+	tracker.pending = nil
+	tracker.mu.Unlock()
+
+	for _, move := range pending {
+		partData, entry := move.part, move.entry
+		if redirect, _ := ResolveRedirect(ctx, entry.URL); redirect != nil && tracker.reconcile(ctx, partData, entry, redirect) {
+			partData.Notes = removeNote(partData.Notes, "Old URL:"+entry.URL)
+			if partData.SpiderStatus == partcatalog.PartChanged && len(partData.Changes()) == 0 {
+				partData.SpiderStatus = partcatalog.UnchangedPart
+				entry.SpiderStatus = partData.SpiderStatus
+			}
+		}
+		OutputPartData(ctx, partData)
+	}
+}
+
+// removeNote takes one of the ", " separated notes out of the notes of a part
+func removeNote(notes string, note string) string {
+	parts := strings.Split(notes, ", ")
+	for i, part := range parts {
+		if strings.TrimSpace(part) == note {
+			return strings.Join(append(parts[:i], parts[i+1:]...), ", ")
+		}
+	}
+	return notes
+}
+
+// recordMoved remembers a catalog entry whose URL redirects
+func (t *RedirectTracker) recordMoved(entry *partcatalog.PartData, redirect *Redirect, status string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.moved[strings.ToUpper(entry.SKU)] = &MovedURL{
+		SKU:     entry.SKU,
+		Name:    entry.Name,
+		Section: entry.Section,
+		OldURL:  entry.URL,
+		NewURL:  redirect.To,
+		Hops:    redirect.Hops,
+		Status:  status,
+	}
+}
+
+// FindMovedNotFound looks for catalog entries which weren't found by the spider but whose URL
+// redirected somewhere while crawling, since the new address is a good place to start looking for them
+func FindMovedNotFound(ctx *Context) {
+	if ctx.G.Redirects == nil || ctx.G.ReferenceData == nil {
+		return
+	}
+	for _, entry := range ctx.G.ReferenceData.PartNumber {
+		if entry.SpiderStatus != partcatalog.PartNotFoundBySpider || entry.URL == "" {
+			continue
+		}
+		if redirect, _ := ResolveRedirect(ctx, entry.URL); redirect != nil {
+			ctx.G.Redirects.recordMoved(entry, redirect, MovedNotFound)
+		}
+	}
+}
+
+// MovedURLs returns the catalog entries whose URL redirects, sorted by section and part number
+func (t *RedirectTracker) MovedURLs() []*MovedURL {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]*MovedURL, 0, len(t.moved))
+	for _, moved := range t.moved {
+		result = append(result, moved)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Section != result[j].Section {
+			return result[i].Section < result[j].Section
+		}
+		return result[i].SKU < result[j].SKU
+	})
+	return result
+}
+
+// WriteMovedURLs writes the URL moved report with one line for each catalog entry whose URL
+// redirects, giving the old and new addresses so that the spreadsheet can be updated in bulk
+func WriteMovedURLs(path string, moved []*MovedURL) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "%v`%v`%v`%v`%v`%v`%v\n",
+		"Part #", "Name", "Section", "Old URL", "New URL", "Status", "Redirects")
+	for _, entry := range moved {
+		chain := FormatChain(&Redirect{From: entry.OldURL, To: entry.NewURL, Hops: entry.Hops})
+		fmt.Fprintf(f, "%v`%v`%v`%v`%v`%v`%v\n",
+			entry.SKU, entry.Name, entry.Section, entry.OldURL, entry.NewURL, entry.Status, chain)
+	}
+	return nil
+}
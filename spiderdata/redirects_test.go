@@ -0,0 +1,173 @@
+package spiderdata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/toebes/ftc_parts_spider/canonurl"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// redirectServer sends /old through /mid to /new/, and /slash to /slash/ which only adds the slash
+func redirectServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/mid", http.StatusMovedPermanently))
+	mux.Handle("/mid", http.RedirectHandler("/new/", http.StatusFound))
+	mux.Handle("/slash", http.RedirectHandler("/slash/", http.StatusMovedPermanently))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	})
+	return httptest.NewServer(mux)
+}
+
+// redirectContext gives a context for the test server with an empty tracker and an output file
+func redirectContext(t *testing.T, server *httptest.Server) *Context {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outfile, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { outfile.Close() })
+	return &Context{G: &Globals{
+		Canon:     &canonurl.Canonicalizer{Host: u.Host},
+		Redirects: NewRedirectTracker(),
+		Outfile:   outfile,
+	}}
+}
+
+// fetch gets a page of the test server, recording its redirects
+func fetch(t *testing.T, ctx *Context, server *httptest.Server, path string) *http.Response {
+	t.Helper()
+	res, err := server.Client().Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	RecordRedirects(ctx, res)
+	return res
+}
+
+func TestRedirectChain(t *testing.T) {
+	server := redirectServer()
+	defer server.Close()
+
+	res, err := server.Client().Get(server.URL + "/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	want := []RedirectHop{
+		{URL: server.URL + "/old", Status: http.StatusMovedPermanently},
+		{URL: server.URL + "/mid", Status: http.StatusFound},
+	}
+	if got := RedirectChain(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("RedirectChain = %+v, expected %+v", got, want)
+	}
+
+	res, err = server.Client().Get(server.URL + "/new/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got := RedirectChain(res); len(got) != 0 {
+		t.Errorf("RedirectChain without a redirect = %+v, expected none", got)
+	}
+}
+
+func TestResolveRedirect(t *testing.T) {
+	server := redirectServer()
+	defer server.Close()
+	ctx := redirectContext(t, server)
+
+	if redirect, fetched := ResolveRedirect(ctx, server.URL+"/old"); redirect != nil || fetched {
+		t.Fatalf("before fetching /old got %+v, %v, expected nothing", redirect, fetched)
+	}
+	fetch(t, ctx, server, "/old")
+	fetch(t, ctx, server, "/slash")
+
+	tests := []struct {
+		path    string
+		from    string
+		hops    int
+		fetched bool
+	}{
+		{"/old", "/old", 2, true},
+		// Starting part way along the chain only gives the rest of it
+		{"/mid", "/mid", 1, true},
+		{"/new/", "", 0, true},
+		// Only adding a trailing slash isn't a move
+		{"/slash", "", 0, true},
+		{"/elsewhere", "", 0, false},
+	}
+	for _, test := range tests {
+		redirect, fetched := ResolveRedirect(ctx, server.URL+test.path)
+		if fetched != test.fetched {
+			t.Errorf("ResolveRedirect(%s) fetched = %v, expected %v", test.path, fetched, test.fetched)
+		}
+		if test.from == "" {
+			if redirect != nil {
+				t.Errorf("ResolveRedirect(%s) = %s, expected no redirect", test.path, FormatChain(redirect))
+			}
+			continue
+		}
+		if redirect == nil {
+			t.Errorf("ResolveRedirect(%s) = nil, expected a redirect", test.path)
+			continue
+		}
+		if redirect.From != server.URL+test.from || redirect.To != server.URL+"/new/" || len(redirect.Hops) != test.hops {
+			t.Errorf("ResolveRedirect(%s) = %s, expected %d hops from %s to /new/", test.path, FormatChain(redirect), test.hops, test.from)
+		}
+	}
+}
+
+func TestReconcilePending(t *testing.T) {
+	server := redirectServer()
+	defer server.Close()
+	ctx := redirectContext(t, server)
+
+	entry := &partcatalog.PartData{SKU: "REV-1", URL: server.URL + "/old"}
+	moved := &partcatalog.PartData{SKU: "REV-1", URL: server.URL + "/new/"}
+	other := &partcatalog.PartData{SKU: "REV-2", URL: server.URL + "/other"}
+	otherEntry := &partcatalog.PartData{SKU: "REV-2", URL: server.URL + "/gone"}
+
+	// Neither catalog URL has been fetched, so the parts are held back
+	for _, part := range []struct{ part, entry *partcatalog.PartData }{{moved, entry}, {other, otherEntry}} {
+		if ReconcileMovedURL(ctx, part.part, part.entry) {
+			t.Fatalf("%s reconciled before its catalog URL was fetched", part.part.SKU)
+		}
+		// This is what the vendors do when it can't be reconciled
+		part.part.SpiderStatus = partcatalog.PartChanged
+		part.part.Notes += " Old URL:" + part.entry.URL
+		OutputPartData(ctx, part.part)
+	}
+	if len(ctx.G.Emitted) != 0 {
+		t.Fatalf("%d parts were output before the crawl was over", len(ctx.G.Emitted))
+	}
+
+	fetch(t, ctx, server, "/old")
+	fetch(t, ctx, server, "/gone")
+	ReconcilePending(ctx)
+
+	if len(ctx.G.Emitted) != 2 {
+		t.Fatalf("%d parts were output after the crawl, expected 2", len(ctx.G.Emitted))
+	}
+	if moved.SpiderStatus != partcatalog.UnchangedPart || moved.Notes != "" || entry.SpiderStatus != partcatalog.UnchangedPart {
+		t.Errorf("moved part is %v with notes %q, expected it to be unchanged", moved.SpiderStatus, moved.Notes)
+	}
+	if other.SpiderStatus != partcatalog.PartChanged || other.Notes != " Old URL:"+otherEntry.URL {
+		t.Errorf("other part is %v with notes %q, expected it to stay changed", other.SpiderStatus, other.Notes)
+	}
+	urls := ctx.G.Redirects.MovedURLs()
+	if len(urls) != 1 || urls[0].SKU != "REV-1" || urls[0].Status != MovedReconciled {
+		t.Errorf("MovedURLs = %+v, expected REV-1 to be Moved", urls)
+	}
+}
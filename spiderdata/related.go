@@ -0,0 +1,207 @@
+package spiderdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// RelatedEdge is a link from a product page to a product that the vendor shows as related to it
+type RelatedEdge struct {
+	SourceURL  string
+	RelatedURL string
+	// Relation is how the vendor describes the link, for example "related" or the card type
+	Relation string
+	// Name is the title of the link, used when the spider never finds the related page
+	Name string
+}
+
+// RelatedNode is one product page in the graph of related products
+type RelatedNode struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Section string   `json:"section,omitempty"`
+	URL     string   `json:"url"`
+	SKUs    []string `json:"skus"`
+}
+
+// RelatedLink is a relationship between two nodes in the graph
+type RelatedLink struct {
+	Source      string   `json:"source"`
+	Target      string   `json:"target"`
+	Relation    string   `json:"relation"`
+	SourceSKUs  []string `json:"sourceSkus"`
+	RelatedSKUs []string `json:"relatedSkus"`
+}
+
+// RelatedGraph is the graph of related products written at the end of the run
+type RelatedGraph struct {
+	Nodes []RelatedNode `json:"nodes"`
+	Links []RelatedLink `json:"links"`
+}
+
+// RecordRelated remembers that the page being processed shows href as a related product
+func RecordRelated(ctx *Context, href string, relation string, name string) {
+	if href == "" {
+		return
+	}
+	if relation == "" {
+		relation = "related"
+	}
+	edge := RelatedEdge{
+		SourceURL:  ctx.Url,
+		RelatedURL: resolveLink(ctx, href),
+		Relation:   relation,
+		Name:       strings.TrimSpace(name),
+	}
+	ctx.G.Related = append(ctx.G.Related, edge)
+	ctx.G.Crawl.recordRelated(ctx.Url, edge)
+}
+
+// relatedKey reduces a URL to the product page it refers to so that the options of a product all end up on the same node
+func relatedKey(url string) string {
+	url, _, _ = strings.Cut(strings.TrimSpace(url), "?")
+	url, _, _ = strings.Cut(url, "#")
+	return strings.TrimSuffix(url, "/")
+}
+
+// BuildRelatedGraph matches the related product links against the parts found by the spider to
+// get the SKUs at each end of the link.  Pages which the spider never produced a part for
+// are still included with no SKUs
+func BuildRelatedGraph(edges []RelatedEdge, emitted []*partcatalog.PartData) RelatedGraph {
+	var graph RelatedGraph
+	nodes := make(map[string]int)
+	addNode := func(url string, name string) int {
+		key := relatedKey(url)
+		if idx, found := nodes[key]; found {
+			return idx
+		}
+		idx := len(graph.Nodes)
+		nodes[key] = idx
+		graph.Nodes = append(graph.Nodes, RelatedNode{ID: fmt.Sprintf("n%d", idx+1), Name: name, URL: key, SKUs: []string{}})
+		return idx
+	}
+
+	// Only pages which take part in a relationship become nodes
+	for _, edge := range edges {
+		addNode(edge.SourceURL, "")
+		addNode(edge.RelatedURL, edge.Name)
+	}
+	for _, partData := range emitted {
+		if partData.SKU == "" || partData.URL == "" || partData.SpiderStatus == partcatalog.PartNotFoundBySpider {
+			continue
+		}
+		idx, found := nodes[relatedKey(partData.URL)]
+		if !found {
+			continue
+		}
+		node := &graph.Nodes[idx]
+		if !slices.Contains(node.SKUs, partData.SKU) {
+			node.SKUs = append(node.SKUs, partData.SKU)
+		}
+		name := partData.BaseName
+		if name == "" {
+			name = partData.Name
+		}
+		if name != "" && (node.Name == "" || len(node.SKUs) == 1) {
+			node.Name = name
+		}
+		if node.Section == "" {
+			node.Section = partData.Section
+		}
+	}
+	for i := range graph.Nodes {
+		sort.Strings(graph.Nodes[i].SKUs)
+	}
+
+	seen := make(map[string]bool)
+	for _, edge := range edges {
+		source := graph.Nodes[nodes[relatedKey(edge.SourceURL)]]
+		target := graph.Nodes[nodes[relatedKey(edge.RelatedURL)]]
+		key := source.ID + "`" + target.ID + "`" + edge.Relation
+		if source.ID == target.ID || seen[key] {
+			continue
+		}
+		seen[key] = true
+		graph.Links = append(graph.Links, RelatedLink{
+			Source:      source.ID,
+			Target:      target.ID,
+			Relation:    edge.Relation,
+			SourceSKUs:  source.SKUs,
+			RelatedSKUs: target.SKUs,
+		})
+	}
+	return graph
+}
+
+// WriteRelatedJSON saves the graph as a JSON file
+func WriteRelatedJSON(path string, graph RelatedGraph) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(graph)
+}
+
+// WriteRelatedDOT saves the graph in the Graphviz DOT language so that it can be drawn with
+//
+//	dot -Tsvg rev-related.dot -o rev-related.svg
+func WriteRelatedDOT(path string, graph RelatedGraph) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeRelatedDOT(f, graph); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeRelatedDOT does the work of WriteRelatedDOT
+func writeRelatedDOT(w io.Writer, graph RelatedGraph) error {
+	fmt.Fprintln(w, "digraph related {")
+	fmt.Fprintln(w, "\trankdir=LR;")
+	fmt.Fprintln(w, "\tnode [shape=box, fontsize=10];")
+	for _, node := range graph.Nodes {
+		label := node.Name
+		if label == "" {
+			label = node.URL
+		}
+		switch len(node.SKUs) {
+		case 0:
+		case 1:
+			label += "\n" + node.SKUs[0]
+		default:
+			label += fmt.Sprintf("\n%s … (%d parts)", node.SKUs[0], len(node.SKUs))
+		}
+		style := ""
+		if len(node.SKUs) == 0 {
+			// Never found by the spider
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(w, "\t%s [label=%s, URL=%s%s];\n", node.ID, dotQuote(label), dotQuote(node.URL), style)
+	}
+	for _, link := range graph.Links {
+		fmt.Fprintf(w, "\t%s -> %s [label=%s];\n", link.Source, link.Target, dotQuote(link.Relation))
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// dotQuote makes a string safe to use as a quoted DOT identifier
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,188 @@
+package spiderdata
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RuleKind says how the pattern of a URLRule is matched
+type RuleKind int
+
+const (
+	// RegexRule - the pattern is a regular expression matched against the whole URL
+	RegexRule RuleKind = iota
+	// GlobRule - * matches within a path segment and ** matches across them.
+	// A pattern starting with / is matched against the path, otherwise against the whole URL
+	GlobRule
+	// PrefixRule - the URL starts with the pattern.  A pattern starting with / is matched against the path
+	PrefixRule
+	// HostRule - the host is the pattern.  *.example.com matches example.com and any host under it
+	HostRule
+)
+
+// String gives the name of the kind of rule for log messages
+func (k RuleKind) String() string {
+	switch k {
+	case RegexRule:
+		return "regex"
+	case GlobRule:
+		return "glob"
+	case PrefixRule:
+		return "prefix"
+	case HostRule:
+		return "host"
+	}
+	return fmt.Sprintf("RuleKind(%d)", int(k))
+}
+
+// URLRule is one pattern used to decide whether a URL should be crawled
+type URLRule struct {
+	Kind    RuleKind
+	Pattern string
+}
+
+// Regex makes a rule which matches the whole URL against a regular expression
+func Regex(pattern string) URLRule { return URLRule{RegexRule, pattern} }
+
+// Glob makes a rule which matches a shell style pattern such as "/spss-*"
+func Glob(pattern string) URLRule { return URLRule{GlobRule, pattern} }
+
+// Prefix makes a rule which matches the start of the URL, or of the path when the pattern starts with /
+func Prefix(pattern string) URLRule { return URLRule{PrefixRule, pattern} }
+
+// Host makes a rule which matches the host of the URL
+func Host(pattern string) URLRule { return URLRule{HostRule, pattern} }
+
+// String describes the rule for log messages, e.g. glob "/spss-*"
+func (r URLRule) String() string {
+	return fmt.Sprintf("%v %q", r.Kind, r.Pattern)
+}
+
+// compiledRule is a URLRule ready to be matched
+type compiledRule struct {
+	URLRule
+	re *regexp.Regexp
+}
+
+// urlRules are the compiled include and exclude rules of a target
+type urlRules struct {
+	include []compiledRule
+	exclude []compiledRule
+}
+
+// compile prepares a rule, turning regex and glob patterns into regular expressions
+func (r URLRule) compile() (compiledRule, error) {
+	result := compiledRule{URLRule: r}
+	var err error
+	switch r.Kind {
+	case RegexRule:
+		result.re, err = regexp.Compile(r.Pattern)
+	case GlobRule:
+		result.re, err = regexp.Compile(globToRegexp(r.Pattern))
+	case PrefixRule, HostRule:
+	default:
+		err = fmt.Errorf("unknown kind of rule")
+	}
+	if err != nil {
+		return result, fmt.Errorf("bad URL rule %v: %w", r, err)
+	}
+	return result, nil
+}
+
+// globToRegexp converts a glob pattern into an anchored regular expression
+func globToRegexp(pattern string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// match checks a URL against the rule
+func (r compiledRule) match(rawurl string, u *url.URL) bool {
+	subject := rawurl
+	if strings.HasPrefix(r.Pattern, "/") {
+		subject = u.Path
+	}
+	switch r.Kind {
+	case RegexRule:
+		return r.re.MatchString(rawurl)
+	case GlobRule:
+		return r.re.MatchString(subject)
+	case PrefixRule:
+		return strings.HasPrefix(subject, r.Pattern)
+	case HostRule:
+		host := strings.ToLower(u.Hostname())
+		pattern := strings.ToLower(r.Pattern)
+		if domain, found := strings.CutPrefix(pattern, "*."); found {
+			return host == domain || strings.HasSuffix(host, "."+domain)
+		}
+		return host == pattern
+	}
+	return false
+}
+
+// CompileRules prepares the include and exclude rules of the target so that bad patterns are found
+// before the run starts
+func (t *SpiderTarget) CompileRules() error {
+	rules := &urlRules{}
+	for _, rule := range t.IncludeRules {
+		compiled, err := rule.compile()
+		if err != nil {
+			return err
+		}
+		rules.include = append(rules.include, compiled)
+	}
+	for _, rule := range t.ExcludeRules {
+		compiled, err := rule.compile()
+		if err != nil {
+			return err
+		}
+		rules.exclude = append(rules.exclude, compiled)
+	}
+	t.rules = rules
+	return nil
+}
+
+// CheckURL decides whether a URL should be crawled.  Exclude rules win over include rules, and when
+// there are include rules the URL has to match one of them.  When the URL is rejected the reason
+// says which rule was responsible
+func (t *SpiderTarget) CheckURL(rawurl string) (ok bool, reason string) {
+	if t == nil || t.rules == nil {
+		return true, ""
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false, fmt.Sprintf("unable to parse URL: %v", err)
+	}
+	for _, rule := range t.rules.exclude {
+		if rule.match(rawurl, u) {
+			return false, fmt.Sprintf("matches exclude rule %v", rule.URLRule)
+		}
+	}
+	if len(t.rules.include) == 0 {
+		return true, ""
+	}
+	for _, rule := range t.rules.include {
+		if rule.match(rawurl, u) {
+			return true, ""
+		}
+	}
+	return false, "does not match any include rule"
+}
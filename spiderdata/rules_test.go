@@ -0,0 +1,73 @@
+package spiderdata
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		matches []string
+		misses  []string
+	}{
+		{"/spss-*", []string{"/spss-", "/spss-gear"}, []string{"/spss-gear/detail", "/x/spss-gear"}},
+		{"/products/**", []string{"/products/", "/products/a/b/c"}, []string{"/product/a"}},
+		{"/gear-?", []string{"/gear-1"}, []string{"/gear-", "/gear-12", "/gear-/"}},
+		// Regular expression characters are taken literally
+		{"/1.50-bore(+)", []string{"/1.50-bore(+)"}, []string{"/1x50-bore(+)", "/1.50-bore"}},
+	}
+	for _, test := range tests {
+		re := regexp.MustCompile(globToRegexp(test.pattern))
+		for _, s := range test.matches {
+			if !re.MatchString(s) {
+				t.Errorf("glob %q doesn't match %q", test.pattern, s)
+			}
+		}
+		for _, s := range test.misses {
+			if re.MatchString(s) {
+				t.Errorf("glob %q matches %q", test.pattern, s)
+			}
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	target := &SpiderTarget{
+		IncludeRules: []URLRule{Host("*.studica.com")},
+		ExcludeRules: []URLRule{Prefix("/cdn-cgi/"), Glob("/spss-*"), Regex(`[?&]page=\d+`), Host("docs.studica.com")},
+	}
+	if err := target.CompileRules(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url    string
+		ok     bool
+		reason string
+	}{
+		{"https://www.studica.com/gears", true, ""},
+		{"https://studica.com/gears", true, ""},
+		{"https://www.example.com/gears", false, "does not match any include rule"},
+		// An exclude rule wins over the include rule which also matches
+		{"https://www.studica.com/cdn-cgi/l/email", false, `prefix "/cdn-cgi/"`},
+		{"https://www.studica.com/spss-gear", false, `glob "/spss-*"`},
+		{"https://www.studica.com/spss-gear/more", true, ""},
+		{"https://www.studica.com/gears?page=2", false, "regex"},
+		{"https://docs.studica.com/gears", false, `host "docs.studica.com"`},
+	}
+	for _, test := range tests {
+		ok, reason := target.CheckURL(test.url)
+		if ok != test.ok || !strings.Contains(reason, test.reason) {
+			t.Errorf("CheckURL(%s) = %v, %q, expected %v with %q", test.url, ok, reason, test.ok, test.reason)
+		}
+	}
+
+	// Without rules everything is crawled, but a bad pattern is caught before the run
+	if ok, _ := (&SpiderTarget{}).CheckURL("https://anywhere.example.com/"); !ok {
+		t.Error("a target without rules rejected a URL")
+	}
+	if err := (&SpiderTarget{ExcludeRules: []URLRule{Regex("(")}}).CompileRules(); err == nil {
+		t.Error("a bad regular expression was accepted")
+	}
+}
@@ -0,0 +1,77 @@
+package spiderdata
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// maxSpecLabel is the longest label we accept as the name of a specification.  Anything longer is a sentence
+const maxSpecLabel = 40
+
+// ExtractSpecs gathers the specifications on a product page.  These come from two column tables
+//
+//	<tr><th>Bore</th><td>8mm REX</td></tr>
+//
+// definition lists
+//
+//	<dl><dt>Material:</dt><dd>6061-T6 Aluminum</dd></dl>
+//
+// and lists of "Name: Value" lines in an area marked as specifications.
+// Returns nil when the page has no specifications
+func ExtractSpecs(product *goquery.Selection) map[string]partcatalog.SpecValue {
+	result := make(map[string]partcatalog.SpecValue)
+	add := func(label string, value string) {
+		name := partcatalog.SpecName(label)
+		value = strings.TrimSpace(value)
+		if name == "" || value == "" || len(name) > maxSpecLabel {
+			return
+		}
+		// Quantities and part numbers in the first column mean this is a list of parts (like the contents of a kit)
+		if first := name[0]; first == '(' || (first >= '0' && first <= '9') {
+			return
+		}
+		// The first one on the page wins since that is normally the main specification table
+		if _, found := result[name]; !found {
+			result[name] = partcatalog.ParseSpecValue(value)
+		}
+	}
+
+	product.Find("tr").Each(func(i int, tr *goquery.Selection) {
+		cells := tr.Children().Filter("th, td")
+		if cells.Length() == 2 {
+			add(cells.Eq(0).Text(), cells.Eq(1).Text())
+		}
+	})
+	product.Find("dl").Each(func(i int, dl *goquery.Selection) {
+		dl.Find("dt").Each(func(i int, dt *goquery.Selection) {
+			add(dt.Text(), dt.NextFiltered("dd").Text())
+		})
+	})
+	product.Find("[class*=spec] li, [id*=spec] li").Each(func(i int, li *goquery.Selection) {
+		if label, value, found := strings.Cut(li.Text(), ":"); found {
+			add(label, value)
+		}
+	})
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// MergeSpecs combines the specifications for a single row of a product table with those for the whole page.
+// The row takes priority since it is specific to the part
+func MergeSpecs(page map[string]partcatalog.SpecValue, row map[string]partcatalog.SpecValue) map[string]partcatalog.SpecValue {
+	if len(page) == 0 && len(row) == 0 {
+		return nil
+	}
+	result := make(map[string]partcatalog.SpecValue)
+	for name, value := range page {
+		result[name] = value
+	}
+	for name, value := range row {
+		result[name] = value
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package spiderdata
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+func TestExtractSpecs(t *testing.T) {
+	f, err := os.Open("testdata/specs.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := goquery.NewDocumentFromReader(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	products := doc.Find(".product")
+
+	want := map[string]partcatalog.SpecValue{
+		// The table comes first so its bore wins over the one in the list
+		"Bore":     partcatalog.ParseSpecValue("8mm REX"),
+		"Pitch":    partcatalog.ParseSpecValue("14mm"),
+		"Teeth":    partcatalog.ParseSpecValue("12"),
+		"Material": partcatalog.ParseSpecValue("6061-T6 Aluminum"),
+		"Weight":   partcatalog.ParseSpecValue("12.5 g"),
+		"Length":   partcatalog.ParseSpecValue(`1-1/2"`),
+	}
+	if got := ExtractSpecs(products.First()); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractSpecs = %v, expected %v", got, want)
+	}
+	if got := ExtractSpecs(products.Last()); got != nil {
+		t.Errorf("ExtractSpecs of a product without specifications = %v, expected nil", got)
+	}
+}
+
+func TestMergeSpecs(t *testing.T) {
+	page := map[string]partcatalog.SpecValue{
+		"Bore":     partcatalog.ParseSpecValue("8mm REX"),
+		"Material": partcatalog.ParseSpecValue("Aluminum"),
+	}
+	row := map[string]partcatalog.SpecValue{
+		"Bore":   partcatalog.ParseSpecValue(`1/2" Hex`),
+		"Length": partcatalog.ParseSpecValue("48mm"),
+	}
+	want := map[string]partcatalog.SpecValue{
+		"Bore":     row["Bore"],
+		"Material": page["Material"],
+		"Length":   row["Length"],
+	}
+	if got := MergeSpecs(page, row); !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeSpecs = %v, expected %v", got, want)
+	}
+	if got := MergeSpecs(page, nil); !reflect.DeepEqual(got, page) {
+		t.Errorf("MergeSpecs without a row = %v, expected %v", got, page)
+	}
+	if got := MergeSpecs(nil, nil); got != nil {
+		t.Errorf("MergeSpecs(nil, nil) = %v, expected nil", got)
+	}
+	// The page is shared by every row so it must not be changed
+	if len(page) != 2 || page["Bore"] != partcatalog.ParseSpecValue("8mm REX") {
+		t.Errorf("MergeSpecs changed the page specifications to %v", page)
+	}
+}
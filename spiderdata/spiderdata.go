@@ -2,12 +2,16 @@ package spiderdata
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 	"sync"
 
 	"github.com/PuerkitoBio/fetchbot"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/bigcommerce"
+	"github.com/toebes/ftc_parts_spider/canonurl"
+	"github.com/toebes/ftc_parts_spider/onshape"
 	"github.com/toebes/ftc_parts_spider/partcatalog"
 )
 
@@ -47,12 +51,33 @@ type Globals struct {
 	Outfile       *os.File
 	SingleOnly    bool
 	StripSKU      bool
+	// Counters for the end of run summary
+	Stats *RunStats
+	// Every part written to the output file, for the reports produced at the end of the run
+	Emitted []*partcatalog.PartData
+	// The contents of all the kits found
+	Kits []*Kit
+	// Links to related products found on the product pages
+	Related []RelatedEdge
+	// What each page produced, kept between runs for incremental crawls.  nil when not incremental
+	Crawl *CrawlState
+	// Storefront reads the products from the store's API instead of the pages.  nil when not in API mode
+	Storefront *bigcommerce.Client
+	// The redirects followed while fetching, for reconciling catalog URLs which have moved
+	Redirects *RedirectTracker
+	// DryEnqueue prints the links found on the pages instead of crawling them
+	DryEnqueue bool
+	// Canon normalizes the URLs used as keys and written to the output file
+	Canon *canonurl.Canonicalizer
+	// Specifications which get their own column in the output file
+	SpecColumns []string
 }
 
 // Define a struct to hold the queue and pending request counter
 type QueueCounter struct {
-	PendingCount int
-	Mutex        sync.Mutex
+	PendingCount  int
+	InFlightCount int
+	Mutex         sync.Mutex
 }
 
 // Increment adds to the pending counter when a request is enqueued
@@ -76,6 +101,32 @@ func (qm *QueueCounter) GetPendingCount() int {
 	return qm.PendingCount
 }
 
+// StartFetch notes that a request has gone out to the website
+func (qm *QueueCounter) StartFetch() {
+	qm.Mutex.Lock()
+	defer qm.Mutex.Unlock()
+	qm.InFlightCount++
+}
+
+// EndFetch notes that the website has responded to a request
+func (qm *QueueCounter) EndFetch() {
+	qm.Mutex.Lock()
+	defer qm.Mutex.Unlock()
+	qm.InFlightCount--
+}
+
+// GetCounts returns the number of requests being fetched and the number still waiting in the queue
+func (qm *QueueCounter) GetCounts() (inflight int, queued int) {
+	qm.Mutex.Lock()
+	defer qm.Mutex.Unlock()
+	inflight = qm.InFlightCount
+	queued = qm.PendingCount - qm.InFlightCount
+	if queued < 0 {
+		queued = 0
+	}
+	return
+}
+
 // Context provides the globals used everywhere
 type Context struct {
 	Cmd fetchbot.Command
@@ -83,6 +134,8 @@ type Context struct {
 	Q   *fetchbot.Queue
 	Qc  *QueueCounter
 	G   *Globals
+	// Storefront is what the storefront API said about the product on the page, nil when it wasn't asked
+	Storefront *bigcommerce.Product
 }
 
 // SpiderTarget provides the information for spidering a given vendor
@@ -110,6 +163,26 @@ type SpiderTarget struct {
 	SectionEquivalents [][]string
 	// These are pages to be skipped when processing.
 	SkipPages []string
+	// IncludeRules limit the crawl to the URLs which match one of them (typically the vendor's host)
+	IncludeRules []URLRule
+	// ExcludeRules are URLs which are never crawled even when an include rule matches them
+	ExcludeRules []URLRule
+	// rules are IncludeRules and ExcludeRules ready for use, set up by CompileRules
+	rules *urlRules
+	// SignificantParams are the query parameters which pick out a different page and are kept even with StripSKU
+	SignificantParams []string
+	// IgnoreCase says that the site ignores the case of URL paths, so they can be lower cased
+	IgnoreCase bool
+	// TrailingSlash says that the page URLs of the site end with a /
+	TrailingSlash bool
+	// Sitemap seeds the run with every page listed in the sitemaps of the site as well as the Seed
+	Sitemap bool
+	// StorefrontAPI says that the site is a BigCommerce store whose Storefront API can be used with -api
+	StorefrontAPI bool
+	// Sheet is the layout of the catalog spreadsheet when it differs from the standard one
+	Sheet partcatalog.SheetConfig
+	// OnshapeNaming proposes the library folder and names for the models of new parts with -naming
+	OnshapeNaming onshape.Naming
 }
 
 // SaveCategory Saves a found Category URL
@@ -146,34 +219,71 @@ func MakeBreadCrumb(ctx *Context, base string, toadd string) (result string) {
 	return
 }
 
-// CleanURL removes any selector from a URL returning the cleaned string and an indication that it was removed
-func CleanURL(ctx *Context, url string) (result string, stripped bool) {
-	stripped = false
-	if ctx.G.StripSKU {
-		trimmed := strings.TrimSpace(url)
-		if trimmed != url {
-			stripped = true
-			url = trimmed
-		}
-		// Trim off any ?sku= on the URL
-		pos := strings.Index(url, "?")
-		if pos > 0 { // note > and not >= because we don't want to get an empty URL
-			// Trim off any ?sku parameters
-			url = url[:pos]
-			stripped = true
-		}
-		// Trim off any #xxxx on the URL
-		pos = strings.Index(url, "#")
-		if pos > 0 { // note > and not >= because we don't want to get an empty URL
-			// Trim off any #xxx parameters
-			url = url[:pos]
-			stripped = true
+// canon returns the URL canonicalizer for the run, making one from the settings if it hasn't been set up
+func canon(ctx *Context) *canonurl.Canonicalizer {
+	if ctx.G.Canon == nil {
+		ctx.G.Canon = &canonurl.Canonicalizer{StripQuery: ctx.G.StripSKU}
+		if ctx.G.TargetConfig != nil {
+			ctx.G.Canon.SignificantParams = ctx.G.TargetConfig.SignificantParams
+			ctx.G.Canon.IgnoreCase = ctx.G.TargetConfig.IgnoreCase
+			ctx.G.Canon.TrailingSlash = ctx.G.TargetConfig.TrailingSlash
 		}
 	}
-	result = url
+	return ctx.G.Canon
+}
+
+// CleanURL turns a URL into the key for the page it refers to, removing any selector (such as ?sku=)
+// when StripSKU is set.  It returns the cleaned string and an indication that a selector was removed
+func CleanURL(ctx *Context, url string) (result string, stripped bool) {
+	result = canon(ctx).Key(url)
+	stripped = result != canon(ctx).Canonical(url)
 	return
 }
 
+// CanonicalURL normalizes a URL for the output file, keeping any selector on it
+func CanonicalURL(ctx *Context, url string) string {
+	return canon(ctx).Canonical(url)
+}
+
+// SameURL checks whether two URLs are the same once they have been normalized
+func SameURL(ctx *Context, a string, b string) bool {
+	return canon(ctx).Same(a, b)
+}
+
+// CanonicalPage looks for a <link rel="canonical"> on a page and returns the key for the page it names.
+// Only a canonical link on the vendor's own site is used.  duplicate is set when the canonical page
+// has already been seen under a different address, in which case the page shouldn't be processed again
+func CanonicalPage(ctx *Context, doc *goquery.Document) (url string, duplicate bool) {
+	url = ctx.Url
+	href, found := doc.Find("link[rel=canonical]").First().Attr("href")
+	if !found || strings.TrimSpace(href) == "" {
+		return url, false
+	}
+	canonical, _ := CleanURL(ctx, resolveLink(ctx, href))
+	key, _ := CleanURL(ctx, url)
+	if !canon(ctx).SameHost(canonical) || canonical == key {
+		return url, false
+	}
+	if _, seen := ctx.G.BreadcrumbMap[canonical]; seen {
+		slog.Debug("duplicate of canonical page", "url", url, "canonical", canonical)
+		return canonical, true
+	}
+	ctx.G.BreadcrumbMap[canonical] = ctx.G.BreadcrumbMap[key]
+	return canonical, false
+}
+
+// resolveLink makes a link found on the current page absolute
+func resolveLink(ctx *Context, href string) string {
+	if ctx.Cmd == nil {
+		return href
+	}
+	resolved, err := ctx.Cmd.URL().Parse(href)
+	if err != nil {
+		return href
+	}
+	return resolved.String()
+}
+
 // EnqueURL puts a URL on the queue
 func EnqueURL(ctx *Context, url string, breadcrumb string) {
 	if url != "" {
@@ -182,25 +292,37 @@ func EnqueURL(ctx *Context, url string, breadcrumb string) {
 		if ctx.Cmd != nil {
 			u, err := ctx.Cmd.URL().Parse(url)
 			if err != nil {
-				fmt.Printf("error: resolve URL %s - %s\n", url, err)
+				slog.Error("unable to resolve URL", "url", url, "err", err)
 				return
 			}
 			url = u.String()
 		}
+		ctx.G.Crawl.recordLink(ctx.Url, url, breadcrumb)
 		// Trim off any sku= on the URL
 		urlString, _ := CleanURL(ctx, url)
+		if ok, reason := ctx.G.TargetConfig.CheckURL(urlString); !ok {
+			slog.Debug("rejected", "url", urlString, "reason", reason)
+			if ctx.G.DryEnqueue && ctx.Url != "" {
+				fmt.Printf("reject\t%s\t%s\n", urlString, reason)
+			}
+			return
+		}
 		prevbreadcrumb, found := ctx.G.BreadcrumbMap[urlString]
-		if !found {
-			fmt.Printf("+++Enqueue:%s for '%v'\n", url, breadcrumb)
+		if !found && ctx.G.DryEnqueue && ctx.Url != "" {
+			// Only the page given to -dry-enqueue is fetched, everything it links to is just listed
+			fmt.Printf("enqueue\t%s\t%s\n", urlString, breadcrumb)
+			ctx.G.BreadcrumbMap[urlString] = breadcrumb
+		} else if !found {
+			slog.Debug("enqueue", "url", url, "breadcrumb", breadcrumb)
 			if _, err := ctx.Q.SendStringGet(urlString); err != nil {
 				// if _, err := ctx.Q.SendStringHead(urlString); err != nil {
-				fmt.Printf("error: enqueue head %s - %s\n", url, err)
+				slog.Error("unable to enqueue", "url", url, "err", err)
 			} else {
 				ctx.Qc.Increment()
 				ctx.G.BreadcrumbMap[urlString] = breadcrumb
 			}
 		} else if len(breadcrumb) > len(prevbreadcrumb) {
-			fmt.Printf("-Found Better breadcrumb '%v' for %v which was '%v'", breadcrumb, urlString, prevbreadcrumb)
+			slog.Debug("found better breadcrumb", "url", urlString, "breadcrumb", breadcrumb, "previous", prevbreadcrumb)
 			ctx.G.BreadcrumbMap[urlString] = breadcrumb
 		}
 	}
@@ -213,11 +335,12 @@ func WasVisitedURL(ctx *Context, url string) (visited bool) {
 
 		u, err := ctx.Cmd.URL().Parse(url)
 		if err != nil {
-			fmt.Printf("error: resolve URL %s - %s\n", url, err)
+			slog.Error("unable to resolve URL", "url", url, "err", err)
 			return
 		}
 		mapUrl = u.String()
 	}
+	mapUrl, _ = CleanURL(ctx, mapUrl)
 	_, visited = ctx.G.BreadcrumbMap[mapUrl]
 	return
 }
@@ -230,11 +353,12 @@ func MarkVisitedURL(ctx *Context, url string, breadcrumb string) {
 
 		u, err := ctx.Cmd.URL().Parse(url)
 		if err != nil {
-			fmt.Printf("error: resolve URL %s - %s\n", url, err)
+			slog.Error("unable to resolve URL", "url", url, "err", err)
 			return
 		}
 		mapUrl = u.String()
 	}
+	mapUrl, _ = CleanURL(ctx, mapUrl)
 	_, found := ctx.G.BreadcrumbMap[mapUrl]
 	if !found {
 		ctx.G.BreadcrumbMap[mapUrl] = breadcrumb
@@ -242,21 +366,24 @@ func MarkVisitedURL(ctx *Context, url string, breadcrumb string) {
 }
 
 // The output routines write the messages in two places.
-//  First it puts a status on stdout so that the you can see what is happening
+//  First it logs a status message so that the you can see what is happening
 //  It also puts in lines in the output file so that it can be pulled into a spreadsheet
 //  Note that the lines are numbered with columns separated by a backtick because sometimes
 //  we may see tabs in the names
 
 // OutputHeader generates the first line of the output file with the column headers
 // Note that we use ` to separate columns because we sometimes see tabs in the names
+// Any specifications selected with SpecColumns come at the end, one column each
 func OutputHeader(ctx *Context) {
-	fmt.Fprintf(ctx.G.Outfile, "%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v\n",
-		"Order", "Section", "Name", "Part #", "Combined Name", "URL", "Model URL", "Extra 1", "Extra 2", "Extra 3", "Extra 4", "Extra 5", "Extra 6", "Extra 7", "Onshape URL", "Model Status", "Spider Status", "Notes")
+	columns := []string{"Order", "Section", "Name", "Part #", "Combined Name", "URL", "Model URL", "Extra 1", "Extra 2", "Extra 3", "Extra 4", "Extra 5", "Extra 6", "Extra 7", "Onshape URL", "Model Status", "Spider Status", "Notes",
+		"Parent ID", "Base Name", "Variant", "Specs", "Images"}
+	columns = append(columns, ctx.G.SpecColumns...)
+	fmt.Fprintln(ctx.G.Outfile, strings.Join(columns, "`"))
 }
 
 // OutputCategory puts in a category line at the start of each new section
 func OutputCategory(ctx *Context, breadcrumbs string, trimlast bool) {
-	fmt.Printf("+++OutputCategory: '%v' trim:%v\n", breadcrumbs, trimlast)
+	slog.Debug("output category", "breadcrumb", breadcrumbs, "trim", trimlast)
 	category := breadcrumbs
 	if trimlast {
 		offset := strings.LastIndex(category, " > ")
@@ -267,57 +394,105 @@ func OutputCategory(ctx *Context, breadcrumbs string, trimlast bool) {
 		}
 	}
 	if category != ctx.G.LastCategory {
-		fmt.Printf("|CATEGORY:|%s\n", category)
+		slog.Info("category", "section", category)
 		// fmt.Fprintf(outfile, "%d`CATEGORY: %s\n", linenum, category)
 		ctx.G.LastCategory = category
 	}
 }
 
-// OutputProduct takes the spidered information and generates the output structure
-func OutputProduct(ctx *Context, name string, sku string, url string, modelURL string, isDiscontinued bool, extra []string) {
-	var partData partcatalog.PartData
+// OutputProduct takes the spidered information and generates the output structure.
+func OutputProduct(ctx *Context, name string, sku string, url string, modelURL string, availability Availability, extra []string) {
+	OutputProductData(ctx, NewProductData(name, sku, url, modelURL, extra), availability)
+}
+
+// NewProductData creates the part for a spidered product so that the caller can fill in
+// more information (such as the variant) before passing it to OutputProductData
+func NewProductData(name string, sku string, url string, modelURL string, extra []string) *partcatalog.PartData {
+	partData := new(partcatalog.PartData)
 	partData.Name = name
 	partData.SKU = sku
 	partData.URL = url
 	partData.ModelURL = modelURL
-	partData.Section = ctx.G.LastCategory
 	copy(partData.Extra[:], extra)
+	return partData
+}
+
+// OutputProductData checks a spidered product against the catalog and generates the output line.
+// A discontinued product is reported as such regardless of what the catalog says, while
+// a product on clearance keeps its status but gets a note so that we can watch for it going away
+func OutputProductData(ctx *Context, partData *partcatalog.PartData, availability Availability) {
+	partData.Section = ctx.G.LastCategory
+	partData.URL = CanonicalURL(ctx, partData.URL)
+	// Variants always need something to group them by, even when the vendor doesn't give us an id
+	if len(partData.Variant) > 0 && partData.ParentID == "" {
+		partData.ParentID, _ = CleanURL(ctx, partData.URL)
+	}
+	ctx.G.Crawl.recordProduct(ctx.Url, partData, availability)
 
 	partData.Order = uint(ctx.G.Linenum)
 	ctx.G.Linenum++
 
-	ctx.G.TargetConfig.CheckMatchFunc(ctx, &partData)
+	ctx.G.TargetConfig.CheckMatchFunc(ctx, partData)
 
-	if isDiscontinued {
+	switch availability {
+	case Discontinued:
 		partData.SpiderStatus = partcatalog.DiscontinuedPart
+	case Clearance:
+		if partData.Notes != "" {
+			partData.Notes += ", "
+		}
+		partData.Notes += "Clearance"
 	}
-	OutputPartData(ctx, &partData)
+	OutputPartData(ctx, partData)
 }
 
-// OutputPartData generates the product line for the output file and also prints a status message on stdout
+// OutputPartData generates the product line for the output file and also logs a status message.
+// A part waiting on its catalog URL to be fetched is left for ReconcilePending
 func OutputPartData(ctx *Context, partData *partcatalog.PartData) {
+	if ctx.G.Redirects.isPending(partData) {
+		return
+	}
 
-	partData.Println()
+	slog.Info("part", "part", partData)
+	if ctx.G.Stats != nil {
+		ctx.G.Stats.RecordPart(partData)
+	}
+	ctx.G.Emitted = append(ctx.G.Emitted, partData)
 
-	fmt.Fprintf(ctx.G.Outfile, "%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v`%v\n",
-		partData.Order,
+	columns := []string{
+		fmt.Sprint(partData.Order),
 		partData.Section,
 		partData.Name,
 		partData.SKU,
-		strings.TrimSpace(partData.Name+" "+partData.SKU),
+		strings.TrimSpace(partData.Name + " " + partData.SKU),
 		partData.URL,
 		partData.ModelURL,
 		partData.Extra[0], partData.Extra[1], partData.Extra[2], partData.Extra[3], partData.Extra[4], partData.Extra[5], partData.Extra[6],
 		partData.OnshapeURL,
 		partData.Status,
-		partData.SpiderStatus,
-		partData.Notes)
+		partData.SpiderStatus.String(),
+		partData.Notes,
+		partData.ParentID,
+		partData.BaseName,
+		partcatalog.FormatVariant(partData.Variant),
+		partcatalog.FormatSpecs(partData.Specs),
+		strings.Join(partData.Images, " "),
+	}
+	for _, name := range ctx.G.SpecColumns {
+		spec, _ := partData.Spec(name)
+		columns = append(columns, spec.Raw)
+	}
+	fmt.Fprintln(ctx.G.Outfile, strings.Join(columns, "`"))
 }
 
 // OutputError generates an error line in the output file (typically a missing download) and
-// also prints the status message on stdout
+// also logs it as a warning
 func OutputError(ctx *Context, message string, args ...interface{}) {
-	fmt.Printf("***"+message, args...)
+	errType := ErrorType(message)
+	slog.Warn(strings.TrimSpace(fmt.Sprintf(message, args...)), "type", errType, "url", ctx.Url)
+	if ctx.G.Stats != nil {
+		ctx.G.Stats.RecordError(errType)
+	}
 	outmsg := fmt.Sprintf("%d`***", ctx.G.Linenum) + message
 	fmt.Fprintf(ctx.G.Outfile, outmsg, args...)
 	ctx.G.Linenum++
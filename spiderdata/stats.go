@@ -0,0 +1,233 @@
+package spiderdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// allStatuses lists the spider status values in the order we want to report them
+var allStatuses = []partcatalog.SpiderStatus{
+	partcatalog.NewPart,
+	partcatalog.PartChanged,
+	partcatalog.UnchangedPart,
+	partcatalog.DiscontinuedPart,
+	partcatalog.PartNotFoundBySpider,
+}
+
+// RunStats accumulates the counters that we report at the end of a run.
+// It has its own lock because the fetch handlers update it outside of Globals.Mu
+type RunStats struct {
+	mu              sync.Mutex
+	start           time.Time
+	pagesFetched    int
+	pagesReused     int
+	unableToProcess int
+	unusedDownloads int
+	currentURL      string
+	status          map[partcatalog.SpiderStatus]int
+	sections        map[string]map[partcatalog.SpiderStatus]int
+	errors          map[string]int
+}
+
+// Summary is the end of run report.  It is what gets written to the JSON summary file
+type Summary struct {
+	Target          string                    `json:"target"`
+	Started         time.Time                 `json:"started"`
+	Finished        time.Time                 `json:"finished"`
+	ElapsedSeconds  float64                   `json:"elapsedSeconds"`
+	PagesFetched    int                       `json:"pagesFetched"`
+	PagesReused     int                       `json:"pagesReused"`
+	UnableToProcess int                       `json:"unableToProcess"`
+	UnusedDownloads int                       `json:"unusedDownloads"`
+	Totals          map[string]int            `json:"totals"`
+	Sections        map[string]map[string]int `json:"sections"`
+	Errors          map[string]int            `json:"errors"`
+	CatalogSnapshot string                    `json:"catalogSnapshot,omitempty"` // The catalog the run was matched against
+}
+
+// NewRunStats creates an empty set of statistics with the clock started
+func NewRunStats() *RunStats {
+	return &RunStats{
+		start:    time.Now(),
+		status:   make(map[partcatalog.SpiderStatus]int),
+		sections: make(map[string]map[partcatalog.SpiderStatus]int),
+		errors:   make(map[string]int),
+	}
+}
+
+// PageFetched counts a page which came back from the vendor website
+func (rs *RunStats) PageFetched(url string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.pagesFetched++
+	rs.currentURL = url
+}
+
+// PageReused counts a page whose results were taken from the previous run because it hadn't changed
+func (rs *RunStats) PageReused(url string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.pagesReused++
+	rs.currentURL = url
+}
+
+// CurrentURL returns the page which was most recently fetched
+func (rs *RunStats) CurrentURL() string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.currentURL
+}
+
+// RecordPart counts a part line written to the output file
+func (rs *RunStats) RecordPart(partData *partcatalog.PartData) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.status[partData.SpiderStatus]++
+	section := TopLevelSection(partData.Section)
+	counts, found := rs.sections[section]
+	if !found {
+		counts = make(map[partcatalog.SpiderStatus]int)
+		rs.sections[section] = counts
+	}
+	counts[partData.SpiderStatus]++
+}
+
+// RecordError counts an error of the given type
+func (rs *RunStats) RecordError(errType string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.errors[errType]++
+	switch errType {
+	case "Unable to process":
+		rs.unableToProcess++
+	case "Unused download":
+		rs.unusedDownloads++
+	}
+}
+
+// Summary takes a snapshot of the statistics as the final report
+func (rs *RunStats) Summary(target string) Summary {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	now := time.Now()
+	result := Summary{
+		Target:          target,
+		Started:         rs.start,
+		Finished:        now,
+		ElapsedSeconds:  now.Sub(rs.start).Seconds(),
+		PagesFetched:    rs.pagesFetched,
+		PagesReused:     rs.pagesReused,
+		UnableToProcess: rs.unableToProcess,
+		UnusedDownloads: rs.unusedDownloads,
+		Totals:          statusCounts(rs.status),
+		Sections:        make(map[string]map[string]int),
+		Errors:          make(map[string]int),
+	}
+	for section, counts := range rs.sections {
+		result.Sections[section] = statusCounts(counts)
+	}
+	for errType, count := range rs.errors {
+		result.Errors[errType] = count
+	}
+	return result
+}
+
+// statusCounts turns the counters into a map by status name, making sure every status is present
+func statusCounts(counts map[partcatalog.SpiderStatus]int) map[string]int {
+	result := make(map[string]int)
+	for _, status := range allStatuses {
+		result[status.String()] = counts[status]
+	}
+	return result
+}
+
+// TopLevelSection returns the first component of a section breadcrumb
+// e.g. "MOTION > Hubs > Sonic Hubs" gives "MOTION"
+func TopLevelSection(section string) string {
+	top, _, _ := strings.Cut(section, " > ")
+	top = strings.TrimSpace(top)
+	if top == "" {
+		top = "(none)"
+	}
+	return top
+}
+
+// ErrorType extracts the type of an error from the format string passed to OutputError.
+// This is the leading text up to the first separator, so "Unable to process: %s\n" becomes "Unable to process"
+func ErrorType(message string) string {
+	message = strings.TrimLeft(message, "*")
+	pos := strings.IndexAny(message, ":%`'\n")
+	if pos >= 0 {
+		message = message[:pos]
+	}
+	return strings.TrimSpace(message)
+}
+
+// Print writes the human readable version of the summary
+func (s Summary) Print(w io.Writer) {
+	fmt.Fprintln(w, strings.Repeat("=", 72))
+	fmt.Fprintf(w, "Summary for %s\n", s.Target)
+	fmt.Fprintf(w, "Elapsed:           %v\n", time.Duration(s.ElapsedSeconds*float64(time.Second)).Round(time.Second))
+	fmt.Fprintf(w, "Pages fetched:     %d\n", s.PagesFetched)
+	if s.PagesReused > 0 {
+		fmt.Fprintf(w, "Pages reused:      %d\n", s.PagesReused)
+	}
+	fmt.Fprintf(w, "Unable to process: %d\n", s.UnableToProcess)
+	fmt.Fprintf(w, "Unused downloads:  %d\n", s.UnusedDownloads)
+
+	fmt.Fprintf(w, "\n%-30s", "Section")
+	for _, status := range allStatuses {
+		fmt.Fprintf(w, " %14s", status.String())
+	}
+	fmt.Fprintln(w)
+	sections := make([]string, 0, len(s.Sections))
+	for section := range s.Sections {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	for _, section := range sections {
+		printStatusRow(w, section, s.Sections[section])
+	}
+	printStatusRow(w, "TOTAL", s.Totals)
+
+	if len(s.Errors) > 0 {
+		fmt.Fprintln(w, "\nErrors:")
+		errTypes := make([]string, 0, len(s.Errors))
+		for errType := range s.Errors {
+			errTypes = append(errTypes, errType)
+		}
+		sort.Strings(errTypes)
+		for _, errType := range errTypes {
+			fmt.Fprintf(w, "  %6d %s\n", s.Errors[errType], errType)
+		}
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 72))
+}
+
+func printStatusRow(w io.Writer, name string, counts map[string]int) {
+	fmt.Fprintf(w, "%-30s", name)
+	for _, status := range allStatuses {
+		fmt.Fprintf(w, " %14d", counts[status.String()])
+	}
+	fmt.Fprintln(w)
+}
+
+// WriteJSON saves the summary as a machine readable file
+func (s Summary) WriteJSON(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,61 @@
+package spiderdata
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/bigcommerce"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// FetchStorefrontProduct asks the storefront API about the product on a page so that the parser can
+// use it instead of what it scrapes.  It is called before the page is parsed (and without holding
+// Globals.Mu) so that the request doesn't hold up the other pages.  Any failure just leaves the
+// parser to work from the page
+func FetchStorefrontProduct(ctx *Context, doc *goquery.Document) {
+	client := ctx.G.Storefront
+	if client == nil || client.Disabled() {
+		return
+	}
+	id := ProductID(doc.Selection)
+	if id == "" {
+		return
+	}
+	if !client.FindToken(doc.Selection) {
+		slog.Debug("no storefront API token on page", "phase", "crawl", "url", ctx.Url)
+		return
+	}
+	product, err := client.Product(id)
+	if err != nil {
+		if !errors.Is(err, bigcommerce.ErrDisabled) {
+			slog.Warn("storefront API failed, using the page instead", "phase", "crawl", "url", ctx.Url, "product", id, "err", err)
+			if client.Disabled() {
+				slog.Warn("giving up on the storefront API for this run", "phase", "crawl", "failures", bigcommerce.MaxFailures)
+			}
+		}
+		return
+	}
+	ctx.Storefront = product
+}
+
+// StorefrontAvailability combines what the page says about the availability of a product with what
+// the storefront API says, for the parts returned by StorefrontParts
+func StorefrontAvailability(ctx *Context, availability Availability) Availability {
+	if ctx.Storefront != nil && ctx.Storefront.Unavailable() {
+		return Discontinued
+	}
+	return availability
+}
+
+// StorefrontParts returns the parts the storefront API gave for the product in a section of the page.
+// It returns false when the API wasn't used for the page or the section is a different product, in
+// which case the parser should scrape the page as usual
+func StorefrontParts(ctx *Context, product *goquery.Selection, url string) ([]*partcatalog.PartData, bool) {
+	if ctx.Storefront == nil || ProductID(product) != strconv.Itoa(ctx.Storefront.ID) {
+		return nil, false
+	}
+	parts := ctx.Storefront.Parts(url)
+	return parts, len(parts) > 0
+}
@@ -0,0 +1,119 @@
+package spiderdata
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// VariantGroup is one product along with all of its variants.  This is what lets a library
+// create a single configurable part instead of a row for every option
+type VariantGroup struct {
+	ParentID   string         `json:"parentId"`
+	BaseName   string         `json:"baseName"`
+	Section    string         `json:"section"`
+	URL        string         `json:"url"`
+	Attributes []string       `json:"attributes"` // The names of all the options used by the variants, in the order first seen
+	Variants   []VariantEntry `json:"variants"`
+}
+
+// VariantEntry is one variant within a group
+type VariantEntry struct {
+	SKU          string                         `json:"sku"`
+	Name         string                         `json:"name"`
+	SpiderStatus partcatalog.SpiderStatus       `json:"spiderStatus"`
+	ModelURL     string                         `json:"modelUrl,omitempty"`
+	Values       []partcatalog.VariantAttribute `json:"values"`
+}
+
+// ProductID finds the vendor's identifier for a product from the hidden form fields or data attributes
+// that the shopping cart uses.  It returns "" when none is found
+func ProductID(product *goquery.Selection) string {
+	if id, found := product.Find("input[name=product_id]").Attr("value"); found && id != "" {
+		return id
+	}
+	for _, attr := range []string{"data-product-id", "data-productid"} {
+		if id, found := product.Attr(attr); found && id != "" {
+			return id
+		}
+		if id, found := product.Find("[" + attr + "]").Attr(attr); found && id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// OptionName cleans up the label for a set of options such as "Cable Length:Required" to be just "Cable Length"
+func OptionName(label string, def string) string {
+	label = strings.TrimSpace(label)
+	if pos := strings.Index(label, ":"); pos >= 0 {
+		label = strings.TrimSpace(label[:pos])
+	}
+	if label == "" {
+		return def
+	}
+	return label
+}
+
+// GroupVariants collects the parts which are variants into one group per product.  The groups are
+// in the order the products were found
+func GroupVariants(parts []*partcatalog.PartData) []VariantGroup {
+	var result []VariantGroup
+	index := make(map[string]int)
+	for _, partData := range parts {
+		if len(partData.Variant) == 0 {
+			continue
+		}
+		idx, found := index[partData.ParentID]
+		if !found {
+			idx = len(result)
+			index[partData.ParentID] = idx
+			result = append(result, VariantGroup{
+				ParentID: partData.ParentID,
+				BaseName: partData.BaseName,
+				Section:  partData.Section,
+				URL:      partData.URL,
+			})
+		}
+		group := &result[idx]
+		for _, attr := range partData.Variant {
+			known := false
+			for _, name := range group.Attributes {
+				if name == attr.Name {
+					known = true
+					break
+				}
+			}
+			if !known {
+				group.Attributes = append(group.Attributes, attr.Name)
+			}
+		}
+		group.Variants = append(group.Variants, VariantEntry{
+			SKU:          partData.SKU,
+			Name:         partData.Name,
+			SpiderStatus: partData.SpiderStatus,
+			ModelURL:     partData.ModelURL,
+			Values:       partData.Variant,
+		})
+	}
+	for _, group := range result {
+		sort.SliceStable(group.Variants, func(i, j int) bool { return group.Variants[i].SKU < group.Variants[j].SKU })
+	}
+	return result
+}
+
+// WriteVariantGroups saves the groups as a JSON file
+func WriteVariantGroups(path string, groups []VariantGroup) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(groups)
+}
@@ -2,10 +2,13 @@ package studica
 
 import (
 	"fmt"
+	"log/slog"
+	"os"
 	"regexp"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/toebes/ftc_parts_spider/onshape"
 	"github.com/toebes/ftc_parts_spider/partcatalog"
 	"github.com/toebes/ftc_parts_spider/spiderdata"
 )
@@ -14,18 +17,24 @@ import (
 var StudicaTarget = spiderdata.SpiderTarget{
 	Outfile:            "studica.txt",
 	SpreadsheetID:      "1xomFgFZ3Ie79XHOMbAX76sSRYDzkkj3VywsakY3DCjA",
+	OnshapeNaming:      onshape.Naming{Vendor: "Studica"},
 	Presets:            []string{},
 	StripSKU:           true,
-	Seed:               "https://www.studica.com/sitemap.xml",
+	Seed:               "https://www.studica.com/",
+	Sitemap:            true,
 	ParsePageFunc:      ParseStudicaPage,
 	CheckMatchFunc:     CheckStudicaMatch,
 	SectionNameDeletes: []string{},
 	SectionAllowedMap:  map[string]string{},
 	SectionEquivalents: [][]string{},
+	IncludeRules:       []spiderdata.URLRule{spiderdata.Host("www.studica.com")},
+	ExcludeRules: []spiderdata.URLRule{
+		spiderdata.Prefix("/cdn-cgi/"),
+		spiderdata.Glob("/spss-*"),
+		spiderdata.Glob("/worldskills-*"),
+	},
 	SkipPages: []string{
-		"https://www.studica.com/cdn-cgi/l/email-protection",
 		"https://www.studica.com/search",
-		"https://blog.studica.com",
 		"https://www.studica.com/about-us",
 		"https://www.studica.com/academic-verfication",
 		"https://www.studica.com/adobe-non-profit-value-incentive-plan",
@@ -90,7 +99,6 @@ var StudicaTarget = spiderdata.SpiderTarget{
 		"https://www.studica.com/v-ray-chaos-for-education",
 		"https://www.studica.com/webinars",
 		"https://www.studica.com/who-can-order",
-		"https://www.studica.com/worldskills-2021-mobile-robotics-competition",
 
 		// These are duplicates of other products
 		"https://www.studica.com/studica-robotics-brand/432mm-u-channel-black",
@@ -359,14 +367,6 @@ var StudicaTarget = spiderdata.SpiderTarget{
 		"https://www.studica.com/sorting-line-color-detection-24v-conveyor-plc",
 		"https://www.studica.com/sorting-line-color-detection-9v-conveyor-txt-controller",
 		"https://www.studica.com/sprocket-25-chain",
-		"https://www.studica.com/spss-student-amos-grad-pack",
-		"https://www.studica.com/spss-student-amos-grad-pack-v28",
-		"https://www.studica.com/spss-student-faculty-pack",
-		"https://www.studica.com/spss-student-faculty-pack-v28",
-		"https://www.studica.com/spss-student-grad-pack-v28-base",
-		"https://www.studica.com/spss-student-grad-pack-v28-premium",
-		"https://www.studica.com/spss-student-grad-pack-v28-standard",
-		"https://www.studica.com/spss-v29",
 		"https://www.studica.com/square-beam-2",
 		"https://www.studica.com/sreb-middle-school",
 		"https://www.studica.com/starter-kit",
@@ -418,9 +418,6 @@ var StudicaTarget = spiderdata.SpiderTarget{
 		"https://www.studica.com/vmx-wallwart-cable",
 		"https://www.studica.com/wheel",
 		"https://www.studica.com/wire-pack",
-		"https://www.studica.com/worldskills-2",
-		"https://www.studica.com/worldskills-mobile-robotics-shanghai",
-		"https://www.studica.com/worldskills-mobile-robotics-vmx-titan",
 		"https://www.studica.com/x-bracket",
 	},
 }
@@ -529,7 +526,7 @@ func CheckStudicaMatch(ctx *spiderdata.Context, partData *partcatalog.PartData)
 		}
 		// If the URL changes then we really want to use it.
 		// Just stash away the old URL so we know what happened
-		if !strings.EqualFold(partData.URL, entry.URL) {
+		if !spiderdata.SameURL(ctx, partData.URL, entry.URL) {
 			// In the case where there was a sku= on the URL we want to keep the one with it
 			urlString := partData.URL
 			newURL, strippedNew := spiderdata.CleanURL(ctx, partData.URL)
@@ -541,6 +538,9 @@ func CheckStudicaMatch(ctx *spiderdata.Context, partData *partcatalog.PartData)
 			// had the URL silently.
 			if strings.EqualFold(oldURL, newURL) {
 				partData.URL = urlString
+			} else if spiderdata.ReconcileMovedURL(ctx, partData, entry) {
+				// The catalog URL redirects here, so it has just moved.  Keep the new one and
+				// leave it for the URL moved report
 			} else {
 				partData.SpiderStatus = partcatalog.PartChanged
 				partData.Notes += extra + " Old URL:" + entry.URL
@@ -565,6 +565,8 @@ func CheckStudicaMatch(ctx *spiderdata.Context, partData *partcatalog.PartData)
 		}
 		// Prevent us from outputting the same entry more than once.
 		entry.SpiderStatus = partData.SpiderStatus
+		// Remember which row of the spreadsheet it came from so that any changes can be applied to it
+		partData.Row = entry.Row
 	} else {
 		partData.SpiderStatus = partcatalog.NewPart
 		partData.Status = "Not Done"
@@ -581,7 +583,7 @@ func findAllDownloads(ctx *spiderdata.Context, url string, root *goquery.Selecti
 		//<a target="_blank" class="product-documents__link" href="https://andymark-weblinc.netdna-ssl.com/media/W1siZiIsIjIwMTgvMTEvMDYvMTUvMDIvMTQvNTMwZjE4YmMtMmM5NS00Yzk3LTg3OWMtZjNmYzI1MTllMzJiL2FtLTMyODQgMzJ0IE5pbmphIFN0YXIgU3Byb2NrZXQuU1RFUCJdXQ/am-3284%2032t%20Ninja%20Star%20Sprocket.STEP?sha=9834a1285a141ddc">am-3284 32t Ninja Star Sprocket.STEP</a>
 		title := strings.TrimSpace(elem.Text())
 		dlurl, foundurl := elem.Attr("href")
-		fmt.Printf("Found a on '%v' href=%v\n", elem.Text(), dlurl)
+		slog.Debug("found description link", "title", elem.Text(), "href", dlurl)
 		if strings.HasSuffix(strings.ToUpper(dlurl), ".JPG") {
 			// We are going to ignore JPG files
 		} else if title == "" {
@@ -644,21 +646,31 @@ func getDownloadURL(_ /*ctx*/ *spiderdata.Context, sku string, downloadurls spid
 	return
 }
 
-func processProductDetail(ctx *spiderdata.Context, breadcrumbs string, url string, product *goquery.Selection) (found bool) {
+func processProductDetail(ctx *spiderdata.Context, breadcrumbs string, url string, product *goquery.Selection, availability spiderdata.Availability) (found bool) {
 	found = false
 
 	spiderdata.OutputCategory(ctx, breadcrumbs, true)
 	downloadurls := findAllDownloads(ctx, url, product)
 
 	localproductname := product.Find("div.product-name").Text()
+	parentID := spiderdata.ProductID(product)
+	specs := spiderdata.ExtractSpecs(product)
+	images := spiderdata.ExtractImages(ctx, product)
 
 	// See if this has a set of options
 	productVariants := product.Find("div.product-variant-list div.product-variant-line")
 	if productVariants.Length() > 0 {
+		label := variantLabel(product)
 		productVariants.Each(func(i int, variant *goquery.Selection) {
 			variantName := variant.Find("div.variant-name").Text()
 			variantSKU := variant.Find("div.manufacturer-part-number span.value").Text()
-			spiderdata.OutputProduct(ctx, localproductname+" - "+variantName, variantSKU, url, getDownloadURL(ctx, variantSKU, downloadurls), false, nil)
+			partData := spiderdata.NewProductData(localproductname+" - "+variantName, variantSKU, url, getDownloadURL(ctx, variantSKU, downloadurls), nil)
+			partData.ParentID = parentID
+			partData.BaseName = strings.TrimSpace(localproductname)
+			partData.Variant = []partcatalog.VariantAttribute{{Name: label, Value: strings.TrimSpace(variantName)}}
+			partData.Specs = specs
+			partData.Images = images
+			spiderdata.OutputProductData(ctx, partData, max(availability, spiderdata.TextAvailability(variant, "")))
 		})
 		found = true
 		return
@@ -666,12 +678,26 @@ func processProductDetail(ctx *spiderdata.Context, breadcrumbs string, url strin
 	productForm := product.Find("#product-details-form")
 	productForm.Each(func(i int, formElem *goquery.Selection) {
 		sku := formElem.Find("div.manufacturer-part-number span.value").Text()
-		spiderdata.OutputProduct(ctx, localproductname, sku, url, getDownloadURL(ctx, sku, downloadurls), false, nil)
+		partData := spiderdata.NewProductData(localproductname, sku, url, getDownloadURL(ctx, sku, downloadurls), nil)
+		partData.Specs = specs
+		partData.Images = images
+		spiderdata.OutputProductData(ctx, partData, availability)
 		found = true
 	})
 	return
 }
 
+// variantLabel finds what the variants of a product choose between (Color, Length, Bore...) from the heading
+// of the variant list or the heading just before it.  Without a heading they are just called "Variant"
+func variantLabel(product *goquery.Selection) string {
+	list := product.Find("div.product-variant-list").First()
+	heading := list.Find("h2, h3, h4, .title").First()
+	if heading.Length() == 0 {
+		heading = list.PrevAll().Filter("h2, h3, h4, label").First()
+	}
+	return spiderdata.OptionName(heading.Text(), "Variant")
+}
+
 // Recursive function to print the HTML tree
 func printHTMLTree(selection *goquery.Selection, indent int) {
 	// Print the current node
@@ -679,11 +705,11 @@ func printHTMLTree(selection *goquery.Selection, indent int) {
 		node := s.Get(0)
 
 		// Indentation for visualizing hierarchy
-		fmt.Println(strings.Repeat("  ", indent), "<", node.Data, ">")
+		fmt.Fprintln(os.Stderr, strings.Repeat("  ", indent), "<", node.Data, ">")
 
 		// Print attributes
 		for _, attr := range node.Attr {
-			fmt.Printf("%s  - %s=\"%s\"\n", strings.Repeat("  ", indent+1), attr.Key, attr.Val)
+			fmt.Fprintf(os.Stderr, "%s  - %s=\"%s\"\n", strings.Repeat("  ", indent+1), attr.Key, attr.Val)
 		}
 
 		// Recursively print children
@@ -764,15 +790,6 @@ func cacheTagLinks(ctx *spiderdata.Context, ptpDiv *goquery.Selection) (found bo
 	return
 }
 
-func CacheSiteURLs(ctx *spiderdata.Context, urlset *goquery.Selection) {
-	urllocs := urlset.Find("url loc")
-	urllocs.Each(func(i int, loc *goquery.Selection) {
-		if !ctx.G.SingleOnly {
-			spiderdata.EnqueURL(ctx, loc.Text(), "")
-		}
-	})
-}
-
 var fixMenus = map[string]string{"Bundles > All Bundles": "Bundles"}
 
 func CacheNavMenu(ctx *spiderdata.Context, navtitle string, l2menu *goquery.Selection) {
@@ -839,31 +856,26 @@ func ParseStudicaPage(ctx *spiderdata.Context, doc *goquery.Document) {
 	// Remember that we have been here so that we can mark it as complete
 	spiderdata.MarkVisitedURL(ctx, url, breadcrumbs)
 
-	// TODO: see if this has been discontinued
-	// isDiscontinued := (doc.Find("p.discontinued").Length() > 0)
-
-	// See if this is the main site map page
-	sitemap := doc.Find("urlset")
-	sitemap.Each(func(i int, urlset *goquery.Selection) {
-		CacheSiteURLs(ctx, urlset)
-		found = true
-	})
-
 	if !found {
-		// Enqueue any related products
-		if !ctx.G.SingleOnly {
-			doc.Find("div.related-products-grid a").Each(func(i int, a *goquery.Selection) {
-				url, foundurl := a.Attr("href")
-				if foundurl {
-					if strings.HasSuffix(strings.ToUpper(url), ".STP") ||
-						strings.Contains(strings.ToUpper(url), "CAD.ONSHAPE.COM") {
-						// We just want to ignore them
-					} else {
+		// Record and enqueue any related products
+		doc.Find("div.related-products-grid a").Each(func(i int, a *goquery.Selection) {
+			url, foundurl := a.Attr("href")
+			if foundurl {
+				if strings.HasSuffix(strings.ToUpper(url), ".STP") ||
+					strings.Contains(strings.ToUpper(url), "CAD.ONSHAPE.COM") {
+					// We just want to ignore them
+				} else {
+					title, _ := a.Attr("title")
+					if title == "" {
+						title = a.Text()
+					}
+					spiderdata.RecordRelated(ctx, url, "related", title)
+					if !ctx.G.SingleOnly {
 						spiderdata.EnqueURL(ctx, url, "")
 					}
 				}
-			})
-		}
+			}
+		})
 	}
 
 	if !found {
@@ -887,7 +899,9 @@ func ParseStudicaPage(ctx *spiderdata.Context, doc *goquery.Document) {
 	if !found {
 		doc.Find("div.product-details-page").Each(func(i int, product *goquery.Selection) {
 			// fmt.Printf("Found Product Detail Container")
-			if processProductDetail(ctx, breadcrumbs, url, product) {
+			// The variants are checked individually since only some of them may be discontinued
+			availability := spiderdata.ProductAvailability(doc, product, "div.product-variant-list")
+			if processProductDetail(ctx, breadcrumbs, url, product, availability) {
 				found = true
 			}
 		})
@@ -0,0 +1,183 @@
+package thumbnails
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif" // Registered so that image.Decode understands the vendor pictures
+	"image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+// DefaultSize is the largest width or height of a thumbnail
+const DefaultSize = 160
+
+// Archive downloads product pictures and keeps a small copy of each of them in a directory.
+// The files are named by SKU, the primary image is SKU.jpg and the gallery images are SKU-2.jpg, SKU-3.jpg...
+type Archive struct {
+	Dir     string
+	Size    int
+	Client  *http.Client
+	Workers int
+	// Refresh downloads the pictures even when we already have a thumbnail for them
+	Refresh bool
+}
+
+// unsafeFileChars are the characters which can't safely be used in a file name
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// FileName is the name of the thumbnail for a SKU.  index 0 is the primary image
+func FileName(sku string, index int) string {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(sku, "_"), "_.")
+	if index > 0 {
+		name += fmt.Sprintf("-%d", index+1)
+	}
+	return name + ".jpg"
+}
+
+// job is one picture to fetch
+type job struct {
+	url  string
+	path string
+}
+
+// SaveAll makes thumbnails for the pictures of all the parts which have a SKU.
+// It returns how many thumbnails were written and how many pictures could not be used
+func (a *Archive) SaveAll(parts []*partcatalog.PartData) (saved int, failed int, err error) {
+	if err := os.MkdirAll(a.Dir, 0o755); err != nil {
+		return 0, 0, err
+	}
+	jobs := make(chan job)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	workers := max(a.Workers, 1)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				err := a.save(j.url, j.path)
+				mu.Lock()
+				if err != nil {
+					slog.Warn("unable to make thumbnail", "url", j.url, "err", err)
+					failed++
+				} else {
+					saved++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	seen := make(map[string]bool)
+	for _, partData := range parts {
+		if partData.SKU == "" {
+			continue
+		}
+		for idx, url := range partData.Images {
+			path := filepath.Join(a.Dir, FileName(partData.SKU, idx))
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			if !a.Refresh {
+				if _, err := os.Stat(path); err == nil {
+					continue
+				}
+			}
+			jobs <- job{url, path}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return saved, failed, nil
+}
+
+// save downloads a single picture and writes the thumbnail for it
+func (a *Archive) save(url string, path string) error {
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	img, _, err := image.Decode(res.Body)
+	if err != nil {
+		return err
+	}
+	size := a.Size
+	if size <= 0 {
+		size = DefaultSize
+	}
+	// Write to a temporary file first so that a failure never leaves a partial thumbnail behind
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = jpeg.Encode(f, Resize(img, size), &jpeg.Options{Quality: 85})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Resize scales an image so that neither side is larger than size, keeping the aspect ratio.
+// Each pixel of the result is the average of the pixels it covers in the original, which gives a
+// much better looking thumbnail than just picking pixels.  Transparent areas come out white
+func Resize(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW > size || srcH > size {
+		if srcW >= srcH {
+			dstW, dstH = size, max(srcH*size/srcW, 1)
+		} else {
+			dstW, dstH = max(srcW*size/srcH, 1), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(bounds.Min.Y+(y+1)*srcH/dstH, y0+1)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(bounds.Min.X+(x+1)*srcW/dstW, x0+1)
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					// Blend onto white using the premultiplied values
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					b += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset+0] = uint8(r / n >> 8)
+			dst.Pix[offset+1] = uint8(g / n >> 8)
+			dst.Pix[offset+2] = uint8(b / n >> 8)
+			dst.Pix[offset+3] = 0xff
+		}
+	}
+	return dst
+}
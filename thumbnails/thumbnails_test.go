@@ -0,0 +1,143 @@
+package thumbnails
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toebes/ftc_parts_spider/partcatalog"
+)
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		size          int
+		wantW, wantH  int
+	}{
+		{"landscape", 400, 200, 160, 160, 80},
+		{"portrait", 100, 400, 160, 40, 160},
+		{"square", 321, 321, 160, 160, 160},
+		// Small pictures are never enlarged
+		{"small", 60, 30, 160, 60, 30},
+		// A very thin picture still has a pixel on its short side
+		{"sliver", 1000, 2, 160, 160, 1},
+	}
+	for _, test := range tests {
+		src := image.NewRGBA(image.Rect(0, 0, test.width, test.height))
+		got := Resize(src, test.size).Bounds()
+		if got.Dx() != test.wantW || got.Dy() != test.wantH {
+			t.Errorf("%s: Resize(%dx%d, %d) = %dx%d, expected %dx%d", test.name, test.width, test.height, test.size,
+				got.Dx(), got.Dy(), test.wantW, test.wantH)
+		}
+	}
+}
+
+func TestResizePixels(t *testing.T) {
+	// A black and white checkerboard averages out to gray, and transparency comes out white
+	src := image.NewNRGBA(image.Rect(10, 10, 14, 12))
+	for y := 10; y < 12; y++ {
+		for x := 10; x < 12; x++ {
+			if (x+y)%2 == 0 {
+				src.Set(x, y, color.Black)
+			} else {
+				src.Set(x, y, color.White)
+			}
+		}
+	}
+	// The right half is left transparent
+	dst := Resize(src, 2)
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, color.RGBA{0x7f, 0x7f, 0x7f, 0xff}},
+		{1, 0, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		if got := dst.At(test.x, test.y); got != test.want {
+			t.Errorf("pixel %d,%d = %v, expected %v", test.x, test.y, got, test.want)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		sku   string
+		index int
+		want  string
+	}{
+		{"REV-41-1300", 0, "REV-41-1300.jpg"},
+		{"REV-41-1300", 2, "REV-41-1300-3.jpg"},
+		{"am-2490/b", 0, "am-2490_b.jpg"},
+		{"../585000", 1, "585000-2.jpg"},
+	}
+	for _, test := range tests {
+		if got := FileName(test.sku, test.index); got != test.want {
+			t.Errorf("FileName(%q, %d) = %q, expected %q", test.sku, test.index, got, test.want)
+		}
+	}
+}
+
+func TestSaveAll(t *testing.T) {
+	var picture bytes.Buffer
+	if err := png.Encode(&picture, image.NewRGBA(image.Rect(0, 0, 320, 240))); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/motor.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(picture.Bytes())
+	})
+	mux.HandleFunc("/broken.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a picture"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	// A thumbnail we already have is kept without downloading it again
+	if err := os.WriteFile(filepath.Join(dir, "585000.jpg"), []byte("kept"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parts := []*partcatalog.PartData{
+		{SKU: "REV-41-1300", Images: []string{server.URL + "/motor.png", server.URL + "/missing.png"}},
+		{SKU: "585000", Images: []string{server.URL + "/broken.png"}},
+		{SKU: "", Images: []string{server.URL + "/motor.png"}},
+	}
+	archive := Archive{Dir: dir, Size: 160, Client: server.Client(), Workers: 2}
+	saved, failed, err := archive.SaveAll(parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved != 1 || failed != 1 {
+		t.Errorf("SaveAll = %d saved, %d failed, expected 1 saved, 1 failed", saved, failed)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "REV-41-1300.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	thumbnail, err := jpeg.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumbnail.Width != 160 || thumbnail.Height != 120 {
+		t.Errorf("thumbnail is %dx%d, expected 160x120", thumbnail.Width, thumbnail.Height)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "585000.jpg")); string(data) != "kept" {
+		t.Errorf("the existing thumbnail was replaced")
+	}
+	for _, name := range []string{"REV-41-1300-2.jpg", "REV-41-1300-2.jpg.tmp"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s was written for a missing picture", name)
+		}
+	}
+}